@@ -0,0 +1,463 @@
+// Package main provides the core functionality for updating GitHub star counts in Markdown and AsciiDoc files.
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/google/go-github/v68/github"
+	"github.com/stn1slv/github-markdown-stars-updater/pkg/stars"
+)
+
+// Supported ways for the action command to publish the updated documents.
+const (
+	actionCommit      = "commit"       // commit to the branch
+	actionPullRequest = "pull-request" // commit to a separate branch and open or update a pull request
+	actionNone        = "none"         // only update the files in the workspace
+)
+
+// Defaults of the action command.
+const (
+	defaultCommitMessage = "Update GitHub star counts"
+	defaultPRBranch      = "stars-updater/update"
+)
+
+// actionOutputDelimiter ends multiline values in the GitHub Actions output file.
+const actionOutputDelimiter = "STARS_UPDATER_EOF"
+
+// actionOptions configures how the action command publishes the updated documents.
+type actionOptions struct {
+	mode          string
+	repo          string // owner/repo; defaults to GITHUB_REPOSITORY
+	branch        string // branch to commit to, or base of the pull request; defaults to GITHUB_REF_NAME
+	commitMessage string
+	prBranch      string
+	prTitle       string
+	prBody        string
+}
+
+// actionEnv is the part of the GitHub Actions environment the action command uses.
+type actionEnv struct {
+	repository string // GITHUB_REPOSITORY
+	refName    string // GITHUB_REF_NAME, the checked-out branch
+	sha        string // GITHUB_SHA, the checked-out commit
+	workspace  string // GITHUB_WORKSPACE, the root of the checkout
+	outputPath string // GITHUB_OUTPUT
+}
+
+// actionEnvFromOS reads the GitHub Actions environment.
+func actionEnvFromOS() actionEnv {
+	return actionEnv{
+		repository: os.Getenv("GITHUB_REPOSITORY"),
+		refName:    os.Getenv("GITHUB_REF_NAME"),
+		sha:        os.Getenv("GITHUB_SHA"),
+		workspace:  os.Getenv("GITHUB_WORKSPACE"),
+		outputPath: os.Getenv("GITHUB_OUTPUT"),
+	}
+}
+
+// changedFile is a document the update changed, with its path relative to the repository root.
+type changedFile struct {
+	path    string
+	content string
+}
+
+// actionResult is what the action command reports in its outputs.
+type actionResult struct {
+	changed           []changedFile
+	repos             []string
+	commitSHA         string
+	pullRequestURL    string
+	pullRequestNumber int
+}
+
+// runAction updates files like the update command, then publishes the changed documents through
+// the GitHub API according to opts.action.mode and writes the outputs of the GitHub Action.
+func runAction(ctx context.Context, files []string, opts *options, mapping map[string][]PackageRef, fetcher *starFetcher, client *github.Client, stdout, stderr io.Writer) int {
+	env := actionEnvFromOS()
+	var result actionResult
+	exitCode := 0
+	for _, file := range files {
+		changed, repos, err := updateForAction(ctx, file, opts, mapping, fetcher, env.workspace, stdout, stderr)
+		if err != nil {
+			_, _ = fmt.Fprintln(stderr, "Error:", err)
+			exitCode = 1
+			continue
+		}
+		if changed != nil {
+			result.changed = append(result.changed, *changed)
+			result.repos = append(result.repos, repos...)
+		}
+	}
+	slices.Sort(result.repos)
+	result.repos = slices.Compact(result.repos)
+
+	if len(result.changed) == 0 {
+		_, _ = fmt.Fprintln(stdout, "No documents changed.")
+	} else if opts.action.mode != actionNone {
+		if err := publish(ctx, client, &opts.action, env, &result); err != nil {
+			_, _ = fmt.Fprintln(stderr, "Error:", err)
+			exitCode = 1
+		} else if result.pullRequestURL != "" {
+			_, _ = fmt.Fprintf(stdout, "Pull request %s updated with %d changed file(s).\n", result.pullRequestURL, len(result.changed))
+		} else {
+			_, _ = fmt.Fprintf(stdout, "Committed %d changed file(s) as %s.\n", len(result.changed), result.commitSHA)
+		}
+	}
+
+	if err := writeActionOutputs(env.outputPath, stdout, &result); err != nil {
+		_, _ = fmt.Fprintln(stderr, "Error writing outputs:", err)
+		exitCode = 1
+	}
+	return exitCode
+}
+
+// updateForAction updates file and returns it with the repositories whose labels changed, or a
+// nil file when the update left the document unchanged.
+func updateForAction(ctx context.Context, file string, opts *options, mapping map[string][]PackageRef, fetcher *starFetcher, workspace string, stdout, stderr io.Writer) (*changedFile, []string, error) {
+	before, err := os.ReadFile(filepath.Clean(file))
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading the file: %w", err)
+	}
+	if err := processFile(ctx, file, opts, mapping, fetcher, stdout, stderr); err != nil {
+		return nil, nil, err
+	}
+	after, err := os.ReadFile(filepath.Clean(file))
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading the file: %w", err)
+	}
+	if string(before) == string(after) {
+		return nil, nil, nil
+	}
+
+	path, err := repoPath(workspace, file)
+	if err != nil {
+		return nil, nil, err
+	}
+	format, err := formatOf(file, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	repos, err := changedRepos(string(before), string(after), format)
+	if err != nil {
+		return nil, nil, fmt.Errorf("finding repositories in %s: %w", file, err)
+	}
+	return &changedFile{path: path, content: string(after)}, repos, nil
+}
+
+// changedRepos returns the repositories whose label, badge or chart differs between before and
+// after. Each link is compared with the text that follows it up to the next link or the end of
+// the line, so lines that generated blocks insert or remove do not shift the comparison. The
+// links that generated blocks repeat are left out.
+func changedRepos(before, after string, format stars.Format) ([]string, error) {
+	old, err := linkTexts(before, format)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := linkTexts(after, format)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for key, texts := range updated {
+		if !slices.Equal(old[key], texts) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	return keys, nil
+}
+
+// linkTexts returns the text of every link of content by repository, in document order: the link
+// as written followed by its label images, up to the next link or the end of the line.
+func linkTexts(content string, format stars.Format) (map[string][]string, error) {
+	links, err := stars.FindLinks(content, format)
+	if err != nil {
+		return nil, err
+	}
+	blocks, err := findBlocks(content, format, blockSummary, blockTop)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(content, "\n")
+	texts := make(map[string][]string)
+	for i := 0; i < len(links); {
+		// The links of one line, each ending where the next one starts.
+		n := i + 1
+		for n < len(links) && links[n].Line == links[i].Line {
+			n++
+		}
+		line := strings.TrimRight(lines[links[i].Line-1], "\r")
+		starts := make([]int, 0, n-i)
+		from := 0
+		for _, link := range links[i:n] {
+			// A link wrapped onto the next line is not found; its text starts after the previous link.
+			if start := strings.Index(line[from:], link.Markup); start >= 0 {
+				from += start
+			}
+			starts = append(starts, from)
+			from += min(len(link.Markup), len(line)-from)
+		}
+		for j, link := range links[i:n] {
+			if slices.ContainsFunc(blocks, func(b generatedBlock) bool { return b.contains(link.Line) }) {
+				continue
+			}
+			end := len(line)
+			if j+1 < len(starts) {
+				end = starts[j+1]
+			}
+			key := stars.RepoKey(cmp.Or(link.Repo, link.URL))
+			texts[key] = append(texts[key], strings.TrimSpace(line[starts[j]:end]))
+		}
+		i = n
+	}
+	return texts, nil
+}
+
+// repoPath returns file relative to the repository root workspace, with forward slashes as the
+// Git API expects. The working directory is the root when workspace is empty.
+func repoPath(workspace, file string) (string, error) {
+	if workspace == "" {
+		workspace = "."
+	}
+	root, err := filepath.Abs(workspace)
+	if err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside the repository %s", file, root)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// publish commits the changed documents to the branch, or to the pull request branch and opens or
+// updates the pull request, recording the commit and pull request in result.
+func publish(ctx context.Context, client *github.Client, opts *actionOptions, env actionEnv, result *actionResult) error {
+	repository := cmp.Or(opts.repo, env.repository)
+	owner, repo, ok := strings.Cut(repository, "/")
+	if !ok || owner == "" || repo == "" {
+		return fmt.Errorf("invalid repository %q (expected owner/repo; set -repo or GITHUB_REPOSITORY)", repository)
+	}
+	branch := cmp.Or(opts.branch, env.refName)
+	if branch == "" {
+		return errors.New("no branch to commit to (set -branch or GITHUB_REF_NAME)")
+	}
+
+	// Commit on top of the checked-out commit, so that a branch that moved since the checkout
+	// makes the commit mode fail instead of reverting the newer changes.
+	parent := env.sha
+	if parent == "" || branch != env.refName {
+		ref, _, err := client.Git.GetRef(ctx, owner, repo, "heads/"+branch)
+		if err != nil {
+			return fmt.Errorf("resolving branch %s: %w", branch, err)
+		}
+		parent = ref.GetObject().GetSHA()
+	}
+	message := cmp.Or(opts.commitMessage, defaultCommitMessage)
+	sha, err := commitFiles(ctx, client, owner, repo, parent, message, result.changed)
+	if err != nil {
+		return err
+	}
+	result.commitSHA = sha
+
+	if opts.mode == actionCommit {
+		ref := &github.Reference{Ref: github.Ptr("refs/heads/" + branch), Object: &github.GitObject{SHA: github.Ptr(sha)}}
+		if _, _, err := client.Git.UpdateRef(ctx, owner, repo, ref, false); err != nil {
+			return fmt.Errorf("updating branch %s: %w", branch, err)
+		}
+		return nil
+	}
+	return updatePullRequest(ctx, client, owner, repo, branch, sha, opts, result)
+}
+
+// commitFiles creates a commit with the changed files on top of parent and returns its SHA. The
+// branch is not moved.
+func commitFiles(ctx context.Context, client *github.Client, owner, repo, parent, message string, changed []changedFile) (string, error) {
+	parentCommit, _, err := client.Git.GetCommit(ctx, owner, repo, parent)
+	if err != nil {
+		return "", fmt.Errorf("reading commit %s: %w", parent, err)
+	}
+	baseTree := parentCommit.GetTree().GetSHA()
+	modes, err := treeModes(ctx, client, owner, repo, baseTree, changed)
+	if err != nil {
+		return "", err
+	}
+	entries := make([]*github.TreeEntry, 0, len(changed))
+	for _, file := range changed {
+		entries = append(entries, &github.TreeEntry{
+			Path:    github.Ptr(file.path),
+			Mode:    github.Ptr(cmp.Or(modes[file.path], "100644")),
+			Type:    github.Ptr("blob"),
+			Content: github.Ptr(file.content),
+		})
+	}
+	tree, _, err := client.Git.CreateTree(ctx, owner, repo, baseTree, entries)
+	if err != nil {
+		return "", fmt.Errorf("creating tree: %w", err)
+	}
+	commit, _, err := client.Git.CreateCommit(ctx, owner, repo, &github.Commit{
+		Message: github.Ptr(message),
+		Tree:    &github.Tree{SHA: tree.SHA},
+		Parents: []*github.Commit{{SHA: github.Ptr(parent)}},
+	}, nil)
+	if err != nil {
+		return "", fmt.Errorf("creating commit: %w", err)
+	}
+	return commit.GetSHA(), nil
+}
+
+// treeModes returns the file modes of the blobs in the tree sha by path, so that commitFiles keeps
+// the executable bit of the files it changes. New files get the default mode. When the recursive
+// listing is truncated, the changed paths it misses are looked up directory by directory.
+func treeModes(ctx context.Context, client *github.Client, owner, repo, sha string, changed []changedFile) (map[string]string, error) {
+	tree, _, err := client.Git.GetTree(ctx, owner, repo, sha, true)
+	if err != nil {
+		return nil, fmt.Errorf("reading tree %s: %w", sha, err)
+	}
+	modes := make(map[string]string, len(tree.Entries))
+	for _, entry := range tree.Entries {
+		if entry.GetType() == "blob" {
+			modes[entry.GetPath()] = entry.GetMode()
+		}
+	}
+	if !tree.GetTruncated() {
+		return modes, nil
+	}
+
+	dirs := map[string][]*github.TreeEntry{}
+	for _, file := range changed {
+		if _, ok := modes[file.path]; ok {
+			continue
+		}
+		entries, err := dirEntries(ctx, client, owner, repo, sha, path.Dir(file.path), dirs)
+		if err != nil {
+			return nil, err
+		}
+		name := path.Base(file.path)
+		for _, entry := range entries {
+			if entry.GetPath() == name && entry.GetType() == "blob" {
+				modes[file.path] = entry.GetMode()
+			}
+		}
+	}
+	return modes, nil
+}
+
+// dirEntries returns the entries of the directory dir of the tree sha, reading one tree per path
+// segment without recursion. The listings are cached in dirs, and a missing directory has none.
+func dirEntries(ctx context.Context, client *github.Client, owner, repo, sha, dir string, dirs map[string][]*github.TreeEntry) ([]*github.TreeEntry, error) {
+	if entries, ok := dirs[dir]; ok {
+		return entries, nil
+	}
+	treeSHA := sha
+	if dir != "." {
+		parent, err := dirEntries(ctx, client, owner, repo, sha, path.Dir(dir), dirs)
+		if err != nil {
+			return nil, err
+		}
+		treeSHA = ""
+		for _, entry := range parent {
+			if entry.GetPath() == path.Base(dir) && entry.GetType() == "tree" {
+				treeSHA = entry.GetSHA()
+			}
+		}
+		if treeSHA == "" {
+			dirs[dir] = nil
+			return nil, nil
+		}
+	}
+	tree, _, err := client.Git.GetTree(ctx, owner, repo, treeSHA, false)
+	if err != nil {
+		return nil, fmt.Errorf("reading tree %s: %w", treeSHA, err)
+	}
+	dirs[dir] = tree.Entries
+	return tree.Entries, nil
+}
+
+// updatePullRequest points the pull request branch at sha, replacing the previous update, and
+// opens a pull request into base unless one is already open.
+func updatePullRequest(ctx context.Context, client *github.Client, owner, repo, base, sha string, opts *actionOptions, result *actionResult) error {
+	head := cmp.Or(opts.prBranch, defaultPRBranch)
+	ref := &github.Reference{Ref: github.Ptr("refs/heads/" + head), Object: &github.GitObject{SHA: github.Ptr(sha)}}
+	_, resp, err := client.Git.GetRef(ctx, owner, repo, "heads/"+head)
+	switch {
+	case err == nil:
+		_, _, err = client.Git.UpdateRef(ctx, owner, repo, ref, true)
+	case resp != nil && resp.StatusCode == http.StatusNotFound:
+		_, _, err = client.Git.CreateRef(ctx, owner, repo, ref)
+	}
+	if err != nil {
+		return fmt.Errorf("updating branch %s: %w", head, err)
+	}
+
+	title := cmp.Or(opts.prTitle, cmp.Or(opts.commitMessage, defaultCommitMessage))
+	open, _, err := client.PullRequests.List(ctx, owner, repo, &github.PullRequestListOptions{
+		State: "open", Head: owner + ":" + head, Base: base,
+	})
+	if err != nil {
+		return fmt.Errorf("listing pull requests: %w", err)
+	}
+	var pr *github.PullRequest
+	if len(open) > 0 {
+		pr, _, err = client.PullRequests.Edit(ctx, owner, repo, open[0].GetNumber(), &github.PullRequest{
+			Title: github.Ptr(title), Body: github.Ptr(opts.prBody),
+		})
+	} else {
+		pr, _, err = client.PullRequests.Create(ctx, owner, repo, &github.NewPullRequest{
+			Title: github.Ptr(title), Head: github.Ptr(head), Base: github.Ptr(base), Body: github.Ptr(opts.prBody),
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("opening pull request: %w", err)
+	}
+	result.pullRequestURL = pr.GetHTMLURL()
+	result.pullRequestNumber = pr.GetNumber()
+	return nil
+}
+
+// writeActionOutputs appends the outputs to the GitHub Actions output file at path, or prints
+// them to stdout outside of GitHub Actions.
+func writeActionOutputs(path string, stdout io.Writer, result *actionResult) error {
+	var b strings.Builder
+	_, _ = fmt.Fprintf(&b, "changed-files=%d\n", len(result.changed))
+	_, _ = fmt.Fprintf(&b, "updated-repos<<%s\n", actionOutputDelimiter)
+	for _, repo := range result.repos {
+		_, _ = fmt.Fprintln(&b, repo)
+	}
+	_, _ = fmt.Fprintln(&b, actionOutputDelimiter)
+	_, _ = fmt.Fprintf(&b, "commit-sha=%s\n", result.commitSHA)
+	_, _ = fmt.Fprintf(&b, "pull-request-url=%s\n", result.pullRequestURL)
+	if result.pullRequestNumber != 0 {
+		_, _ = fmt.Fprintf(&b, "pull-request-number=%d\n", result.pullRequestNumber)
+	} else {
+		_, _ = fmt.Fprintln(&b, "pull-request-number=")
+	}
+
+	if path == "" {
+		_, err := io.WriteString(stdout, b.String())
+		return err
+	}
+	f, err := os.OpenFile(filepath.Clean(path), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(b.String())
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
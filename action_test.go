@@ -0,0 +1,223 @@
+// Package main provides the core functionality for updating GitHub star counts in Markdown and AsciiDoc files.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stn1slv/github-markdown-stars-updater/pkg/stars"
+)
+
+// setupActionWorkspace creates a checkout with an outdated README.md and an up-to-date
+// docs/other.md, and the GitHub Actions environment of a push to main.
+func setupActionWorkspace(t *testing.T) (dir, outputPath string) {
+	t.Helper()
+	isolateAuthEnv(t)
+	t.Setenv("GITHUB_TOKEN", "test_token")
+	dir = t.TempDir()
+	writeFile(t, filepath.Join(dir, "README.md"), "# Tools\n- [A](https://github.com/owner/a)\n- [B (⭐20)](https://github.com/owner/b)\n")
+	writeFile(t, filepath.Join(dir, "docs", "other.md"), "- [B (⭐20)](https://github.com/owner/b)\n")
+	outputPath = filepath.Join(dir, "github-output")
+	t.Setenv("GITHUB_REPOSITORY", "me/docs")
+	t.Setenv("GITHUB_REF_NAME", "main")
+	t.Setenv("GITHUB_SHA", "base-sha")
+	t.Setenv("GITHUB_WORKSPACE", dir)
+	t.Setenv("GITHUB_OUTPUT", outputPath)
+	return dir, outputPath
+}
+
+func TestRunActionCommit(t *testing.T) {
+	api := newFakeAPI(t, map[string]int{"owner/a": 1500, "owner/b": 20})
+	dir, outputPath := setupActionWorkspace(t)
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"action", "-api-url", api.URL, "-commit-message", "docs: refresh stars", dir}
+	if code := run(args, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+
+	updated := "# Tools\n- [A (⭐1.5k)](https://github.com/owner/a)\n- [B (⭐20)](https://github.com/owner/b)\n"
+	if len(api.trees) != 1 || api.trees[0]["base_tree"] != "base-tree" {
+		t.Fatalf("expected one tree on top of base-tree, got %v", api.trees)
+	}
+	entries, _ := api.trees[0]["tree"].([]any)
+	entry, _ := entries[0].(map[string]any)
+	if len(entries) != 1 || entry["path"] != "README.md" || entry["content"] != updated {
+		t.Errorf("expected only README.md in the tree, got %v", entries)
+	}
+	if entry["mode"] != "100755" {
+		t.Errorf("expected the mode of README.md to be kept, got %v", entry["mode"])
+	}
+	if len(api.commits) != 1 || api.commits[0]["message"] != "docs: refresh stars" || fmt.Sprint(api.commits[0]["parents"]) != "[base-sha]" {
+		t.Errorf("unexpected commit %v", api.commits)
+	}
+	if strings.Join(api.refs, "\n") != "PATCH main new-sha false" {
+		t.Errorf("expected main to be fast-forwarded to new-sha, got %v", api.refs)
+	}
+
+	expected := "changed-files=1\nupdated-repos<<" + actionOutputDelimiter + "\nowner/a\n" + actionOutputDelimiter +
+		"\ncommit-sha=new-sha\npull-request-url=\npull-request-number=\n"
+	if got := mustRead(t, outputPath); got != expected {
+		t.Errorf("expected outputs %q, got %q", expected, got)
+	}
+}
+
+func TestRunActionTruncatedTree(t *testing.T) {
+	api := newFakeAPI(t, map[string]int{"owner/a": 1500, "owner/b": 20})
+	api.truncated = true
+	dir, _ := setupActionWorkspace(t)
+	writeFile(t, filepath.Join(dir, "docs", "other.md"), "- [A](https://github.com/owner/a)\n")
+	writeFile(t, filepath.Join(dir, "docs", "new.md"), "- [A](https://github.com/owner/a)\n")
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"action", "-api-url", api.URL, dir}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	if len(api.trees) != 1 {
+		t.Fatalf("expected one tree, got %v", api.trees)
+	}
+	// The modes missing from the truncated listing are read from the trees of their directories.
+	modes := map[any]any{}
+	entries, _ := api.trees[0]["tree"].([]any)
+	for _, e := range entries {
+		entry, _ := e.(map[string]any)
+		modes[entry["path"]] = entry["mode"]
+	}
+	expected := map[any]any{"README.md": "100755", "docs/other.md": "100755", "docs/new.md": "100644"}
+	if fmt.Sprint(modes) != fmt.Sprint(expected) {
+		t.Errorf("expected modes %v, got %v", expected, modes)
+	}
+}
+
+func TestRunActionRejectsLocalOutputs(t *testing.T) {
+	dir, _ := setupActionWorkspace(t)
+	tests := [][]string{
+		{"-history", filepath.Join(dir, "history.json")},
+		{"-cache", filepath.Join(dir, "cache.json")},
+		{"-mode", "pull-request", "-trend", "-history", filepath.Join(dir, "history.json")},
+	}
+	for _, flags := range tests {
+		var stdout, stderr bytes.Buffer
+		args := append(append([]string{"action"}, flags...), dir)
+		if code := run(args, &stdout, &stderr); code != 1 || !strings.Contains(stderr.String(), "require -mode none") {
+			t.Errorf("%v: expected exit code 1 requiring -mode none, got %d: %s", flags, code, stderr.String())
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	server := newFakeAPI(t, map[string]int{"owner/a": 1500, "owner/b": 20})
+	args := []string{"action", "-api-url", server.URL, "-mode", "none", "-history", filepath.Join(dir, "history.json"), dir}
+	if code := run(args, &stdout, &stderr); code != 0 {
+		t.Errorf("expected -history to be allowed with -mode none, got %d: %s", code, stderr.String())
+	}
+}
+
+func TestRunActionPullRequest(t *testing.T) {
+	api := newFakeAPI(t, map[string]int{"owner/a": 1500, "owner/b": 20})
+	dir, outputPath := setupActionWorkspace(t)
+	readme := filepath.Join(dir, "README.md")
+	original := mustRead(t, readme)
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"action", "-api-url", api.URL, "-mode", "pull-request", "-pr-title", "Refresh stars", readme}
+	if code := run(args, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	if strings.Join(api.refs, "\n") != "POST refs/heads/"+defaultPRBranch+" new-sha" || strings.Join(api.prUpdates, "\n") != "POST Refresh stars" {
+		t.Errorf("expected a new branch and pull request, got %v and %v", api.refs, api.prUpdates)
+	}
+	if !strings.Contains(mustRead(t, outputPath), "pull-request-url=https://github.com/me/docs/pull/7\npull-request-number=7\n") {
+		t.Errorf("unexpected outputs %q", mustRead(t, outputPath))
+	}
+
+	// The next run replaces the branch of the open pull request instead of opening another one.
+	writeFile(t, readme, original)
+	api.headRef, api.openPR = true, true
+	api.refs, api.prUpdates = nil, nil
+	if code := run(args, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	if strings.Join(api.refs, "\n") != "PATCH "+defaultPRBranch+" new-sha true" || strings.Join(api.prUpdates, "\n") != "PATCH Refresh stars" {
+		t.Errorf("expected the branch and pull request to be updated, got %v and %v", api.refs, api.prUpdates)
+	}
+}
+
+func TestRunActionNoChanges(t *testing.T) {
+	api := newFakeAPI(t, map[string]int{"owner/b": 20})
+	dir, outputPath := setupActionWorkspace(t)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"action", "-api-url", api.URL, filepath.Join(dir, "docs")}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	if len(api.commits) != 0 || len(api.refs) != 0 {
+		t.Errorf("expected nothing to be published, got %v and %v", api.commits, api.refs)
+	}
+	if got := mustRead(t, outputPath); !strings.HasPrefix(got, "changed-files=0\nupdated-repos<<"+actionOutputDelimiter+"\n"+actionOutputDelimiter+"\n") {
+		t.Errorf("unexpected outputs %q", got)
+	}
+}
+
+func TestRepoPath(t *testing.T) {
+	dir := t.TempDir()
+	if got, err := repoPath(dir, filepath.Join(dir, "docs", "list.md")); err != nil || got != "docs/list.md" {
+		t.Errorf("expected docs/list.md, got %q, %v", got, err)
+	}
+	if _, err := repoPath(filepath.Join(dir, "repo"), filepath.Join(dir, "other.md")); err == nil {
+		t.Error("expected an error for a file outside the repository")
+	}
+}
+
+func TestChangedRepos(t *testing.T) {
+	tests := []struct {
+		name          string
+		format        stars.Format
+		before, after string
+		want          []string
+	}{
+		{
+			name:   "label changed",
+			format: stars.FormatMarkdown,
+			before: "- [A (⭐1)](https://github.com/o/a) and [B (⭐2)](https://github.com/o/b)\n- [C](https://github.com/o/c)\n",
+			after:  "- [A (⭐1)](https://github.com/o/a) and [B (⭐3)](https://github.com/o/b)\n- [C (⭐4)](https://github.com/o/c)\n",
+			want:   []string{"o/b", "o/c"},
+		},
+		{
+			name:   "lines inserted by a generated block",
+			format: stars.FormatMarkdown,
+			before: "<!-- stars:top 1 -->\n<!-- stars:end -->\n- [A (⭐1)](https://github.com/o/a)\n- [B (⭐2)](https://github.com/o/b)\n",
+			after: "<!-- stars:top 1 -->\n1. [B (⭐5)](https://github.com/o/b)\n<!-- stars:end -->\n" +
+				"- [A (⭐1)](https://github.com/o/a)\n- [B (⭐5)](https://github.com/o/b)\n",
+			want: []string{"o/b"},
+		},
+		{
+			name:   "badge changed",
+			format: stars.FormatMarkdown,
+			before: "- [A](https://github.com/o/a) ![⭐1](https://img.shields.io/badge/stars-1-blue)\n",
+			after:  "- [A](https://github.com/o/a) ![⭐2](https://img.shields.io/badge/stars-2-blue)\n",
+			want:   []string{"o/a"},
+		},
+		{
+			name:   "AsciiDoc",
+			format: stars.FormatASCIIDoc,
+			before: "* https://github.com/o/a[A (⭐1)]\n* https://github.com/o/b[B (⭐2)]\n",
+			after:  "// stars:summary\n|===\n|===\n// stars:end\n* https://github.com/o/a[A (⭐1)]\n* https://github.com/o/b[B]\n",
+			want:   []string{"o/b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := changedRepos(tt.before, tt.after, tt.format)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
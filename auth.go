@@ -0,0 +1,432 @@
+// Package main provides the core functionality for updating GitHub star counts in Markdown and AsciiDoc files.
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/go-github/v68/github"
+	"golang.org/x/oauth2"
+	"gopkg.in/yaml.v3"
+)
+
+const defaultAPIURL = "https://api.github.com/"
+
+// apiBaseURL returns the GitHub REST API base URL s with the trailing slash go-github requires,
+// or defaultAPIURL when s is empty.
+func apiBaseURL(s string) string {
+	if s == "" {
+		return defaultAPIURL
+	}
+	return strings.TrimSuffix(s, "/") + "/"
+}
+
+// credential is a named source of GitHub tokens. The name describes where the token came from
+// and is safe to print; the token itself must never be logged.
+type credential struct {
+	name   string
+	source oauth2.TokenSource
+}
+
+// authOptions holds the explicitly configured authentication settings.
+type authOptions struct {
+	// tokenFile is a file containing one or more personal access tokens, one per line.
+	tokenFile string
+	// appID, appKeyFile and appInstallationID configure GitHub App authentication.
+	appID             int64
+	appKeyFile        string
+	appInstallationID int64
+	// apiURL is the GitHub REST API base URL used for the App token exchange.
+	apiURL string
+}
+
+// appOptionsFromEnv fills unset GitHub App settings from the GITHUB_APP_ID,
+// GITHUB_APP_PRIVATE_KEY_FILE and GITHUB_APP_INSTALLATION_ID environment variables.
+func (o *authOptions) appOptionsFromEnv() error {
+	if o.appID == 0 {
+		if v := os.Getenv("GITHUB_APP_ID"); v != "" {
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid GITHUB_APP_ID: %w", err)
+			}
+			o.appID = id
+		}
+	}
+	if o.appKeyFile == "" {
+		o.appKeyFile = os.Getenv("GITHUB_APP_PRIVATE_KEY_FILE")
+	}
+	if o.appInstallationID == 0 {
+		if v := os.Getenv("GITHUB_APP_INSTALLATION_ID"); v != "" {
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid GITHUB_APP_INSTALLATION_ID: %w", err)
+			}
+			o.appInstallationID = id
+		}
+	}
+	return nil
+}
+
+// resolveCredentials walks the authentication chain and returns the credentials of the first
+// source that is configured:
+//
+//  1. the -token-file flag (one token per line),
+//  2. a GitHub App (flags or GITHUB_APP_* environment variables),
+//  3. the GITHUB_TOKENS environment variable (comma or whitespace separated),
+//  4. the GITHUB_TOKEN or GH_TOKEN environment variables,
+//  5. the gh CLI configuration (hosts.yml),
+//  6. a .netrc entry for api.github.com or github.com.
+//
+// It returns no credentials when none is configured, in which case requests are anonymous.
+func resolveCredentials(ctx context.Context, opts authOptions) ([]*credential, error) {
+	if opts.tokenFile != "" {
+		tokens, err := readTokenFile(opts.tokenFile)
+		if err != nil {
+			return nil, err
+		}
+		return staticCredentials("-token-file "+opts.tokenFile, tokens), nil
+	}
+
+	if err := opts.appOptionsFromEnv(); err != nil {
+		return nil, err
+	}
+	if opts.appID != 0 || opts.appKeyFile != "" {
+		cred, err := newAppCredential(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		return []*credential{cred}, nil
+	}
+
+	if tokens := strings.FieldsFunc(os.Getenv("GITHUB_TOKENS"), func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	}); len(tokens) > 0 {
+		return staticCredentials("GITHUB_TOKENS", tokens), nil
+	}
+
+	if token, err := getAccessToken(); err == nil {
+		name := "GITHUB_TOKEN"
+		if os.Getenv(name) == "" {
+			name = "GH_TOKEN"
+		}
+		return staticCredentials(name, []string{token}), nil
+	}
+
+	token, path, err := ghCLIToken()
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		return staticCredentials("gh CLI config "+path, []string{token}), nil
+	}
+
+	token, path, err = netrcToken()
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		return staticCredentials("netrc "+path, []string{token}), nil
+	}
+
+	return nil, nil
+}
+
+// staticCredentials wraps tokens into credentials named after their source. When there are
+// several tokens, each name gets its position appended, e.g. "GITHUB_TOKENS #2".
+func staticCredentials(source string, tokens []string) []*credential {
+	creds := make([]*credential, 0, len(tokens))
+	for i, token := range tokens {
+		name := source
+		if len(tokens) > 1 {
+			name = fmt.Sprintf("%s #%d", source, i+1)
+		}
+		creds = append(creds, staticCredential(name, token))
+	}
+	return creds
+}
+
+func staticCredential(name, token string) *credential {
+	return &credential{name: name, source: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})}
+}
+
+// readTokenFile reads one token per non-empty line of path.
+func readTokenFile(path string) ([]string, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token file: %w", err)
+	}
+	var tokens []string
+	for line := range strings.SplitSeq(string(data), "\n") {
+		if token := strings.TrimSpace(line); token != "" {
+			tokens = append(tokens, token)
+		}
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("token file %s is empty", path)
+	}
+	return tokens, nil
+}
+
+// getAccessToken retrieves the GitHub access token from the GITHUB_TOKEN or GH_TOKEN environment variables.
+func getAccessToken() (string, error) {
+	for _, name := range []string{"GITHUB_TOKEN", "GH_TOKEN"} {
+		if token := os.Getenv(name); token != "" {
+			return token, nil
+		}
+	}
+	return "", errors.New("missing GITHUB_TOKEN; set a GitHub personal access token")
+}
+
+// ghConfigDir returns the configuration directory of the gh CLI.
+func ghConfigDir() string {
+	if dir := os.Getenv("GH_CONFIG_DIR"); dir != "" {
+		return dir
+	}
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "gh")
+	}
+	if runtime.GOOS == "windows" {
+		if dir := os.Getenv("AppData"); dir != "" {
+			return filepath.Join(dir, "GitHub CLI")
+		}
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "gh")
+}
+
+// ghCLIToken reads the github.com OAuth token stored in plain text by the gh CLI. Tokens kept in
+// the system keyring are not accessible and are ignored. It returns an empty token when the
+// configuration does not exist.
+func ghCLIToken() (token, path string, err error) {
+	dir := ghConfigDir()
+	if dir == "" {
+		return "", "", nil
+	}
+	path = filepath.Join(dir, "hosts.yml")
+	data, err := os.ReadFile(filepath.Clean(path))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", "", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	type ghUser struct {
+		OAuthToken string `yaml:"oauth_token"`
+	}
+	var hosts map[string]struct {
+		OAuthToken string            `yaml:"oauth_token"`
+		User       string            `yaml:"user"`
+		Users      map[string]ghUser `yaml:"users"`
+	}
+	if err := yaml.Unmarshal(data, &hosts); err != nil {
+		return "", "", fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	host := hosts["github.com"]
+	if host.OAuthToken != "" {
+		return host.OAuthToken, path, nil
+	}
+	if user, ok := host.Users[host.User]; ok && user.OAuthToken != "" {
+		return user.OAuthToken, path, nil
+	}
+	return "", "", nil
+}
+
+// netrcToken returns the password of the api.github.com (or github.com) machine entry in the
+// file named by $NETRC, or ~/.netrc (~/_netrc on Windows).
+func netrcToken() (token, path string, err error) {
+	path = os.Getenv("NETRC")
+	if path == "" {
+		home, homeErr := os.UserHomeDir()
+		if homeErr != nil {
+			return "", "", nil
+		}
+		name := ".netrc"
+		if runtime.GOOS == "windows" {
+			name = "_netrc"
+		}
+		path = filepath.Join(home, name)
+	}
+
+	f, err := os.Open(filepath.Clean(path))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", "", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+	defer func() { _ = f.Close() }()
+
+	passwords := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	scanner.Split(bufio.ScanWords)
+	machine := ""
+	for scanner.Scan() {
+		switch scanner.Text() {
+		case "machine":
+			if scanner.Scan() {
+				machine = scanner.Text()
+			}
+		case "default":
+			machine = ""
+		case "password":
+			if scanner.Scan() && machine != "" {
+				passwords[machine] = scanner.Text()
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	for _, machine := range []string{"api.github.com", "github.com"} {
+		if token := passwords[machine]; token != "" {
+			return token, path, nil
+		}
+	}
+	return "", "", nil
+}
+
+// appTokenSource exchanges a GitHub App JWT for installation access tokens.
+type appTokenSource struct {
+	ctx            context.Context
+	appID          int64
+	key            *rsa.PrivateKey
+	installationID int64
+	apiURL         string
+	httpClient     *http.Client
+	now            func() time.Time
+}
+
+// newAppCredential returns a credential that authenticates as a GitHub App installation.
+// Installation tokens are cached and refreshed automatically shortly before they expire.
+func newAppCredential(ctx context.Context, opts authOptions) (*credential, error) {
+	if opts.appID == 0 || opts.appKeyFile == "" {
+		return nil, errors.New("GitHub App authentication requires both an app ID and a private key file")
+	}
+	keyPEM, err := os.ReadFile(filepath.Clean(opts.appKeyFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GitHub App private key: %w", err)
+	}
+	key, err := parseRSAPrivateKey(keyPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	src := &appTokenSource{
+		ctx:            ctx,
+		appID:          opts.appID,
+		key:            key,
+		installationID: opts.appInstallationID,
+		apiURL:         apiBaseURL(opts.apiURL),
+		httpClient:     http.DefaultClient,
+		now:            time.Now,
+	}
+	return &credential{
+		name:   fmt.Sprintf("GitHub App %d", opts.appID),
+		source: oauth2.ReuseTokenSourceWithExpiry(nil, src, time.Minute),
+	}, nil
+}
+
+// parseRSAPrivateKey parses a PEM encoded PKCS#1 or PKCS#8 RSA private key.
+func parseRSAPrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("GitHub App private key is not PEM encoded")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse GitHub App private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("GitHub App private key is not an RSA key")
+	}
+	return key, nil
+}
+
+// jwt returns a signed RS256 JSON Web Token identifying the App, valid for nine minutes.
+func (s *appTokenSource) jwt() (string, error) {
+	now := s.now()
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, err := json.Marshal(map[string]any{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(), //nolint:mnd
+		"iss": strconv.FormatInt(s.appID, 10),
+	})
+	if err != nil {
+		return "", err
+	}
+	signingInput := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign GitHub App JWT: %w", err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// Token implements oauth2.TokenSource by requesting a fresh installation access token.
+func (s *appTokenSource) Token() (*oauth2.Token, error) {
+	jwt, err := s.jwt()
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient := &http.Client{
+		Transport: &oauth2.Transport{
+			Source: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: jwt}),
+			Base:   s.httpClient.Transport,
+		},
+	}
+	client := github.NewClient(httpClient)
+	baseURL, err := url.Parse(s.apiURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid GitHub API URL: %w", err)
+	}
+	client.BaseURL = baseURL
+
+	installationID := s.installationID
+	if installationID == 0 {
+		installations, _, listErr := client.Apps.ListInstallations(s.ctx, nil)
+		if listErr != nil {
+			return nil, fmt.Errorf("failed to list GitHub App installations: %w", listErr)
+		}
+		if len(installations) != 1 {
+			return nil, fmt.Errorf("GitHub App has %d installations; set the installation ID explicitly", len(installations))
+		}
+		installationID = installations[0].GetID()
+	}
+
+	token, _, err := client.Apps.CreateInstallationToken(s.ctx, installationID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GitHub App installation token: %w", err)
+	}
+	return &oauth2.Token{AccessToken: token.GetToken(), Expiry: token.GetExpiresAt().Time}, nil
+}
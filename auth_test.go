@@ -0,0 +1,259 @@
+// Package main provides the core functionality for updating GitHub star counts in Markdown and AsciiDoc files.
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func singleCredential(t *testing.T, creds []*credential) *credential {
+	t.Helper()
+	if len(creds) != 1 {
+		t.Fatalf("expected exactly one credential, got %d", len(creds))
+	}
+	return creds[0]
+}
+
+func credentialToken(t *testing.T, cred *credential) string {
+	t.Helper()
+	if cred == nil {
+		t.Fatal("expected a credential, got nil")
+	}
+	token, err := cred.source.Token()
+	if err != nil {
+		t.Fatalf("unexpected token error: %v", err)
+	}
+	return token.AccessToken
+}
+
+func TestResolveCredentialChain(t *testing.T) {
+	tests := []struct {
+		name      string
+		setup     func(t *testing.T, dir string) authOptions
+		wantToken string
+		wantName  string
+	}{
+		{
+			name: "Token file wins over environment",
+			setup: func(t *testing.T, dir string) authOptions {
+				t.Setenv("GITHUB_TOKEN", "env_token")
+				path := filepath.Join(dir, "token")
+				writeFile(t, path, "\n  file_token  \n")
+				return authOptions{tokenFile: path}
+			},
+			wantToken: "file_token",
+			wantName:  "-token-file",
+		},
+		{
+			name: "GH_TOKEN used when GITHUB_TOKEN is empty",
+			setup: func(t *testing.T, _ string) authOptions {
+				t.Setenv("GH_TOKEN", "gh_env_token")
+				return authOptions{}
+			},
+			wantToken: "gh_env_token",
+			wantName:  "GH_TOKEN",
+		},
+		{
+			name: "gh CLI hosts.yml",
+			setup: func(t *testing.T, dir string) authOptions {
+				writeFile(t, filepath.Join(dir, "gh", "hosts.yml"), "github.com:\n    oauth_token: gho_cli\n    user: me\n")
+				writeFile(t, filepath.Join(dir, ".netrc"), "machine api.github.com login me password netrc_token\n")
+				return authOptions{}
+			},
+			wantToken: "gho_cli",
+			wantName:  "gh CLI config",
+		},
+		{
+			name: "gh CLI multi-account hosts.yml",
+			setup: func(t *testing.T, dir string) authOptions {
+				writeFile(t, filepath.Join(dir, "gh", "hosts.yml"),
+					"github.com:\n    user: me\n    users:\n        me:\n            oauth_token: gho_user\n")
+				return authOptions{}
+			},
+			wantToken: "gho_user",
+			wantName:  "gh CLI config",
+		},
+		{
+			name: "netrc",
+			setup: func(t *testing.T, dir string) authOptions {
+				writeFile(t, filepath.Join(dir, ".netrc"),
+					"machine example.com login x password nope\nmachine github.com\n  login me\n  password netrc_token\n")
+				return authOptions{}
+			},
+			wantToken: "netrc_token",
+			wantName:  "netrc",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := isolateAuthEnv(t)
+			opts := tt.setup(t, dir)
+
+			creds, err := resolveCredentials(context.Background(), opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			cred := singleCredential(t, creds)
+			if got := credentialToken(t, cred); got != tt.wantToken {
+				t.Errorf("expected token %q, got %q", tt.wantToken, got)
+			}
+			if !strings.HasPrefix(cred.name, tt.wantName) {
+				t.Errorf("expected credential name starting with %q, got %q", tt.wantName, cred.name)
+			}
+			if strings.Contains(cred.name, tt.wantToken) {
+				t.Errorf("credential name %q leaks the token", cred.name)
+			}
+		})
+	}
+}
+
+func TestResolveCredentialAnonymous(t *testing.T) {
+	isolateAuthEnv(t)
+
+	creds, err := resolveCredentials(context.Background(), authOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(creds) != 0 {
+		t.Errorf("expected anonymous mode, got %d credentials", len(creds))
+	}
+}
+
+func TestResolveMultipleCredentials(t *testing.T) {
+	dir := isolateAuthEnv(t)
+	t.Setenv("GITHUB_TOKEN", "single")
+
+	t.Setenv("GITHUB_TOKENS", "one, two\nthree")
+	creds, err := resolveCredentials(context.Background(), authOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(creds) != 3 || creds[1].name != "GITHUB_TOKENS #2" || credentialToken(t, creds[1]) != "two" {
+		t.Errorf("unexpected credentials from GITHUB_TOKENS: %d", len(creds))
+	}
+
+	path := filepath.Join(dir, "tokens")
+	writeFile(t, path, "a\n\nb\n")
+	creds, err = resolveCredentials(context.Background(), authOptions{tokenFile: path})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(creds) != 2 || credentialToken(t, creds[0]) != "a" || credentialToken(t, creds[1]) != "b" {
+		t.Errorf("unexpected credentials from token file: %d", len(creds))
+	}
+}
+
+func TestResolveCredentialMissingTokenFile(t *testing.T) {
+	dir := isolateAuthEnv(t)
+
+	_, err := resolveCredentials(context.Background(), authOptions{tokenFile: filepath.Join(dir, "missing")})
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
+
+func TestGitHubAppCredential(t *testing.T) {
+	dir := isolateAuthEnv(t)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(dir, "app.pem")
+	writeFile(t, keyPath, string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})))
+
+	var issued atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/app/installations", func(w http.ResponseWriter, r *http.Request) {
+		verifyAppJWT(t, r, &key.PublicKey)
+		_, _ = fmt.Fprint(w, `[{"id": 77}]`)
+	})
+	mux.HandleFunc("/app/installations/77/access_tokens", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("expected POST, got %s", r.Method)
+		}
+		verifyAppJWT(t, r, &key.PublicKey)
+		n := issued.Add(1)
+		// The first token is already about to expire, so the next call must refresh it.
+		expires := time.Now().Add(30 * time.Second)
+		if n > 1 {
+			expires = time.Now().Add(time.Hour)
+		}
+		w.WriteHeader(http.StatusCreated)
+		_, _ = fmt.Fprintf(w, `{"token": "ghs_%d", "expires_at": %q}`, n, expires.UTC().Format(time.RFC3339))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	t.Setenv("GITHUB_TOKEN", "ignored")
+	t.Setenv("GITHUB_APP_ID", "12345")
+	t.Setenv("GITHUB_APP_PRIVATE_KEY_FILE", keyPath)
+
+	creds, err := resolveCredentials(context.Background(), authOptions{apiURL: server.URL})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cred := singleCredential(t, creds)
+	if cred.name != "GitHub App 12345" {
+		t.Errorf("unexpected credential name %q", cred.name)
+	}
+	if got := credentialToken(t, cred); got != "ghs_1" {
+		t.Errorf("expected ghs_1, got %q", got)
+	}
+	if got := credentialToken(t, cred); got != "ghs_2" {
+		t.Errorf("expected refreshed token ghs_2, got %q", got)
+	}
+	if got := credentialToken(t, cred); got != "ghs_2" {
+		t.Errorf("expected cached token ghs_2, got %q", got)
+	}
+}
+
+func verifyAppJWT(t *testing.T, r *http.Request, pub *rsa.PublicKey) {
+	t.Helper()
+	jwt, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		t.Errorf("missing bearer JWT")
+		return
+	}
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		t.Errorf("malformed JWT %q", jwt)
+		return
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		t.Errorf("bad JWT signature encoding: %v", err)
+		return
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], signature); err != nil {
+		t.Errorf("invalid JWT signature: %v", err)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		t.Errorf("bad JWT payload encoding: %v", err)
+		return
+	}
+	var claims struct {
+		Iss string `json:"iss"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Iss != "12345" {
+		t.Errorf("unexpected JWT claims %s", payload)
+	}
+}
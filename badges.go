@@ -0,0 +1,115 @@
+// Package main provides the core functionality for updating GitHub star counts in Markdown and AsciiDoc files.
+package main
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/stn1slv/github-markdown-stars-updater/pkg/stars"
+)
+
+// Supported values for -style.
+const (
+	styleLabel   = "label"   // the label in the link text
+	styleShields = "shields" // a shields.io badge with the count baked in
+	styleSVG     = "svg"     // a badge generated into the badge directory
+)
+
+// defaultBadgeDir is the directory the SVG badges are written to.
+const defaultBadgeDir = "badges"
+
+// documentImages are the image files generated for one document, such as SVG badges and
+// sparklines. They are kept in memory, keyed by path, until write writes them, so that a dry run
+// writes nothing.
+type documentImages struct {
+	base  string // directory of the document, which image references are relative to
+	files map[string][]byte
+}
+
+// newDocumentImages returns the images of the document written to output.
+func newDocumentImages(output string) *documentImages {
+	return &documentImages{base: filepath.Dir(output), files: make(map[string][]byte)}
+}
+
+// add keeps the image at path and returns the reference to it from the document.
+func (d *documentImages) add(path string, data []byte) string {
+	d.files[path] = data
+	ref := path
+	if rel, err := filepath.Rel(d.base, path); err == nil {
+		ref = rel
+	}
+	return filepath.ToSlash(ref)
+}
+
+// write writes the images. Images whose content did not change are left untouched.
+func (d *documentImages) write() error {
+	for path, data := range d.files {
+		if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil { //nolint:mnd
+			return fmt.Errorf("writing image: %w", err)
+		}
+		if _, err := writeFileAtomic(path, data, false); err != nil {
+			return fmt.Errorf("writing image %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// repoImagePath returns the path of the image of repo, a repository URL or "owner/repo", in dir,
+// as <dir>/<owner>/<repo>.svg.
+func repoImagePath(dir, repo string) string {
+	return filepath.Join(dir, filepath.FromSlash(stars.RepoKey(repo))+".svg")
+}
+
+// badgeFunc returns the BadgeFunc of the -style option, or nil for text labels. SVG badges are
+// added to images.
+func badgeFunc(opts *options, images *documentImages) stars.BadgeFunc {
+	switch opts.style {
+	case styleShields:
+		return stars.ShieldsBadge
+	case styleSVG:
+		return func(repoURL, label string) string {
+			return images.add(repoImagePath(opts.badgeDir, repoURL), renderBadge("stars", stars.BadgeMessage(label)))
+		}
+	default:
+		return nil
+	}
+}
+
+// renderBadge renders a flat two-part badge in the style of shields.io, with the subject on grey
+// and the message on blue. Text widths are estimated, as no font metrics are available.
+func renderBadge(subject, message string) []byte {
+	left, right := textWidth(subject)+10, textWidth(message)+10 //nolint:mnd
+	width := left + right
+	title := html.EscapeString(subject + ": " + message)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="20" role="img" aria-label="%s">`+"\n", width, title)
+	fmt.Fprintf(&b, "<title>%s</title>\n", title)
+	b.WriteString(`<linearGradient id="s" x2="0" y2="100%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>` + "\n")
+	fmt.Fprintf(&b, `<clipPath id="r"><rect width="%d" height="20" rx="3" fill="#fff"/></clipPath>`+"\n", width)
+	fmt.Fprintf(&b, `<g clip-path="url(#r)"><rect width="%d" height="20" fill="#555"/><rect x="%d" width="%d" height="20" fill="#007ec6"/><rect width="%d" height="20" fill="url(#s)"/></g>`+"\n",
+		left, left, right, width)
+	b.WriteString(`<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">` + "\n")
+	fmt.Fprintf(&b, "<text x=\"%.1f\" y=\"14\">%s</text>\n", float64(left)/2, html.EscapeString(subject))                //nolint:mnd
+	fmt.Fprintf(&b, "<text x=\"%.1f\" y=\"14\">%s</text>\n", float64(left)+float64(right)/2, html.EscapeString(message)) //nolint:mnd
+	b.WriteString("</g>\n</svg>\n")
+	return []byte(b.String())
+}
+
+// textWidth estimates the width in pixels of s in 11px Verdana: 7px per ASCII character and 12px
+// for other characters such as arrows and emoji.
+func textWidth(s string) int {
+	width := 0
+	for _, r := range s {
+		if r < utf8.RuneSelf {
+			width += 7 //nolint:mnd
+		} else {
+			width += 12 //nolint:mnd
+		}
+	}
+	return width
+}
@@ -0,0 +1,78 @@
+// Package main provides the core functionality for updating GitHub star counts in Markdown and AsciiDoc files.
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderBadge(t *testing.T) {
+	svg := renderBadge("stars", "1.3k ↑140 <30d>")
+	if err := xml.Unmarshal(svg, new(struct{})); err != nil {
+		t.Fatalf("invalid SVG: %v\n%s", err, svg)
+	}
+	for _, want := range []string{`width="165"`, "<title>stars: 1.3k ↑140 &lt;30d&gt;</title>", `<text x="22.5" y="14">stars</text>`} {
+		if !bytes.Contains(svg, []byte(want)) {
+			t.Errorf("expected %q in the badge:\n%s", want, svg)
+		}
+	}
+}
+
+func TestRunWithBadges(t *testing.T) {
+	isolateAuthEnv(t)
+	dir := t.TempDir()
+	t.Chdir(dir)
+	writeFile(t, "stars.json", `{"o/a": 1500, "up/r": 20000}`)
+	if err := os.Mkdir("docs", 0o750); err != nil {
+		t.Fatal(err)
+	}
+	doc := filepath.Join("docs", "list.md")
+	original := "- [A (⭐1)](https://github.com/o/a)\n- [Mono](https://github.com/m/mono) <!-- stars: up/r -->\n"
+	writeFile(t, doc, original)
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"-offline", "-fixture", "stars.json", "-style", "svg", "-badge-dir", "assets/badges"}
+	if code := run(append(args, "-dry-run", doc), &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	if _, err := os.Stat("assets"); !os.IsNotExist(err) {
+		t.Errorf("a dry run must not write badges, got %v", err)
+	}
+
+	stdout.Reset()
+	if code := run(append(args, doc), &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	expected := "- [A](https://github.com/o/a) ![⭐1.5k](../assets/badges/o/a.svg)\n" +
+		"- [Mono](https://github.com/m/mono) <!-- stars: up/r --> ![⭐20k](../assets/badges/up/r.svg)\n"
+	if got := mustRead(t, doc); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+	if svg := mustRead(t, filepath.Join("assets", "badges", "up", "r.svg")); !strings.Contains(svg, ">20k</text>") {
+		t.Errorf("unexpected badge:\n%s", svg)
+	}
+
+	// Shields badges replace the SVG badges, and text labels replace the badges again.
+	if code := run([]string{"-offline", "-fixture", "stars.json", "-style", "shields", doc}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	if got := mustRead(t, doc); !strings.Contains(got, "[A](https://github.com/o/a) ![⭐1.5k](https://img.shields.io/badge/stars-1.5k-blue)\n") {
+		t.Errorf("expected a shields.io badge, got %q", got)
+	}
+	if code := run([]string{"-offline", "-fixture", "stars.json", doc}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	expected = "- [A (⭐1.5k)](https://github.com/o/a)\n- [Mono (⭐20k)](https://github.com/m/mono) <!-- stars: up/r -->\n"
+	if got := mustRead(t, doc); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+
+	stderr.Reset()
+	if code := run([]string{"-offline", "-fixture", "stars.json", "-style", "png", doc}, &stdout, &stderr); code != 1 || !strings.Contains(stderr.String(), `unknown -style "png"`) {
+		t.Errorf("expected exit code 1 for an unknown style, got %d: %s", code, stderr.String())
+	}
+}
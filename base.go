@@ -0,0 +1,79 @@
+// Package main provides the core functionality for updating GitHub star counts in Markdown and AsciiDoc files.
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// readBase returns the content of filePath in the base version it is compared against: the file
+// given by -base-file, or the file at the git revision given by -base. A file that does not exist
+// at the base revision yields empty content, so all of its links count as new.
+func readBase(ctx context.Context, filePath string, opts *options) (string, error) {
+	if opts.baseFile != "" {
+		data, err := os.ReadFile(filepath.Clean(opts.baseFile))
+		if err != nil {
+			return "", fmt.Errorf("reading base file: %w", err)
+		}
+		return string(data), nil
+	}
+
+	// "rev:./name" is resolved relative to the directory git runs in, so the file may live
+	// anywhere in the work tree. Whether the file exists is asked with its own command rather than
+	// read from the error message of git show, which depends on the locale.
+	dir, object := filepath.Dir(filePath), opts.baseRev+":./"+filepath.Base(filePath)
+	if _, err := git(ctx, dir, "rev-parse", "--verify", "--end-of-options", opts.baseRev+"^{commit}"); err != nil {
+		return "", fmt.Errorf("reading %s at %s: %w", filePath, opts.baseRev, err)
+	}
+	if err := exec.CommandContext(ctx, "git", "-C", dir, "cat-file", "-e", object).Run(); err != nil { //nolint:gosec
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", nil
+		}
+		return "", fmt.Errorf("reading %s at %s: %w", filePath, opts.baseRev, err)
+	}
+	out, err := git(ctx, dir, "show", object)
+	if err != nil {
+		return "", fmt.Errorf("reading %s at %s: %w", filePath, opts.baseRev, err)
+	}
+	return string(out), nil
+}
+
+// git runs git with args in dir and returns its output. Errors carry the message git printed.
+func git(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...) //nolint:gosec
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, errors.New(msg)
+		}
+		return nil, err
+	}
+	return out, nil
+}
+
+// newRepos returns the links of repos that are not in the base version: repositories the base
+// does not link to at all, and links whose URL changed, e.g. to a different path of the same
+// repository. The links are returned in document order.
+func newRepos(repos, baseRepos []string) []string {
+	baseURLs := make(map[string]bool, len(baseRepos))
+	for _, repoURL := range baseRepos {
+		baseURLs[repoURL] = true
+	}
+
+	var added []string
+	for _, repoURL := range repos {
+		if !baseURLs[repoURL] {
+			added = append(added, repoURL)
+		}
+	}
+	return added
+}
@@ -0,0 +1,101 @@
+// Package main provides the core functionality for updating GitHub star counts in Markdown and AsciiDoc files.
+package main
+
+import (
+	"bytes"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestNewRepos(t *testing.T) {
+	base := []string{"https://github.com/o/kept", "https://github.com/o/moved"}
+	repos := []string{
+		"https://github.com/o/kept",
+		"https://github.com/o/moved/tree/main/sub",
+		"https://github.com/o/added",
+	}
+	expected := []string{"https://github.com/o/moved/tree/main/sub", "https://github.com/o/added"}
+	if got := newRepos(repos, base); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestRunBaseFile(t *testing.T) {
+	isolateAuthEnv(t)
+	t.Setenv("GITHUB_TOKEN", "test_token")
+	server := newFakeAPI(t, map[string]int{"owner/a": 1500, "owner/b": 5})
+
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base.md")
+	writeFile(t, base, "- [A](https://github.com/owner/a)\n")
+	doc := filepath.Join(dir, "list.md")
+	writeFile(t, doc, "- [A](https://github.com/owner/a)\n- [B](https://github.com/owner/b)\n- [Gone](https://github.com/owner/gone)\n")
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"-api-url", server.URL, "-base-file", base, "-policy-min-stars", "50", doc}
+	if code := run(args, &stdout, &stderr); code != 1 {
+		t.Fatalf("expected exit code 1, got %d: %s", code, stderr.String())
+	}
+	expected := doc + ":2:7: owner/b (⭐5)\n" +
+		doc + ":3:10: owner/gone could not be fetched\n" +
+		"2 new repository link(s) checked.\n" +
+		doc + ":2:7: owner/b has 5 stars, fewer than the required 50 (min-stars)\n" +
+		doc + ":3:10: owner/gone could not be fetched (unreachable)\n" +
+		"2 policy violation(s) found.\n"
+	if stdout.String() != expected {
+		t.Errorf("expected %q, got %q", expected, stdout.String())
+	}
+	if server.requests.Load() != 2 {
+		t.Errorf("expected only the new links to be fetched, got %d API requests", server.requests.Load())
+	}
+}
+
+func TestRunBaseRevision(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+	isolateAuthEnv(t)
+	t.Setenv("GITHUB_TOKEN", "test_token")
+	server := newFakeAPI(t, map[string]int{"owner/a": 1500, "owner/b": 500})
+
+	dir := t.TempDir()
+	gitRun := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	doc := filepath.Join(dir, "docs", "list.md")
+	writeFile(t, doc, "- [A](https://github.com/owner/a)\n")
+	gitRun("init", "-q")
+	gitRun("add", ".")
+	gitRun("commit", "-q", "-m", "base")
+	writeFile(t, doc, "- [A](https://github.com/owner/a)\n- [B](https://github.com/owner/b)\n")
+	added := filepath.Join(dir, "docs", "new.md")
+	writeFile(t, added, "- [A](https://github.com/owner/a)\n")
+
+	// The file missing at the base is recognised whatever language git reports errors in.
+	t.Setenv("LANGUAGE", "de")
+	t.Setenv("LC_ALL", "de_DE.UTF-8")
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"-api-url", server.URL, "-base", "HEAD", "-policy-min-stars", "50", doc, added}
+	if code := run(args, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s%s", code, stdout.String(), stderr.String())
+	}
+	expected := doc + ":2:7: owner/b (⭐500)\n" +
+		added + ":1:7: owner/a (⭐1.5k)\n" +
+		"2 new repository link(s) checked.\n" +
+		"No policy violations found.\n"
+	if stdout.String() != expected {
+		t.Errorf("expected %q, got %q", expected, stdout.String())
+	}
+
+	stdout.Reset()
+	if code := run([]string{"-api-url", server.URL, "-base", "no-such-rev", doc}, &stdout, &stderr); code != 1 {
+		t.Errorf("expected exit code 1 for an unknown revision, got %d", code)
+	}
+}
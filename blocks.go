@@ -0,0 +1,222 @@
+// Package main provides the core functionality for updating GitHub star counts in Markdown and AsciiDoc files.
+package main
+
+import (
+	"fmt"
+	"iter"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/stn1slv/github-markdown-stars-updater/pkg/stars"
+)
+
+// Generated blocks are delimited by a start marker naming the block and an end marker, e.g.
+// "<!-- stars:summary -->" and "<!-- stars:end -->" in Markdown, or "// stars:summary" and
+// "// stars:end" comments in AsciiDoc. The lines between the markers are regenerated on each run.
+const blockEnd = "end"
+
+var (
+	markdownMarkerRe = regexp.MustCompile(`^[ \t]*<!--[ \t]*stars:([a-z-]+)\b(.*?)[ \t]*-->[ \t]*$`)
+	asciidocMarkerRe = regexp.MustCompile(`^[ \t]*//[ \t]*stars:([a-z-]+)\b(.*?)[ \t]*$`)
+)
+
+// generatedBlock is a block between a start and an end marker.
+type generatedBlock struct {
+	name    string // the block name of the start marker, e.g. "summary"
+	args    string // the rest of the start marker, e.g. `10 section="Tools"`
+	line    int    // line of the start marker, 1-based
+	endLine int    // line of the end marker
+	start   int    // offset of the first generated line
+	end     int    // offset of the end marker line
+}
+
+// contains reports whether line is one of the lines of the block, markers included.
+func (b generatedBlock) contains(line int) bool {
+	return line >= b.line && line <= b.endLine
+}
+
+// findBlocks returns the generated blocks with one of the given names in content. Markers in code
+// blocks are ignored. A start marker without an end marker, or nested blocks, are an error.
+func findBlocks(content string, format stars.Format, names ...string) ([]generatedBlock, error) {
+	markerRe := markdownMarkerRe
+	endMarker := "<!-- stars:end -->"
+	if format == stars.FormatASCIIDoc {
+		markerRe = asciidocMarkerRe
+		endMarker = "// stars:end"
+	}
+
+	var blocks []generatedBlock
+	var open *generatedBlock
+	for line := range textLines(content, format) {
+		match := markerRe.FindStringSubmatch(line.text)
+		if match == nil {
+			continue
+		}
+		switch name := match[1]; {
+		case name == blockEnd && open != nil:
+			open.endLine, open.end = line.number, line.offset
+			blocks = append(blocks, *open)
+			open = nil
+		case name == blockEnd, !slices.Contains(names, name):
+			// A stray end marker, or another directive such as stars:stale-after.
+		case open != nil:
+			return nil, fmt.Errorf("line %d: stars:%s block inside the stars:%s block of line %d", line.number, name, open.name, open.line)
+		default:
+			open = &generatedBlock{name: name, args: strings.TrimSpace(match[2]), line: line.number, start: line.next}
+		}
+	}
+	if open != nil {
+		return nil, fmt.Errorf("line %d: stars:%s block without a closing %s", open.line, open.name, endMarker)
+	}
+	return blocks, nil
+}
+
+// refreshBlocks regenerates the summary tables and leaderboards between "stars:summary" or
+// "stars:top" and "stars:end" markers in content from repos, the FindRepos result for content, and
+// the repository data in infos. The labels of generated links are left to the update.
+func refreshBlocks(content string, format stars.Format, layout textLayout, repos []string, infos map[string]repoInfo, exclude []string) (string, error) {
+	blocks, err := findBlocks(content, format, blockSummary, blockTop)
+	if err != nil || len(blocks) == 0 {
+		return content, err
+	}
+	return replaceBlocks(content, blocks, layout, func(block generatedBlock) (string, error) {
+		if block.name == blockSummary {
+			sections, total := buildSections(content, format, outsideBlocks(stars.Locate(content, repos), blocks), infos, exclude)
+			return summaryTable(sections, total, format), nil
+		}
+		links, err := stars.FindLinks(content, format)
+		if err != nil {
+			return "", err
+		}
+		return leaderboard(content, format, block.args, links, blocks, infos, exclude)
+	})
+}
+
+// documentLocations returns the positions of the links in repos, the FindRepos result for
+// content, leaving out the links that generated blocks repeat.
+func documentLocations(content string, format stars.Format, repos []string) ([]stars.Location, error) {
+	blocks, err := findBlocks(content, format, blockSummary, blockTop)
+	if err != nil {
+		return nil, err
+	}
+	return outsideBlocks(stars.Locate(content, repos), blocks), nil
+}
+
+// linkLocations returns the positions of the repository links of content, leaving out the links
+// that generated blocks repeat. Each link is located at its URL and keyed by the repository it
+// links to, whatever its annotation makes the label show, so that pinned, hidden and redirected
+// links are listed and checked like any other.
+func linkLocations(content string, format stars.Format) ([]stars.Location, error) {
+	links, err := stars.FindLinks(content, format)
+	if err != nil {
+		return nil, err
+	}
+	blocks, err := findBlocks(content, format, blockSummary, blockTop)
+	if err != nil {
+		return nil, err
+	}
+	locations := make([]stars.Location, 0, len(links))
+	for _, link := range links {
+		key, ok := stars.NormalizeRepoURL(link.URL)
+		if !ok || slices.ContainsFunc(blocks, func(b generatedBlock) bool { return b.contains(link.Line) }) {
+			continue
+		}
+		locations = append(locations, stars.Location{URL: link.URL, Key: key, Line: link.Line, Column: link.Column})
+	}
+	return locations, nil
+}
+
+// outsideBlocks returns the locations that are not inside one of blocks.
+func outsideBlocks(locations []stars.Location, blocks []generatedBlock) []stars.Location {
+	if len(blocks) == 0 {
+		return locations
+	}
+	return slices.DeleteFunc(locations, func(loc stars.Location) bool {
+		return slices.ContainsFunc(blocks, func(b generatedBlock) bool { return b.contains(loc.Line) })
+	})
+}
+
+// replaceBlocks returns content with the lines of every block replaced by the text render returns
+// for it. The text is written with "\n" line breaks and ends with one unless it is empty; it gets
+// the line breaks of the document's layout.
+func replaceBlocks(content string, blocks []generatedBlock, layout textLayout, render func(generatedBlock) (string, error)) (string, error) {
+	var b strings.Builder
+	last := 0
+	for _, block := range blocks {
+		text, err := render(block)
+		if err != nil {
+			return "", fmt.Errorf("line %d: %w", block.line, err)
+		}
+		b.WriteString(content[last:block.start])
+		b.WriteString(layout.lines(text))
+		last = block.end
+	}
+	b.WriteString(content[last:])
+	return b.String(), nil
+}
+
+// textLine is a line of a document, without its line break.
+type textLine struct {
+	text   string
+	number int // 1-based
+	offset int // offset of the line in the document
+	next   int // offset of the following line
+}
+
+// textLines yields the lines of content outside code blocks: fenced code in Markdown, and listing,
+// literal, passthrough and comment blocks in AsciiDoc.
+func textLines(content string, format stars.Format) iter.Seq[textLine] {
+	return func(yield func(textLine) bool) {
+		fence := ""
+		for offset, number := 0, 1; offset < len(content); number++ {
+			next := len(content)
+			if i := strings.IndexByte(content[offset:], '\n'); i >= 0 {
+				next = offset + i + 1
+			}
+			text := strings.TrimRight(content[offset:next], "\r\n")
+			line := textLine{text: text, number: number, offset: offset, next: next}
+			offset = next
+
+			if fence != "" {
+				if closesFence(text, fence, format) {
+					fence = ""
+				}
+				continue
+			}
+			if fence = opensFence(text, format); fence != "" {
+				continue
+			}
+			if !yield(line) {
+				return
+			}
+		}
+	}
+}
+
+// opensFence returns the delimiter of the code block that text opens, or "".
+func opensFence(text string, format stars.Format) string {
+	trimmed := strings.TrimSpace(text)
+	for _, c := range []string{"`", "~"} {
+		if strings.HasPrefix(trimmed, strings.Repeat(c, 3)) { //nolint:mnd
+			return trimmed[:len(trimmed)-len(strings.TrimLeft(trimmed, c))]
+		}
+	}
+	if format == stars.FormatASCIIDoc && len(trimmed) >= 4 { //nolint:mnd
+		for _, c := range []string{"-", ".", "+", "/"} {
+			if strings.Trim(trimmed, c) == "" {
+				return trimmed
+			}
+		}
+	}
+	return ""
+}
+
+// closesFence reports whether text closes the code block opened with fence.
+func closesFence(text, fence string, format stars.Format) bool {
+	trimmed := strings.TrimSpace(text)
+	if format == stars.FormatASCIIDoc && !strings.HasPrefix(fence, "`") && !strings.HasPrefix(fence, "~") {
+		return trimmed == fence
+	}
+	return strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == ""
+}
@@ -0,0 +1,125 @@
+// Package main provides the core functionality for updating GitHub star counts in Markdown and AsciiDoc files.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// defaultCacheTTL is how long cached values are reused when no TTL is configured.
+const defaultCacheTTL = 24 * time.Hour
+
+// cacheEntry is a cached value and the time it was fetched. Repository entries fetched for the
+// freshness fields also carry the repository metadata.
+type cacheEntry struct {
+	Value     int       `json:"value"`
+	FetchedAt time.Time `json:"fetched_at"`
+	Info      *repoInfo `json:"info,omitempty"`
+}
+
+// fetchCache stores fetched values between runs. Star counts are keyed by the canonical
+// "owner/repo" key and package metrics by "registry:name".
+type fetchCache struct {
+	path    string
+	ttl     time.Duration
+	now     func() time.Time
+	entries map[string]cacheEntry
+	dirty   bool
+}
+
+// loadCache reads the cache file at path. A missing file yields an empty cache.
+func loadCache(path string, ttl time.Duration) (*fetchCache, error) {
+	if ttl == 0 {
+		ttl = defaultCacheTTL
+	}
+	c := &fetchCache{path: path, ttl: ttl, now: time.Now, entries: make(map[string]cacheEntry)}
+
+	data, err := os.ReadFile(filepath.Clean(path))
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var file struct {
+		Entries map[string]cacheEntry `json:"entries"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse cache %s: %w", path, err)
+	}
+	if file.Entries != nil {
+		c.entries = file.Entries
+	}
+	return c, nil
+}
+
+// get returns the cached value for key if it is younger than the TTL. A nil cache never hits.
+func (c *fetchCache) get(key string) (int, bool) {
+	if c == nil {
+		return 0, false
+	}
+	entry, ok := c.entries[key]
+	if !ok || c.now().Sub(entry.FetchedAt) > c.ttl {
+		return 0, false
+	}
+	return entry.Value, true
+}
+
+// getInfo returns the cached repository metadata for key if it is younger than the TTL. Entries
+// cached without metadata miss.
+func (c *fetchCache) getInfo(key string) (repoInfo, bool) {
+	if c == nil {
+		return repoInfo{}, false
+	}
+	entry, ok := c.entries[key]
+	if !ok || entry.Info == nil || c.now().Sub(entry.FetchedAt) > c.ttl {
+		return repoInfo{}, false
+	}
+	return *entry.Info, true
+}
+
+// putInfo stores the star count and metadata of the repository key. It is a no-op on a nil cache.
+func (c *fetchCache) putInfo(key string, info repoInfo) {
+	if c == nil {
+		return
+	}
+	c.entries[key] = cacheEntry{Value: info.Stars, FetchedAt: c.now().UTC(), Info: &info}
+	c.dirty = true
+}
+
+// put stores value for key. It is a no-op on a nil cache.
+func (c *fetchCache) put(key string, value int) {
+	if c == nil {
+		return
+	}
+	c.entries[key] = cacheEntry{Value: value, FetchedAt: c.now().UTC()}
+	c.dirty = true
+}
+
+// save writes the cache back to disk if anything changed.
+func (c *fetchCache) save() error {
+	if c == nil || !c.dirty {
+		return nil
+	}
+	data, err := json.MarshalIndent(struct {
+		Entries map[string]cacheEntry `json:"entries"`
+	}{c.entries}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(c.path); dir != "" {
+		if err := os.MkdirAll(dir, 0o750); err != nil {
+			return err
+		}
+	}
+	if err := os.WriteFile(c.path, append(data, '\n'), 0o600); err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
@@ -0,0 +1,230 @@
+// Package main provides the core functionality for updating GitHub star counts in Markdown and AsciiDoc files.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+)
+
+// Names of the subcommands.
+const (
+	commandUpdate   = "update"   // update the star counts in the documents
+	commandCheck    = "check"    // check the linked repositories against the policy
+	commandList     = "list"     // list the repository links without fetching
+	commandFetch    = "fetch"    // fetch the repository data into the cache or a snapshot
+	commandApply    = "apply"    // update the documents from a snapshot only
+	commandReport   = "report"   // print statistics about the linked repositories
+	commandSections = "sections" // print statistics per document section
+	commandAction   = "action"   // update the documents and publish them from GitHub Actions
+)
+
+// flagGroup is a set of related flags that commands accept together.
+type flagGroup uint
+
+const (
+	flagsInput      flagGroup = 1 << iota // -config, -format, -exclude
+	flagsSource                           // where repository data comes from: API, credentials, cache, fixture
+	flagsLabel                            // -label, the stale marker and the badge style
+	flagsRender                           // other label content: thresholds, history, trends, sparklines, metrics
+	flagsWrite                            // -out, -dry-run, -backup
+	flagsPolicy                           // policy rules, pull request mode and SARIF
+	flagsDuplicates                       // -duplicates
+	flagsSnapshot                         // -snapshot
+	flagsReport                           // -top
+	flagsOutput                           // -output
+	flagsWatch                            // -watch and its timing
+	flagsAction                           // how the action command publishes the documents
+	flagsLegacy                           // -policy and -version of invocations without a command
+)
+
+// command is a subcommand of the CLI and the flags it accepts.
+type command struct {
+	name    string
+	summary string
+	flags   flagGroup
+}
+
+// commands lists the subcommands in the order shown by the usage message.
+var commands = []command{
+	{commandUpdate, "update the star counts in the documents",
+		flagsInput | flagsSource | flagsLabel | flagsRender | flagsWrite | flagsDuplicates | flagsWatch},
+	{commandCheck, "check the linked repositories against the policy rules in CI, without modifying the documents",
+		flagsInput | flagsSource | flagsPolicy | flagsDuplicates},
+	{commandList, "list the repository links of the documents with their position, without fetching",
+		flagsInput},
+	{commandFetch, "fetch the repository data of the documents into the cache or a snapshot file",
+		flagsInput | flagsSource | flagsLabel | flagsSnapshot},
+	{commandApply, "update the documents from a snapshot file, without network access or credentials",
+		flagsInput | flagsLabel | flagsRender | flagsWrite | flagsSnapshot},
+	{commandReport, "print statistics about the linked repositories",
+		flagsInput | flagsSource | flagsLabel | flagsReport | flagsOutput},
+	{commandSections, "print the repositories, stars and top project of every section of the documents",
+		flagsInput | flagsSource | flagsOutput},
+	{commandAction, "update the documents in GitHub Actions and commit them or open a pull request",
+		flagsInput | flagsSource | flagsLabel | flagsRender | flagsDuplicates | flagsAction},
+}
+
+// legacyCommand parses invocations that do not start with a command name, as before subcommands
+// existed: the documents are updated, or checked with -policy, -base or -base-file.
+var legacyCommand = command{
+	flags: flagsInput | flagsSource | flagsLabel | flagsRender | flagsWrite | flagsPolicy | flagsDuplicates | flagsWatch | flagsLegacy,
+}
+
+// lookupCommand returns the command named by the first argument and the remaining arguments, or
+// legacyCommand and all arguments when the first argument is not a command name.
+func lookupCommand(args []string) (command, []string) {
+	if len(args) > 0 {
+		for _, cmd := range commands {
+			if cmd.name == args[0] {
+				return cmd, args[1:]
+			}
+		}
+	}
+	return legacyCommand, args
+}
+
+// flagValues holds the flags that are not stored in options directly.
+type flagValues struct {
+	configPath  string
+	showVersion bool
+	excludeList string
+	licenseList string
+}
+
+// newFlagSet returns the flag set of cmd, storing the parsed values in opts and values.
+func newFlagSet(cmd command, opts *options, values *flagValues, stderr io.Writer) *flag.FlagSet {
+	name := programName
+	if cmd.name != "" {
+		name += " " + cmd.name
+	}
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		if cmd.name == "" {
+			writeUsage(stderr)
+			_, _ = fmt.Fprintln(stderr, "\nFlags without a command:")
+		} else {
+			_, _ = fmt.Fprintf(stderr, "Usage: %s [flags] <path_to_file>...\n\n%s.\n\nFlags:\n", name, capitalize(cmd.summary))
+		}
+		fs.PrintDefaults()
+	}
+	has := func(group flagGroup) bool { return cmd.flags&group != 0 }
+
+	if has(flagsLegacy) {
+		fs.BoolVar(&values.showVersion, "version", false, "show version info and exit")
+		fs.BoolVar(&opts.checkPolicy, "policy", false, "check the linked repositories against the policy rules instead of updating the documents")
+	}
+	if has(flagsInput) {
+		fs.StringVar(&values.configPath, "config", "", "config file (defaults to .stars-updater.yaml in the working directory or its parents)")
+		fs.StringVar(&opts.format, "format", "", "treat all inputs as markdown or asciidoc regardless of their extension")
+		fs.StringVar(&values.excludeList, "exclude", "", "comma-separated repositories to leave untouched, e.g. owner/repo,owner/*")
+	}
+	if has(flagsWrite) {
+		fs.StringVar(&opts.outPath, "out", "", "output file path (defaults to input file)")
+		fs.BoolVar(&opts.dryRun, "dry-run", false, "print updated markdown to stdout")
+		fs.BoolVar(&opts.backup, "backup", false, "keep the previous content of changed files next to them with a .bak suffix")
+	}
+	if has(flagsSource) {
+		fs.StringVar(&opts.apiURL, "api-url", "", "GitHub REST API base URL (for GitHub Enterprise Server)")
+		fs.StringVar(&opts.cachePath, "cache", "", "cache fetched values in this file between runs")
+		fs.DurationVar(&opts.cacheTTL, "cache-ttl", defaultCacheTTL, "how long cached values are reused")
+		fs.StringVar(&opts.auth.tokenFile, "token-file", "", "read GitHub tokens from this file (one per line) instead of the environment")
+		fs.Int64Var(&opts.auth.appID, "app-id", 0, "authenticate as this GitHub App (requires -app-key)")
+		fs.StringVar(&opts.auth.appKeyFile, "app-key", "", "path to the GitHub App private key (PEM)")
+		fs.Int64Var(&opts.auth.appInstallationID, "app-installation-id", 0, "GitHub App installation ID (defaults to the only installation)")
+		fs.StringVar(&opts.fixturePath, "fixture", "", "read star counts from this JSON or YAML file, using the API only for repositories it does not list")
+		fs.BoolVar(&opts.offline, "offline", false, "never call the GitHub API; star counts come from -fixture only")
+	}
+	if has(flagsLabel) {
+		fs.StringVar(&opts.label, "label", "", "label template, e.g. \"⭐{stars}\" (placeholders: {stars}, {count}, {metrics}, {trend}, {pushed}, {release}, {release_date}, {issues}, {stale})")
+		fs.IntVar(&opts.staleAfter, "stale-after", 0, "mark repositories without a push in this many months as stale (0 disables)")
+		fs.StringVar(&opts.staleMarker, "stale-marker", defaultStaleMarker, "marker shown in the label of stale repositories")
+		fs.StringVar(&opts.style, "style", styleLabel, "show the label in the link text (label), or as a shields.io (shields) or local SVG (svg) badge after the link")
+		fs.StringVar(&opts.badgeDir, "badge-dir", defaultBadgeDir, "directory the SVG badges of -style svg are written to")
+	}
+	if has(flagsRender) {
+		fs.IntVar(&opts.minStars, "min-stars", 0, "leave repositories with fewer stars without a label")
+		fs.StringVar(&opts.historyPath, "history", "", "record star counts per run in this file")
+		fs.BoolVar(&opts.trend, "trend", false, "show the star change from the history in the label (requires -history)")
+		fs.IntVar(&opts.trendDays, "trend-days", defaultTrendDays, "period in days the trend compares against")
+		fs.IntVar(&opts.trendMinDelta, "trend-min-delta", 0, "hide trends with a smaller absolute change")
+		fs.StringVar(&opts.sparklineDir, "sparklines", "", "render the star history of each repository as an SVG sparkline into this directory (requires -history)")
+		fs.IntVar(&opts.sparklineDays, "sparkline-days", defaultSparklineDays, "period in days the sparklines show")
+		fs.IntVar(&opts.sparklineTop, "sparkline-top", 0, "render sparklines only for this many of the most starred repositories of each document (0 renders all)")
+		fs.BoolVar(&opts.sparklineLinks, "sparkline-links", false, "show the sparklines as images after the labels")
+		fs.BoolVar(&opts.metrics, "metrics", false, "append package registry metrics for package links found next to repository links")
+		fs.StringVar(&opts.packagesPath, "packages", "", "JSON file mapping owner/repo to registry packages, e.g. {\"owner/repo\": [\"npm:name\"]} (implies -metrics)")
+	}
+	if has(flagsPolicy) {
+		fs.IntVar(&opts.policy.minStars, "policy-min-stars", 0, "policy: minimum star count of every listed repository")
+		fs.BoolVar(&opts.policy.forbidArchived, "policy-forbid-archived", false, "policy: reject archived repositories")
+		fs.IntVar(&opts.policy.maxInactiveDays, "policy-max-inactive-days", 0, "policy: reject repositories without a push in this many days")
+		fs.StringVar(&values.licenseList, "policy-licenses", "", "policy: comma-separated allowed SPDX license identifiers, e.g. MIT,Apache-2.0")
+		fs.StringVar(&opts.baseRev, "base", "", "check only links added since this git revision, e.g. origin/main (implies -policy)")
+		fs.StringVar(&opts.baseFile, "base-file", "", "check only links added compared to this file (implies -policy)")
+		fs.StringVar(&opts.sarifPath, "sarif", "", "also write policy violations to this file in SARIF format")
+	}
+	if has(flagsDuplicates) {
+		fs.StringVar(&opts.duplicates, "duplicates", "", "report repositories linked more than once: warn, or error to also exit with code 1")
+	}
+	if has(flagsSnapshot) {
+		fs.StringVar(&opts.snapshotPath, "snapshot", "", "snapshot file written by fetch and read by apply")
+	}
+	if has(flagsReport) {
+		fs.IntVar(&opts.reportTop, "top", defaultReportTop, "number of repositories in the top list (0 lists all)")
+	}
+	if has(flagsOutput) {
+		fs.StringVar(&opts.reportOutput, "output", reportText, "output format: text, markdown or json")
+	}
+	if has(flagsWatch) {
+		fs.BoolVar(&opts.watch, "watch", false, "keep running and update the documents again whenever they are saved")
+		fs.DurationVar(&opts.watchInterval, "watch-interval", defaultWatchInterval, "how often -watch checks the documents for changes")
+		fs.DurationVar(&opts.watchDebounce, "watch-debounce", defaultWatchDebounce, "how long a document must stay unchanged before -watch updates it")
+	}
+	if has(flagsAction) {
+		fs.StringVar(&opts.action.mode, "mode", actionCommit, "publish changed documents: commit, pull-request or none")
+		fs.StringVar(&opts.action.repo, "repo", "", "repository to publish to, as owner/repo (defaults to GITHUB_REPOSITORY)")
+		fs.StringVar(&opts.action.branch, "branch", "", "branch to commit to, or base of the pull request (defaults to GITHUB_REF_NAME)")
+		fs.StringVar(&opts.action.commitMessage, "commit-message", defaultCommitMessage, "commit message")
+		fs.StringVar(&opts.action.prBranch, "pr-branch", defaultPRBranch, "branch of the pull request")
+		fs.StringVar(&opts.action.prTitle, "pr-title", "", "pull request title (defaults to the commit message)")
+		fs.StringVar(&opts.action.prBody, "pr-body", "", "pull request description")
+	}
+	return fs
+}
+
+// writeUsage prints the overview of the commands.
+func writeUsage(w io.Writer) {
+	_, _ = fmt.Fprintf(w, "Usage: %s <command> [flags] <path_to_file>...\n\nCommands:\n", programName)
+	for _, cmd := range commands {
+		_, _ = fmt.Fprintf(w, "  %-8s %s\n", cmd.name, cmd.summary)
+	}
+	_, _ = fmt.Fprintf(w, "\nRun \"%s help <command>\" for the flags of a command.\n", programName)
+	_, _ = fmt.Fprintf(w, "Without a command, %s [flags] <path_to_file>... updates the documents as before.\n", programName)
+}
+
+// runHelp prints the usage of the command named in args, or the overview of all commands.
+func runHelp(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		writeUsage(stdout)
+		return 0
+	}
+	cmd, rest := lookupCommand(args)
+	if len(rest) == len(args) {
+		_, _ = fmt.Fprintf(stderr, "Error: unknown command %q\n", args[0])
+		writeUsage(stderr)
+		return 2 //nolint:mnd
+	}
+	var opts options
+	newFlagSet(cmd, &opts, &flagValues{}, stdout).Usage()
+	return 0
+}
+
+// capitalize returns s with its first letter in upper case.
+func capitalize(s string) string {
+	if s == "" || s[0] < 'a' || s[0] > 'z' {
+		return s
+	}
+	return string(s[0]-'a'+'A') + s[1:]
+}
@@ -0,0 +1,156 @@
+// Package main provides the core functionality for updating GitHub star counts in Markdown and AsciiDoc files.
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLookupCommand(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected string
+		rest     int
+	}{
+		{[]string{"update", "README.md"}, commandUpdate, 1},
+		{[]string{"check", "-policy-min-stars", "10", "README.md"}, commandCheck, 3},
+		{[]string{"report"}, commandReport, 0},
+		{[]string{"-dry-run", "README.md"}, "", 2},
+		{[]string{"README.md"}, "", 1},
+		{nil, "", 0},
+	}
+	for _, tt := range tests {
+		cmd, rest := lookupCommand(tt.args)
+		if cmd.name != tt.expected || len(rest) != tt.rest {
+			t.Errorf("%v: expected %q with %d arguments, got %q with %d", tt.args, tt.expected, tt.rest, cmd.name, len(rest))
+		}
+	}
+}
+
+func TestCommandFlags(t *testing.T) {
+	isolateAuthEnv(t)
+	doc := filepath.Join(t.TempDir(), "list.md")
+	writeFile(t, doc, "- [A](https://github.com/owner/a)\n")
+
+	tests := []struct {
+		args []string
+		flag string
+	}{
+		{[]string{"update", "-policy-min-stars", "10", doc}, "-policy-min-stars"},
+		{[]string{"check", "-dry-run", doc}, "-dry-run"},
+		{[]string{"list", "-cache", "cache.json", doc}, "-cache"},
+		{[]string{"report", "-snapshot", "stars.json", doc}, "-snapshot"},
+		{[]string{"-snapshot", "stars.json", doc}, "-snapshot"},
+	}
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		if code := run(tt.args, &stdout, &stderr); code != 2 || !strings.Contains(stderr.String(), "flag provided but not defined: "+tt.flag) {
+			t.Errorf("%v: expected exit code 2 rejecting %s, got %d: %s", tt.args, tt.flag, code, stderr.String())
+		}
+	}
+}
+
+func TestRunHelp(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"help"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	for _, cmd := range commands {
+		if !strings.Contains(stdout.String(), "  "+cmd.name+" ") {
+			t.Errorf("expected %s in the usage, got %q", cmd.name, stdout.String())
+		}
+	}
+
+	stdout.Reset()
+	if code := run([]string{"help", "check"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	usage := stdout.String()
+	if !strings.Contains(usage, "markdown-github-stars-updater check [flags]") || !strings.Contains(usage, "-policy-min-stars") || strings.Contains(usage, "-dry-run") {
+		t.Errorf("unexpected usage of check: %q", usage)
+	}
+
+	if code := run([]string{"help", "frobnicate"}, &stdout, &stderr); code != 2 {
+		t.Errorf("expected exit code 2 for an unknown command, got %d", code)
+	}
+}
+
+func TestRunUpdateAndCheckCommands(t *testing.T) {
+	isolateAuthEnv(t)
+	t.Setenv("GITHUB_TOKEN", "test_token")
+	server := newFakeAPI(t, map[string]int{"owner/a": 1500})
+	doc := filepath.Join(t.TempDir(), "list.md")
+	writeFile(t, doc, "- [A](https://github.com/owner/a)\n- [Gone](https://github.com/owner/gone)\n")
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"update", "-api-url", server.URL, doc}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	expected := "- [A (⭐1.5k)](https://github.com/owner/a)\n- [Gone](https://github.com/owner/gone)\n"
+	if got := mustRead(t, doc); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+
+	// Without rules, check still fails on repositories that cannot be fetched.
+	stdout.Reset()
+	if code := run([]string{"check", "-api-url", server.URL, doc}, &stdout, &stderr); code != 1 {
+		t.Fatalf("expected exit code 1, got %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "list.md:2:") || !strings.Contains(stdout.String(), "(unreachable)") {
+		t.Errorf("expected an unreachable violation, got %q", stdout.String())
+	}
+	if got := mustRead(t, doc); got != expected {
+		t.Errorf("check must not modify the document, got %q", got)
+	}
+
+	// The flat flag set of earlier releases keeps its -policy flag and its rule requirement.
+	if code := run([]string{"-policy", "-api-url", server.URL, doc}, &stdout, &stderr); code != 1 || !strings.Contains(stderr.String(), "needs at least one rule") {
+		t.Errorf("expected the legacy -policy to require a rule, got %d: %s", code, stderr.String())
+	}
+}
+
+func TestRunList(t *testing.T) {
+	isolateAuthEnv(t)
+	dir := t.TempDir()
+	doc := filepath.Join(dir, "list.md")
+	writeFile(t, doc, "# Tools\n- [A](https://github.com/owner/a)\n- [B](https://github.com/Owner/B.git) and [A again](https://github.com/owner/a/tree/main)\n- [Skip](https://github.com/skip/c)\n")
+
+	var stdout, stderr bytes.Buffer
+	// No token and no API: list never fetches.
+	if code := run([]string{"list", "-exclude", "skip/*", doc}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	expected := doc + ":2:7: owner/a\n" +
+		doc + ":3:7: owner/b\n" +
+		doc + ":3:53: owner/a\n" +
+		doc + ":4:10: skip/c (excluded)\n" +
+		"4 link(s) to 3 repositories.\n"
+	if stdout.String() != expected {
+		t.Errorf("expected %q, got %q", expected, stdout.String())
+	}
+	if stderr.Len() != 0 {
+		t.Errorf("expected no warnings, got %q", stderr.String())
+	}
+}
+
+func TestRunListAnnotatedLinks(t *testing.T) {
+	isolateAuthEnv(t)
+	doc := filepath.Join(t.TempDir(), "list.md")
+	writeFile(t, doc, "- [A](https://github.com/owner/a)\n- [A pinned](https://github.com/owner/a) <!-- stars: pin 9999 -->\n"+
+		"- [A hidden](https://github.com/owner/a) <!-- stars: hide -->\n- [Fork](https://github.com/me/fork) <!-- stars: up/tool -->\n")
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"list", doc}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	expected := doc + ":1:7: owner/a\n" +
+		doc + ":2:14: owner/a\n" +
+		doc + ":3:14: owner/a\n" +
+		doc + ":4:10: me/fork\n" +
+		"4 link(s) to 2 repositories.\n"
+	if stdout.String() != expected {
+		t.Errorf("expected %q, got %q", expected, stdout.String())
+	}
+}
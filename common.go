@@ -1,46 +0,0 @@
-// Package main provides the core functionality for updating GitHub star counts in Markdown and AsciiDoc files.
-package main
-
-import (
-	"fmt"
-	"regexp"
-	"strings"
-)
-
-// LinkUpdater defines the interface for updating repo links in different file formats.
-type LinkUpdater interface {
-	// FindRepos finds all GitHub repository links in the given content.
-	FindRepos(content string) ([]string, error)
-
-	// UpdateContent updates the content by injecting star counts using the provided map.
-	UpdateContent(content string, stars map[string]int) (string, error)
-}
-
-var (
-	starsInfoRe  = regexp.MustCompile(`\s*\(⭐[^)]*\)`)
-	multiSpaceRe = regexp.MustCompile(`\s{2,}`)
-)
-
-// removeStarsInfo removes the existing star count information from the input string.
-func removeStarsInfo(input string) string {
-	result := starsInfoRe.ReplaceAllString(input, "")
-	result = multiSpaceRe.ReplaceAllString(result, " ")
-	return strings.TrimSpace(result)
-}
-
-// formatStarCount formats the given star count for display in the markdown content.
-func formatStarCount(stars int) string {
-	switch {
-	case stars < 1000:
-		return fmt.Sprintf("%d", stars)
-	case stars < 10000:
-		wholePart := stars / 1000
-		decimalPart := (stars % 1000) / 100
-		if decimalPart == 0 {
-			return fmt.Sprintf("%dk", wholePart)
-		}
-		return fmt.Sprintf("%d.%dk", wholePart, decimalPart)
-	default:
-		return fmt.Sprintf("%dk", stars/1000)
-	}
-}
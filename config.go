@@ -0,0 +1,341 @@
+// Package main provides the core functionality for updating GitHub star counts in Markdown and AsciiDoc files.
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/stn1slv/github-markdown-stars-updater/pkg/stars"
+	"gopkg.in/yaml.v3"
+)
+
+// configFileNames are the per-project configuration file names, in lookup order.
+var configFileNames = []string{".stars-updater.yaml", ".stars-updater.yml"}
+
+// Supported values for format overrides.
+const (
+	formatMarkdown = string(stars.FormatMarkdown)
+	formatASCIIDoc = string(stars.FormatASCIIDoc)
+)
+
+// fileConfig is the content of a .stars-updater.yaml file.
+type fileConfig struct {
+	// Inputs are glob patterns of the documents to update, relative to the config file.
+	Inputs []string `yaml:"inputs"`
+	// Formats maps a file extension (".txt") or glob ("docs/*.txt") to "markdown" or "asciidoc".
+	Formats map[string]string `yaml:"formats"`
+	// Label is the label template, e.g. "⭐{stars}".
+	Label string `yaml:"label"`
+	// Style shows the label in the link text ("label") or as a "shields" or local "svg" badge.
+	Style string `yaml:"style"`
+	// BadgeDir is the directory SVG badges are written to, relative to the config file.
+	BadgeDir string `yaml:"badge_dir"`
+	// Exclude lists repositories ("owner/repo", URLs or globs like "owner/*") that are left untouched.
+	Exclude []string `yaml:"exclude"`
+	// Duplicates reports repositories linked more than once: "warn" or "error".
+	Duplicates string `yaml:"duplicates"`
+
+	Host       hostConfig      `yaml:"host"`
+	Cache      cacheConfig     `yaml:"cache"`
+	History    historyConfig   `yaml:"history"`
+	Thresholds thresholdConfig `yaml:"thresholds"`
+	Policy     policyConfig    `yaml:"policy"`
+
+	// dir is the directory containing the config file; relative paths are resolved against it.
+	dir string
+}
+
+type hostConfig struct {
+	// APIURL is the GitHub REST API base URL, e.g. "https://github.example.com/api/v3/".
+	APIURL string `yaml:"api_url"`
+}
+
+type cacheConfig struct {
+	// Path is the file star counts are cached in between runs.
+	Path string `yaml:"path"`
+	// TTL is how long cached counts are reused, e.g. "12h".
+	TTL duration `yaml:"ttl"`
+}
+
+type historyConfig struct {
+	// Path is the file the star counts of every run are recorded in.
+	Path string `yaml:"path"`
+	// Trend adds the {trend} field to the default label.
+	Trend bool `yaml:"trend"`
+	// Sparklines renders the recorded history as SVG sparklines.
+	Sparklines sparklineConfig `yaml:"sparklines"`
+}
+
+type sparklineConfig struct {
+	// Dir is the directory the sparklines are written to, relative to the config file.
+	Dir string `yaml:"dir"`
+	// Days is the period the sparklines show.
+	Days int `yaml:"days"`
+	// Top limits the sparklines to the most starred repositories of each document.
+	Top int `yaml:"top"`
+	// Links shows the sparklines as images after the labels.
+	Links bool `yaml:"links"`
+}
+
+type thresholdConfig struct {
+	// MinStars hides the label of repositories with fewer stars.
+	MinStars int `yaml:"min_stars"`
+	// TrendDays is the period the {trend} field compares against.
+	TrendDays int `yaml:"trend_days"`
+	// TrendMinDelta hides trends with a smaller absolute change.
+	TrendMinDelta int `yaml:"trend_min_delta"`
+	// StaleAfterMonths marks repositories without a push in that many months as stale.
+	StaleAfterMonths int `yaml:"stale_after_months"`
+	// StaleMarker replaces the default 💤 stale marker.
+	StaleMarker string `yaml:"stale_marker"`
+}
+
+type policyConfig struct {
+	// MinStars is the star count every listed repository needs.
+	MinStars int `yaml:"min_stars"`
+	// ForbidArchived rejects archived repositories.
+	ForbidArchived bool `yaml:"forbid_archived"`
+	// MaxInactiveDays rejects repositories without a push in that many days.
+	MaxInactiveDays int `yaml:"max_inactive_days"`
+	// Licenses lists the allowed SPDX license identifiers, e.g. ["MIT", "Apache-2.0"].
+	Licenses []string `yaml:"licenses"`
+}
+
+// duration is a time.Duration that unmarshals from strings such as "90m" or "24h".
+type duration time.Duration
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (d *duration) UnmarshalYAML(value *yaml.Node) error {
+	parsed, err := time.ParseDuration(value.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: invalid duration %q", value.Line, value.Value)
+	}
+	*d = duration(parsed)
+	return nil
+}
+
+// findConfig looks for a config file in dir and its parents and returns its path, or "" if none exists.
+func findConfig(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		for _, name := range configFileNames {
+			candidate := filepath.Join(dir, name)
+			if info, statErr := os.Stat(candidate); statErr == nil && !info.IsDir() {
+				return candidate, nil
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// loadConfig reads and validates the config file at path. Unknown keys are rejected.
+func loadConfig(configPath string) (*fileConfig, error) {
+	data, err := os.ReadFile(filepath.Clean(configPath))
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &fileConfig{dir: filepath.Dir(configPath)}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("config %s: %w", configPath, err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("config %s: %w", configPath, err)
+	}
+	return cfg, nil
+}
+
+// validate checks the values that YAML decoding cannot check by itself.
+func (c *fileConfig) validate() error {
+	for pattern, format := range c.Formats {
+		if format != formatMarkdown && format != formatASCIIDoc {
+			return fmt.Errorf("formats: %q maps to unknown format %q (expected %q or %q)", pattern, format, formatMarkdown, formatASCIIDoc)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("formats: invalid pattern %q", pattern)
+		}
+	}
+	for _, pattern := range c.Inputs {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("inputs: invalid pattern %q", pattern)
+		}
+	}
+	for _, pattern := range c.Exclude {
+		if _, err := path.Match(stars.RepoKey(pattern), ""); err != nil {
+			return fmt.Errorf("exclude: invalid pattern %q", pattern)
+		}
+	}
+	if c.Duplicates != "" && c.Duplicates != duplicatesWarn && c.Duplicates != duplicatesError {
+		return fmt.Errorf("duplicates: unknown value %q (expected %q or %q)", c.Duplicates, duplicatesWarn, duplicatesError)
+	}
+	if c.Style != "" && c.Style != styleLabel && c.Style != styleShields && c.Style != styleSVG {
+		return fmt.Errorf("style: unknown value %q (expected %q, %q or %q)", c.Style, styleLabel, styleShields, styleSVG)
+	}
+	if c.Label != "" {
+		if err := validateLabelTemplate(c.Label); err != nil {
+			return fmt.Errorf("label: %w", err)
+		}
+	}
+	if c.Cache.TTL < 0 {
+		return errors.New("cache.ttl must not be negative")
+	}
+	if c.Thresholds.MinStars < 0 {
+		return errors.New("thresholds.min_stars must not be negative")
+	}
+	if c.Thresholds.TrendDays < 0 {
+		return errors.New("thresholds.trend_days must not be negative")
+	}
+	if c.Thresholds.TrendMinDelta < 0 {
+		return errors.New("thresholds.trend_min_delta must not be negative")
+	}
+	if c.Thresholds.StaleAfterMonths < 0 {
+		return errors.New("thresholds.stale_after_months must not be negative")
+	}
+	if c.History.Sparklines.Days < 0 || c.History.Sparklines.Top < 0 {
+		return errors.New("history.sparklines.days and history.sparklines.top must not be negative")
+	}
+	if c.Policy.MinStars < 0 {
+		return errors.New("policy.min_stars must not be negative")
+	}
+	if c.Policy.MaxInactiveDays < 0 {
+		return errors.New("policy.max_inactive_days must not be negative")
+	}
+	if strings.ContainsAny(c.Thresholds.StaleMarker, ")]") {
+		return errors.New("thresholds.stale_marker must not contain ')' or ']'")
+	}
+	return nil
+}
+
+// resolve returns p relative to the config file directory unless it is absolute.
+func (c *fileConfig) resolve(p string) string {
+	if p == "" || filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(c.dir, p)
+}
+
+// expandInputs expands glob patterns into a sorted, de-duplicated list of files. Directories
+// expand to the Markdown and AsciiDoc files below them, skipping hidden directories. Patterns that
+// match nothing are returned unchanged so that the caller reports the missing file.
+func expandInputs(patterns []string) ([]string, error) {
+	var files []string
+	seen := make(map[string]bool)
+	add := func(file string) {
+		if !seen[file] {
+			seen[file] = true
+			files = append(files, file)
+		}
+	}
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid input pattern %q: %w", pattern, err)
+		}
+		if len(matches) == 0 {
+			matches = []string{pattern}
+		}
+		for _, match := range matches {
+			if info, statErr := os.Stat(match); statErr != nil || !info.IsDir() {
+				add(match)
+				continue
+			}
+			documents, err := documentsIn(match)
+			if err != nil {
+				return nil, err
+			}
+			for _, document := range documents {
+				add(document)
+			}
+		}
+	}
+	return files, nil
+}
+
+// documentsIn returns the files below dir with a Markdown or AsciiDoc extension, in lexical order.
+func documentsIn(dir string) ([]string, error) {
+	var documents []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != dir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if _, formatErr := stars.FormatFromPath(p); formatErr == nil {
+			documents = append(documents, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing documents in %s: %w", dir, err)
+	}
+	return documents, nil
+}
+
+// formatFor returns the format override for filePath, or "" when none applies. Extension keys
+// (".txt") match case-insensitively; other keys are globs matched against the slash-separated
+// path relative to base, the config file directory, and against the base name. When several keys
+// match, the longest wins, and of equally long keys the first in lexical order.
+func formatFor(formats map[string]string, base, filePath string) string {
+	if base != "" {
+		absBase, baseErr := filepath.Abs(base)
+		abs, err := filepath.Abs(filePath)
+		if baseErr == nil && err == nil {
+			if rel, err := filepath.Rel(absBase, abs); err == nil {
+				filePath = rel
+			}
+		}
+	}
+	slashed := filepath.ToSlash(filePath)
+	ext := strings.ToLower(filepath.Ext(filePath))
+	patterns := slices.Sorted(maps.Keys(formats))
+	slices.SortStableFunc(patterns, func(a, b string) int { return cmp.Compare(len(b), len(a)) })
+	for _, pattern := range patterns {
+		if strings.HasPrefix(pattern, ".") && !strings.ContainsAny(pattern, "*?[/") {
+			if strings.ToLower(pattern) == ext {
+				return formats[pattern]
+			}
+			continue
+		}
+		if ok, _ := path.Match(pattern, slashed); ok {
+			return formats[pattern]
+		}
+		if ok, _ := path.Match(pattern, path.Base(slashed)); ok {
+			return formats[pattern]
+		}
+	}
+	return ""
+}
+
+// isExcluded reports whether the repository with the canonical key matches one of the patterns.
+func isExcluded(patterns []string, key string) bool {
+	for _, pattern := range patterns {
+		if stars.MatchRepo(pattern, key) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,225 @@
+// Package main provides the core functionality for updating GitHub star counts in Markdown and AsciiDoc files.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".stars-updater.yaml")
+	writeFile(t, path, `
+inputs: ["docs/*.md"]
+formats:
+  ".txt": markdown
+label: "⭐{stars} ({count})"
+exclude: ["owner/*"]
+host:
+  api_url: https://github.example.com/api/v3/
+cache:
+  path: .cache/stars.json
+  ttl: 12h
+thresholds:
+  min_stars: 10
+`)
+
+	_, err := loadConfig(path)
+	if err == nil || !strings.Contains(err.Error(), "must not contain") {
+		t.Fatalf("expected label validation error, got %v", err)
+	}
+
+	writeFile(t, path, strings.Replace(mustRead(t, path), `"⭐{stars} ({count})"`, `"⭐{stars} / {count}"`, 1))
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Label != "⭐{stars} / {count}" || cfg.Formats[".txt"] != formatMarkdown || cfg.Thresholds.MinStars != 10 {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+	if time.Duration(cfg.Cache.TTL) != 12*time.Hour {
+		t.Errorf("expected 12h TTL, got %v", time.Duration(cfg.Cache.TTL))
+	}
+	if got := cfg.resolve(cfg.Cache.Path); got != filepath.Join(dir, ".cache", "stars.json") {
+		t.Errorf("cache path not resolved against config dir: %s", got)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"Unknown key", "labels: x\n", "field labels not found"},
+		{"Unknown nested key", "cache:\n  dir: x\n", "field dir not found"},
+		{"Unknown format", "formats:\n  .txt: rst\n", "unknown format"},
+		{"Bad duration", "cache:\n  ttl: soon\n", "invalid duration"},
+		{"Unknown placeholder", "label: \"⭐{stras}\"\n", "unknown placeholder {stras}"},
+		{"Label without star", "label: \"{stars}\"\n", "must start with ⭐"},
+		{"Negative threshold", "thresholds:\n  min_stars: -1\n", "min_stars"},
+		{"Unknown style", "style: badge\n", "style: unknown value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), ".stars-updater.yaml")
+			writeFile(t, path, tt.content)
+			_, err := loadConfig(path)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestFindConfig(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "a", "b")
+	if err := os.MkdirAll(nested, 0o750); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := findConfig(nested); err != nil || got != "" {
+		t.Fatalf("expected no config, got %q, %v", got, err)
+	}
+
+	writeFile(t, filepath.Join(root, ".stars-updater.yml"), "label: \"⭐{stars}\"\n")
+	got, err := findConfig(nested)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != filepath.Join(root, ".stars-updater.yml") {
+		t.Errorf("expected config from the parent directory, got %q", got)
+	}
+}
+
+func TestFormatFor(t *testing.T) {
+	base := t.TempDir()
+	formats := map[string]string{".TXT": formatMarkdown, "docs/*.inc": formatASCIIDoc, "*.inc": formatMarkdown}
+	tests := []struct {
+		base string
+		path string
+		want string
+	}{
+		{"", "notes.txt", formatMarkdown},
+		{"", "docs/part.inc", formatASCIIDoc},
+		{"", "other/part.inc", formatMarkdown},
+		{"", "README.md", ""},
+		{base, filepath.Join(base, "docs", "part.inc"), formatASCIIDoc},
+		{base, filepath.Join(base, "other", "part.inc"), formatMarkdown},
+		{base, filepath.Join(base, "sub", "docs", "part.inc"), formatMarkdown},
+	}
+	for _, tt := range tests {
+		if got := formatFor(formats, tt.base, tt.path); got != tt.want {
+			t.Errorf("For %s, expected %q, got %q", tt.path, tt.want, got)
+		}
+	}
+}
+
+func TestExpandInputsDirectories(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"README.md", "docs/guide.adoc", "docs/notes.txt", ".github/template.md", "z.markdown"} {
+		writeFile(t, filepath.Join(dir, name), "")
+	}
+
+	files, err := expandInputs([]string{dir, filepath.Join(dir, "README.md"), filepath.Join(dir, "missing.md")})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{
+		filepath.Join(dir, "README.md"), filepath.Join(dir, "docs", "guide.adoc"), filepath.Join(dir, "z.markdown"),
+		filepath.Join(dir, "missing.md"),
+	}
+	if strings.Join(files, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected %v, got %v", expected, files)
+	}
+}
+
+func TestIsExcluded(t *testing.T) {
+	patterns := []string{"https://github.com/Owner/Skip", "org/*"}
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"owner/skip", true},
+		{"org/anything", true},
+		{"owner/keep", false},
+	}
+	for _, tt := range tests {
+		if got := isExcluded(patterns, tt.key); got != tt.want {
+			t.Errorf("For %s, expected %v, got %v", tt.key, tt.want, got)
+		}
+	}
+}
+
+func TestNewLabelFunc(t *testing.T) {
+	fields := map[string]labelField{"metrics": func(string, int) string { return "" }}
+	label, err := newLabelFunc("⭐{stars} · {metrics}", fields, 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := label("https://github.com/o/r", 1234); got != "⭐1.2k" {
+		t.Errorf("expected empty metrics to be dropped, got %q", got)
+	}
+	if got := label("https://github.com/o/r", 99); got != "" {
+		t.Errorf("expected no label below the threshold, got %q", got)
+	}
+
+	if _, err := newLabelFunc("⭐{stars} (x)", nil, 0); err == nil {
+		t.Error("expected error for a template containing ')'")
+	}
+}
+
+func TestRunWithConfig(t *testing.T) {
+	isolateAuthEnv(t)
+	t.Setenv("GITHUB_TOKEN", "test_token")
+	server := newFakeAPI(t, map[string]int{"owner/a": 1500, "owner/b": 5, "skip/c": 9})
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, ".stars-updater.yaml"), fmt.Sprintf(`
+inputs: ["docs/*.txt"]
+formats:
+  .txt: asciidoc
+  "docs/*.txt": markdown
+label: "⭐{stars} / {count}"
+exclude: ["skip/*"]
+host:
+  api_url: %s
+cache:
+  path: stars-cache.json
+thresholds:
+  min_stars: 10
+`, server.URL))
+	doc := filepath.Join(dir, "docs", "list.txt")
+	writeFile(t, doc, "- [A](https://github.com/owner/a)\n- [B (⭐3)](https://github.com/owner/b)\n- [C](https://github.com/skip/c)\n")
+	t.Chdir(dir)
+
+	var stdout, stderr bytes.Buffer
+	if code := run(nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	expected := "- [A (⭐1.5k / 1500)](https://github.com/owner/a)\n- [B](https://github.com/owner/b)\n- [C](https://github.com/skip/c)\n"
+	if got := mustRead(t, doc); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+	if server.requests.Load() != 2 {
+		t.Errorf("expected 2 API requests, got %d", server.requests.Load())
+	}
+
+	// The second run is served from the cache, and the -label flag overrides the config file.
+	if code := run([]string{"-label", "⭐{count}"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	if server.requests.Load() != 2 {
+		t.Errorf("expected cached values to be reused, got %d API requests", server.requests.Load())
+	}
+	if got := mustRead(t, doc); !strings.Contains(got, "[A (⭐1500)]") {
+		t.Errorf("expected the -label flag to take precedence, got %q", got)
+	}
+}
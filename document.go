@@ -0,0 +1,69 @@
+// Package main provides the core functionality for updating GitHub star counts in Markdown and AsciiDoc files.
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// utf8BOM is the byte order mark that some Windows editors put at the start of UTF-8 files.
+const utf8BOM = "\ufeff"
+
+// textLayout is the byte-level layout of a document, which an update must leave exactly as it
+// was. Line endings inside the content pass through the updaters unchanged; newline is only used
+// for the line breaks of text the tool inserts.
+type textLayout struct {
+	bom             bool   // the file starts with a UTF-8 byte order mark
+	newline         string // "\r\n" when the first line ends with CRLF, "\n" otherwise
+	trailingNewline bool   // the file ends with a line break
+}
+
+// parseDocument splits data into the content the updaters work on, without byte order mark, and
+// the layout needed to turn updated content back into the file format.
+func parseDocument(data []byte) (string, textLayout) {
+	content := string(data)
+	layout := textLayout{newline: "\n"}
+	if rest, ok := strings.CutPrefix(content, utf8BOM); ok {
+		content, layout.bom = rest, true
+	}
+	if i := strings.IndexByte(content, '\n'); i > 0 && content[i-1] == '\r' {
+		layout.newline = "\r\n"
+	}
+	layout.trailingNewline = strings.HasSuffix(content, "\n")
+	return content, layout
+}
+
+// render returns content in the layout of the original document: with its byte order mark, and
+// ending with a line break exactly when the original did.
+func (l textLayout) render(content string) []byte {
+	switch hasNewline := strings.HasSuffix(content, "\n"); {
+	case l.trailingNewline && !hasNewline && content != "":
+		content += l.newline
+	case !l.trailingNewline && hasNewline:
+		content = strings.TrimSuffix(strings.TrimSuffix(content, "\n"), "\r")
+	}
+	if l.bom {
+		content = utf8BOM + content
+	}
+	return []byte(content)
+}
+
+// lines returns text, written with "\n" line breaks, with the line breaks of the document.
+func (l textLayout) lines(text string) string {
+	if l.newline == "\n" {
+		return text
+	}
+	return strings.ReplaceAll(text, "\n", l.newline)
+}
+
+// readDocument reads the document at path and returns its content without byte order mark, and
+// its layout.
+func readDocument(path string) (string, textLayout, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return "", textLayout{}, err
+	}
+	content, layout := parseDocument(data)
+	return content, layout, nil
+}
@@ -0,0 +1,119 @@
+// Package main provides the core functionality for updating GitHub star counts in Markdown and AsciiDoc files.
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseDocument(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		content string
+		layout  textLayout
+	}{
+		{"LF", "a\nb\n", "a\nb\n", textLayout{newline: "\n", trailingNewline: true}},
+		{"CRLF with BOM", "\ufeffa\r\nb\r\n", "a\r\nb\r\n", textLayout{bom: true, newline: "\r\n", trailingNewline: true}},
+		{"No trailing newline", "a\r\nb", "a\r\nb", textLayout{newline: "\r\n"}},
+		{"Empty", "", "", textLayout{newline: "\n"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, layout := parseDocument([]byte(tt.data))
+			if content != tt.content || layout != tt.layout {
+				t.Errorf("expected %q, %+v, got %q, %+v", tt.content, tt.layout, content, layout)
+			}
+			if got := string(layout.render(content)); got != tt.data {
+				t.Errorf("expected the round trip to return %q, got %q", tt.data, got)
+			}
+		})
+	}
+
+	layout := textLayout{bom: true, newline: "\r\n", trailingNewline: true}
+	if got := string(layout.render("a\r\nb")); got != "\ufeffa\r\nb\r\n" {
+		t.Errorf("expected the trailing newline to be restored, got %q", got)
+	}
+	if got := layout.lines("| a |\n| b |\n"); got != "| a |\r\n| b |\r\n" {
+		t.Errorf("expected CRLF line breaks, got %q", got)
+	}
+}
+
+// TestLayoutGolden updates every document in testdata/layout from a fixture and compares the
+// result byte for byte with its .golden file, through both the file and the stdout output, and
+// both in memory and streamed as large documents are.
+func TestLayoutGolden(t *testing.T) {
+	isolateAuthEnv(t)
+	fixture := filepath.Join("testdata", "layout", "stars.json")
+	inputs, err := filepath.Glob(filepath.Join("testdata", "layout", "*.*doc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	markdown, err := filepath.Glob(filepath.Join("testdata", "layout", "*.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	inputs = append(inputs, markdown...)
+	if len(inputs) == 0 {
+		t.Fatal("no golden inputs found")
+	}
+
+	for _, mode := range []struct {
+		name      string
+		threshold int64
+	}{{"in memory", streamThreshold}, {"streamed", 0}} {
+		t.Run(mode.name, func(t *testing.T) {
+			setStreamThreshold(t, mode.threshold)
+			for _, input := range inputs {
+				t.Run(filepath.Base(input), func(t *testing.T) {
+					golden := mustRead(t, input+".golden")
+					doc := filepath.Join(t.TempDir(), filepath.Base(input))
+					writeFile(t, doc, mustRead(t, input))
+
+					var stdout, stderr bytes.Buffer
+					if code := run([]string{"-offline", "-fixture", fixture, "-dry-run", doc}, &stdout, &stderr); code != 0 {
+						t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+					}
+					if stdout.String() != golden {
+						t.Errorf("dry run: expected %q, got %q", golden, stdout.String())
+					}
+
+					stdout.Reset()
+					if code := run([]string{"-offline", "-fixture", fixture, doc}, &stdout, &stderr); code != 0 {
+						t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+					}
+					if got := mustRead(t, doc); got != golden {
+						t.Errorf("file: expected %q, got %q", golden, got)
+					}
+
+					// With no star value changing, the output is byte-identical and the file is untouched.
+					stdout.Reset()
+					if code := run([]string{"-offline", "-fixture", fixture, doc}, &stdout, &stderr); code != 0 {
+						t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+					}
+					if got := mustRead(t, doc); got != golden || !strings.Contains(stdout.String(), "already up to date") {
+						t.Errorf("expected an unchanged file, got %q: %s", got, stdout.String())
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestListColumnsAfterBOM(t *testing.T) {
+	isolateAuthEnv(t)
+	doc := filepath.Join(t.TempDir(), "list.md")
+	if err := os.WriteFile(doc, []byte("\ufeff[A](https://github.com/owner/a)\r\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"list", doc}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	if !strings.HasPrefix(stdout.String(), doc+":1:5: owner/a\n") {
+		t.Errorf("expected the byte order mark not to shift the column, got %q", stdout.String())
+	}
+}
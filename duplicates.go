@@ -0,0 +1,85 @@
+// Package main provides the core functionality for updating GitHub star counts in Markdown and AsciiDoc files.
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/stn1slv/github-markdown-stars-updater/pkg/stars"
+)
+
+// Supported values for the duplicate check.
+const (
+	duplicatesWarn  = "warn"
+	duplicatesError = "error"
+)
+
+// linkOccurrence is a repository link in one of the input documents.
+type linkOccurrence struct {
+	File string
+	stars.Location
+}
+
+// duplicate is a repository linked more than once, after URL normalisation.
+type duplicate struct {
+	Key         string
+	Occurrences []linkOccurrence
+}
+
+// findDuplicates returns the repositories linked more than once across files, in the order of
+// their first occurrence. Each occurrence is listed in file and document order.
+func findDuplicates(files []string, opts *options) ([]duplicate, error) {
+	links, err := findLinks(files, opts)
+	if err != nil {
+		return nil, err
+	}
+	var order []string
+	occurrences := make(map[string][]linkOccurrence)
+	for _, link := range links {
+		if _, seen := occurrences[link.Key]; !seen {
+			order = append(order, link.Key)
+		}
+		occurrences[link.Key] = append(occurrences[link.Key], link)
+	}
+
+	var duplicates []duplicate
+	for _, key := range order {
+		if len(occurrences[key]) > 1 {
+			duplicates = append(duplicates, duplicate{Key: key, Occurrences: occurrences[key]})
+		}
+	}
+	return duplicates, nil
+}
+
+// writeDuplicates prints every duplicate with the position of each of its links, e.g.
+//
+//	Warning: owner/repo is listed 2 times:
+//	  README.md:12:5: https://github.com/owner/repo
+//	  docs/tools.md:40:3: https://github.com/Owner/Repo.git
+func writeDuplicates(w io.Writer, prefix string, duplicates []duplicate) {
+	for _, d := range duplicates {
+		_, _ = fmt.Fprintf(w, "%s: %s is listed %d times:\n", prefix, d.Key, len(d.Occurrences))
+		for _, o := range d.Occurrences {
+			_, _ = fmt.Fprintf(w, "  %s:%d:%d: %s\n", o.File, o.Line, o.Column, o.URL)
+		}
+	}
+}
+
+// checkDuplicates reports the duplicates among files on stderr according to mode and returns the
+// exit code: 1 when mode is "error" and duplicates were found or the check failed.
+func checkDuplicates(files []string, opts *options, stderr io.Writer) int {
+	duplicates, err := findDuplicates(files, opts)
+	if err != nil {
+		_, _ = fmt.Fprintln(stderr, "Error:", err)
+		return 1
+	}
+	if opts.duplicates == duplicatesError {
+		writeDuplicates(stderr, "Error", duplicates)
+		if len(duplicates) > 0 {
+			return 1
+		}
+		return 0
+	}
+	writeDuplicates(stderr, "Warning", duplicates)
+	return 0
+}
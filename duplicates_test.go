@@ -0,0 +1,78 @@
+// Package main provides the core functionality for updating GitHub star counts in Markdown and AsciiDoc files.
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFindDuplicates(t *testing.T) {
+	dir := t.TempDir()
+	readme := filepath.Join(dir, "README.md")
+	writeFile(t, readme, "# Tools\n- [A](https://github.com/owner/a)\n- [B](https://github.com/owner/b)\n\n# More\n- [A again](https://github.com/Owner/A.git)\n")
+	guide := filepath.Join(dir, "guide.adoc")
+	writeFile(t, guide, "= Guide\n\n* https://github.com/owner/a/tree/main[A]\n* https://github.com/owner/c[C]\n")
+
+	duplicates, err := findDuplicates([]string{readme, guide}, &options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(duplicates) != 1 || duplicates[0].Key != "owner/a" {
+		t.Fatalf("expected owner/a to be the only duplicate, got %+v", duplicates)
+	}
+
+	var out bytes.Buffer
+	writeDuplicates(&out, "Warning", duplicates)
+	expected := "Warning: owner/a is listed 3 times:\n" +
+		"  " + readme + ":2:7: https://github.com/owner/a\n" +
+		"  " + readme + ":6:13: https://github.com/Owner/A.git\n" +
+		"  " + guide + ":3:3: https://github.com/owner/a/tree/main\n"
+	if out.String() != expected {
+		t.Errorf("expected %q, got %q", expected, out.String())
+	}
+}
+
+func TestRunDuplicatesError(t *testing.T) {
+	isolateAuthEnv(t)
+	t.Setenv("GITHUB_TOKEN", "test_token")
+	server := newFakeAPI(t, map[string]int{"owner/a": 10})
+
+	doc := filepath.Join(t.TempDir(), "list.md")
+	writeFile(t, doc, "- [A](https://github.com/owner/a)\n- [A](https://github.com/owner/a)\n")
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-api-url", server.URL, "-duplicates", "warn", doc}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0 for warnings, got %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "Warning: owner/a is listed 2 times") {
+		t.Errorf("expected a duplicate warning, got %q", stderr.String())
+	}
+
+	stderr.Reset()
+	if code := run([]string{"-api-url", server.URL, "-duplicates", "error", doc}, &stdout, &stderr); code != 1 {
+		t.Fatalf("expected exit code 1, got %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "Error: owner/a is listed 2 times") {
+		t.Errorf("expected a duplicate error, got %q", stderr.String())
+	}
+	if got := mustRead(t, doc); !strings.Contains(got, "(⭐10)") {
+		t.Errorf("expected the document to be updated anyway, got %q", got)
+	}
+}
+
+func TestFindDuplicatesAnnotatedLinks(t *testing.T) {
+	doc := filepath.Join(t.TempDir(), "list.md")
+	writeFile(t, doc, "- [A](https://github.com/owner/a)\n- [A pinned](https://github.com/owner/a) <!-- stars: pin 9999 -->\n"+
+		"- [A hidden](https://github.com/owner/a) <!-- stars: hide -->\n- [Fork](https://github.com/me/fork) <!-- stars: owner/a -->\n")
+
+	duplicates, err := findDuplicates([]string{doc}, &options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The redirected link shows the stars of owner/a but links to me/fork.
+	if len(duplicates) != 1 || duplicates[0].Key != "owner/a" || len(duplicates[0].Occurrences) != 3 {
+		t.Errorf("expected the three links to owner/a to be duplicates, got %+v", duplicates)
+	}
+}
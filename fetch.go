@@ -0,0 +1,186 @@
+// Package main provides the core functionality for updating GitHub star counts in Markdown and AsciiDoc files.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+	"github.com/stn1slv/github-markdown-stars-updater/pkg/stars"
+)
+
+// errNotFetched marks repositories that were excluded or failed to fetch.
+var errNotFetched = errors.New("not fetched")
+
+// errOffline is reported for repository metadata, which star fixtures do not provide.
+var errOffline = errors.New("repository metadata is not available offline")
+
+// errFixtureMetadata is reported for repository metadata of repositories listed in the fixture,
+// which only holds their star counts.
+var errFixtureMetadata = errors.New("the fixture only provides the star count, not the repository metadata")
+
+// infoDetail is how much repository data a run needs beyond the star count.
+type infoDetail int
+
+const (
+	detailStars    infoDetail = iota // star count only
+	detailActivity                   // push date, open issues, archive state and license
+	detailRelease                    // activity plus the latest release
+)
+
+// starFetcher fetches star counts, repository metadata and package metrics at most once per run,
+// across all processed files, and consults the cache before calling the APIs.
+type starFetcher struct {
+	// source provides the star counts; client is used for the repository metadata and is nil
+	// when running offline. fixture holds the -fixture counts, which take precedence over the API.
+	source  stars.StarFetcher
+	client  *github.Client
+	fixture stars.MapFetcher
+	metrics *MetricsClient
+	cache   *fetchCache
+	history *starHistory
+	warn    io.Writer
+	now     func() time.Time
+
+	info    map[string]repoInfo
+	fetched map[string]infoDetail
+	failed  map[string]bool
+}
+
+func newStarFetcher(client *github.Client, metrics *MetricsClient, cache *fetchCache, warn io.Writer) *starFetcher {
+	return &starFetcher{
+		source:  &stars.GitHubFetcher{Client: client},
+		client:  client,
+		metrics: metrics,
+		cache:   cache,
+		warn:    warn,
+		now:     time.Now,
+		info:    make(map[string]repoInfo),
+		fetched: make(map[string]infoDetail),
+		failed:  make(map[string]bool),
+	}
+}
+
+// fetchStars returns the star counts of repos keyed by canonical "owner/repo". Repositories
+// matching an exclude pattern are skipped, and failures are reported as warnings. Freshly fetched
+// counts are recorded in the history, if one is configured.
+func (f *starFetcher) fetchStars(ctx context.Context, repos []string, exclude []string) map[string]int {
+	return starsOf(f.fetchInfo(ctx, repos, exclude, detailStars))
+}
+
+// fetchInfo is fetchStars for callers that also need the repository metadata up to detail.
+// Repositories already fetched with less detail, by an earlier file or an earlier run, are
+// fetched again.
+func (f *starFetcher) fetchInfo(ctx context.Context, repos []string, exclude []string, detail infoDetail) map[string]repoInfo {
+	infos := make(map[string]repoInfo)
+	for _, repoURL := range repos {
+		key, ok := stars.NormalizeRepoURL(repoURL)
+		if !ok || isExcluded(exclude, key) {
+			continue
+		}
+		if got, done := f.fetched[key]; done && got >= detail {
+			infos[key] = f.info[key]
+			continue
+		}
+		if f.failed[key] {
+			continue
+		}
+		if info, hit := f.fromCache(key, detail); hit {
+			infos[key] = info
+			continue
+		}
+
+		info, err := f.fetch(ctx, key, repoURL, detail)
+		if err != nil {
+			f.failed[key] = true
+			_, _ = fmt.Fprintf(f.warn, "Warning: Could not fetch stars for %s: %v\n", repoURL, err)
+			continue
+		}
+		if detail == detailStars {
+			f.cache.put(key, info.Stars)
+		} else {
+			f.cache.putInfo(key, info)
+		}
+		f.history.record(key, f.now(), info.Stars)
+		f.remember(key, info, detail)
+		infos[key] = info
+	}
+	return infos
+}
+
+// fetch asks the star source for plain counts and the GitHub API for everything else. The
+// repositories listed in the fixture are never fetched from the API.
+func (f *starFetcher) fetch(ctx context.Context, key, repoURL string, detail infoDetail) (repoInfo, error) {
+	if detail == detailStars {
+		count, err := f.source.FetchStars(ctx, key)
+		return repoInfo{Stars: count}, err
+	}
+	if _, listed := f.fixture[key]; listed {
+		return repoInfo{}, errFixtureMetadata
+	}
+	if f.client == nil {
+		return repoInfo{}, errOffline
+	}
+	return getRepoInfo(ctx, f.client, repoURL, detail >= detailRelease)
+}
+
+// fromCache returns the cached data of key if it has at least the requested detail.
+func (f *starFetcher) fromCache(key string, detail infoDetail) (repoInfo, bool) {
+	if info, hit := f.cache.getInfo(key); hit {
+		got := detailActivity
+		if info.ReleaseChecked {
+			got = detailRelease
+		}
+		if got >= detail {
+			f.remember(key, info, got)
+			return info, true
+		}
+		return repoInfo{}, false
+	}
+	if detail > detailStars {
+		return repoInfo{}, false
+	}
+	count, hit := f.cache.get(key)
+	if !hit {
+		return repoInfo{}, false
+	}
+	info := repoInfo{Stars: count}
+	f.remember(key, info, detailStars)
+	return info, true
+}
+
+// forgetFailures lets the next fetchInfo retry the repositories that failed before, e.g. when a
+// watched document is saved again.
+func (f *starFetcher) forgetFailures() {
+	clear(f.failed)
+}
+
+func (f *starFetcher) remember(key string, info repoInfo, detail infoDetail) {
+	f.info[key] = info
+	f.fetched[key] = detail
+}
+
+// starsOf returns the star counts of infos.
+func starsOf(infos map[string]repoInfo) map[string]int {
+	stars := make(map[string]int, len(infos))
+	for key, info := range infos {
+		stars[key] = info.Stars
+	}
+	return stars
+}
+
+// fetchMetric returns a package registry metric, using the cache when possible.
+func (f *starFetcher) fetchMetric(ctx context.Context, ref PackageRef) (int, error) {
+	if value, hit := f.cache.get(ref.String()); hit {
+		return value, nil
+	}
+	value, err := f.metrics.Fetch(ctx, ref)
+	if err != nil {
+		return 0, err
+	}
+	f.cache.put(ref.String(), value)
+	return value, nil
+}
@@ -0,0 +1,109 @@
+// Package main provides the core functionality for updating GitHub star counts in Markdown and AsciiDoc files.
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/stn1slv/github-markdown-stars-updater/pkg/stars"
+)
+
+// defaultStaleMarker is appended to the label of repositories without a recent push.
+const defaultStaleMarker = "💤"
+
+// repoInfo is the repository data the labels and policies are built from.
+type repoInfo struct {
+	Stars      int       `json:"stars"`
+	PushedAt   time.Time `json:"pushed_at,omitzero"`
+	OpenIssues int       `json:"open_issues"`
+	Archived   bool      `json:"archived,omitempty"`
+	License    string    `json:"license,omitempty"`
+
+	// ReleaseChecked is set when the latest release was looked up; ReleaseTag stays empty when
+	// the repository has no releases.
+	ReleaseChecked bool      `json:"release_checked,omitempty"`
+	ReleaseTag     string    `json:"release_tag,omitempty"`
+	ReleaseDate    time.Time `json:"release_date,omitzero"`
+}
+
+// staleDirectiveRe matches the per-document stale setting, either as a Markdown/HTML comment
+// "<!-- stars:stale-after 6 -->" or as an AsciiDoc attribute ":stars-stale-after: 6".
+var staleDirectiveRe = regexp.MustCompile(`(?m)<!--\s*stars:stale-after\s+(\d+)\s*-->|^:stars-stale-after:\s*(\d+)\s*$`)
+
+// staleAfterDirective returns the number of months set by a stale directive in content.
+func staleAfterDirective(content string) (int, bool) {
+	match := staleDirectiveRe.FindStringSubmatch(content)
+	if match == nil {
+		return 0, false
+	}
+	value := match[1]
+	if value == "" {
+		value = match[2]
+	}
+	months, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, false
+	}
+	return months, true
+}
+
+// detailFor returns how much repository data the label template tmpl needs. A stale marker
+// needs the push date even when tmpl does not mention it.
+func detailFor(tmpl string, staleMonths int) infoDetail {
+	detail := detailStars
+	if staleMonths > 0 {
+		detail = detailActivity
+	}
+	for _, match := range placeholderRe.FindAllStringSubmatch(tmpl, -1) {
+		switch match[1] {
+		case "release", "release_date":
+			return detailRelease
+		case "pushed", "issues", "stale":
+			detail = detailActivity
+		}
+	}
+	return detail
+}
+
+// newFreshnessFields returns the {pushed}, {release}, {release_date}, {issues} and {stale} label
+// fields for the repository data in info. Repositories whose last push is more than staleMonths
+// months before now get marker as their {stale} field; staleMonths 0 disables the marker.
+func newFreshnessFields(info map[string]repoInfo, now time.Time, staleMonths int, marker string) map[string]labelField {
+	return map[string]labelField{
+		"pushed": func(key string, _ int) string {
+			if pushed := info[key].PushedAt; !pushed.IsZero() {
+				return "pushed " + pushed.UTC().Format(historyDateLayout)
+			}
+			return ""
+		},
+		"release": func(key string, _ int) string {
+			return info[key].ReleaseTag
+		},
+		"release_date": func(key string, _ int) string {
+			if date := info[key].ReleaseDate; info[key].ReleaseTag != "" && !date.IsZero() {
+				return date.UTC().Format(historyDateLayout)
+			}
+			return ""
+		},
+		"issues": func(key string, _ int) string {
+			if ri, ok := info[key]; ok {
+				return fmt.Sprintf("%s open issues", stars.FormatStarCount(ri.OpenIssues))
+			}
+			return ""
+		},
+		"stale": func(key string, _ int) string {
+			if !isStale(info[key], now, staleMonths) {
+				return ""
+			}
+			return marker
+		},
+	}
+}
+
+// isStale reports whether the last push to the repository was more than months months before
+// now. It is always false when months is 0 or the push date is unknown.
+func isStale(info repoInfo, now time.Time, months int) bool {
+	return months > 0 && !info.PushedAt.IsZero() && !info.PushedAt.After(now.AddDate(0, -months, 0))
+}
@@ -0,0 +1,162 @@
+// Package main provides the core functionality for updating GitHub star counts in Markdown and AsciiDoc files.
+package main
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetRepoInfo(t *testing.T) {
+	client := newRepoInfoAPI(t).client()
+	ctx := context.Background()
+
+	info, err := getRepoInfo(ctx, client, "https://github.com/owner/active", true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info.Stars != 1234 || info.OpenIssues != 12 || info.License != "MIT" || info.ReleaseTag != "v1.2.3" || !info.ReleaseChecked {
+		t.Errorf("unexpected info: %+v", info)
+	}
+	if !info.PushedAt.Equal(activePushedAt) {
+		t.Errorf("expected push date %v, got %v", activePushedAt, info.PushedAt)
+	}
+
+	info, err = getRepoInfo(ctx, client, "https://github.com/owner/dormant", true)
+	if err != nil {
+		t.Fatalf("expected a repository without releases to succeed, got %v", err)
+	}
+	if !info.Archived || info.ReleaseTag != "" || !info.ReleaseChecked {
+		t.Errorf("unexpected info: %+v", info)
+	}
+}
+
+func TestFreshnessFields(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	infos := map[string]repoInfo{
+		"owner/active":  {Stars: 10, PushedAt: now.AddDate(0, -1, 0), OpenIssues: 1500, ReleaseTag: "v2.0.0", ReleaseDate: now.AddDate(0, 0, -3)},
+		"owner/dormant": {Stars: 10, PushedAt: now.AddDate(0, -7, 0)},
+	}
+	fields := newFreshnessFields(infos, now, 6, "(stale)")
+
+	tests := []struct {
+		field    string
+		key      string
+		expected string
+	}{
+		{"pushed", "owner/active", "pushed 2026-09-19"},
+		{"release", "owner/active", "v2.0.0"},
+		{"release_date", "owner/active", "2026-10-16"},
+		{"release_date", "owner/dormant", ""},
+		{"issues", "owner/active", "1.5k open issues"},
+		{"issues", "owner/unknown", ""},
+		{"stale", "owner/active", ""},
+		{"stale", "owner/dormant", "(stale)"},
+		{"stale", "owner/unknown", ""},
+	}
+	for _, tt := range tests {
+		if got := fields[tt.field](tt.key, 10); got != tt.expected {
+			t.Errorf("{%s} of %s: expected %q, got %q", tt.field, tt.key, tt.expected, got)
+		}
+	}
+
+	if got := newFreshnessFields(infos, now, 0, "x")["stale"]("owner/dormant", 10); got != "" {
+		t.Errorf("expected no stale marker when disabled, got %q", got)
+	}
+}
+
+func TestStaleAfterDirective(t *testing.T) {
+	tests := []struct {
+		content  string
+		expected int
+		ok       bool
+	}{
+		{"# List\n<!-- stars:stale-after 6 -->\n", 6, true},
+		{"= List\n:stars-stale-after: 12\n", 12, true},
+		{"<!--stars:stale-after 0-->", 0, true},
+		{"No directive here", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := staleAfterDirective(tt.content)
+		if got != tt.expected || ok != tt.ok {
+			t.Errorf("For %q, expected %d, %v, got %d, %v", tt.content, tt.expected, tt.ok, got, ok)
+		}
+	}
+}
+
+func TestDetailFor(t *testing.T) {
+	tests := []struct {
+		template string
+		stale    int
+		expected infoDetail
+	}{
+		{"⭐{stars}", 0, detailStars},
+		{"⭐{stars}", 6, detailActivity},
+		{"⭐{stars} · {issues}", 0, detailActivity},
+		{"⭐{stars} · {release}", 0, detailRelease},
+	}
+	for _, tt := range tests {
+		if got := detailFor(tt.template, tt.stale); got != tt.expected {
+			t.Errorf("For %q, expected %d, got %d", tt.template, tt.expected, got)
+		}
+	}
+}
+
+func TestRunWithFreshnessLabel(t *testing.T) {
+	isolateAuthEnv(t)
+	t.Setenv("GITHUB_TOKEN", "test_token")
+	server := newRepoInfoAPI(t)
+
+	dir := t.TempDir()
+	cachePath := filepath.Join(dir, "cache.json")
+	doc := filepath.Join(dir, "list.md")
+	writeFile(t, doc, "<!-- stars:stale-after 12 -->\n- [Active](https://github.com/owner/active)\n- [Dormant (⭐40)](https://github.com/owner/dormant)\n")
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"-api-url", server.URL, "-cache", cachePath, "-label", "⭐{stars} {stale} · {release}", doc}
+	if code := run(args, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	expected := "<!-- stars:stale-after 12 -->\n- [Active (⭐1.2k · v1.2.3)](https://github.com/owner/active)\n- [Dormant (⭐40 💤)](https://github.com/owner/dormant)\n"
+	if got := mustRead(t, doc); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+
+	// The cached metadata renders the same label without the API.
+	server.Close()
+	if code := run(args, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	if got := mustRead(t, doc); got != expected {
+		t.Errorf("expected the cached metadata to be reused, got %q", got)
+	}
+}
+
+func TestRunWithFreshnessLabelAndFixture(t *testing.T) {
+	isolateAuthEnv(t)
+	t.Setenv("GITHUB_TOKEN", "test_token")
+	server := newRepoInfoAPI(t)
+
+	dir := t.TempDir()
+	fixture := filepath.Join(dir, "stars.json")
+	writeFile(t, fixture, `{"owner/active": 5}`)
+	doc := filepath.Join(dir, "list.md")
+	writeFile(t, doc, "- [Active](https://github.com/owner/active)\n- [Dormant](https://github.com/owner/dormant)\n")
+
+	// The repository listed in the fixture is not fetched from the API, so it gets no label.
+	var stdout, stderr bytes.Buffer
+	args := []string{"-api-url", server.URL, "-fixture", fixture, "-label", "⭐{stars} {pushed}", doc}
+	if code := run(args, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	expected := "- [Active](https://github.com/owner/active)\n- [Dormant (⭐40 pushed 2024-01-02)](https://github.com/owner/dormant)\n"
+	if got := mustRead(t, doc); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+	if !strings.Contains(stderr.String(), "owner/active: the fixture only provides the star count") {
+		t.Errorf("expected a warning about the fixture, got %q", stderr.String())
+	}
+}
@@ -0,0 +1,220 @@
+// Package main provides the core functionality for updating GitHub star counts in Markdown and AsciiDoc files.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// isolateAuthEnv clears every source of the authentication chain so tests only see what they set up.
+func isolateAuthEnv(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range []string{"GITHUB_TOKENS", "GITHUB_TOKEN", "GH_TOKEN", "GITHUB_APP_ID", "GITHUB_APP_PRIVATE_KEY_FILE", "GITHUB_APP_INSTALLATION_ID", "XDG_CONFIG_HOME"} {
+		t.Setenv(name, "")
+	}
+	t.Setenv("HOME", dir)
+	t.Setenv("GH_CONFIG_DIR", filepath.Join(dir, "gh"))
+	t.Setenv("NETRC", filepath.Join(dir, ".netrc"))
+	return dir
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func mustRead(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// fakeAPI is a GitHub API stand-in. It serves the repositories in repos, counts the requests, and
+// records the Git data and pull request calls of the action command on the repository me/docs.
+type fakeAPI struct {
+	*httptest.Server
+	requests atomic.Int32
+
+	mu        sync.Mutex
+	repos     map[string]string // JSON of GET /repos/owner/repo by "owner/repo"
+	releases  map[string]string // JSON of the latest release by "owner/repo"
+	trees     []map[string]any
+	commits   []map[string]any
+	refs      []string // "METHOD ref sha force"
+	headRef   bool     // whether the pull request branch exists
+	openPR    bool
+	prUpdates []string // "METHOD title"
+	truncated bool     // whether the recursive listing of base-tree is truncated
+}
+
+// newFakeAPI returns a GitHub API stand-in serving the given star counts.
+func newFakeAPI(t *testing.T, counts map[string]int) *fakeAPI {
+	t.Helper()
+	api := &fakeAPI{repos: map[string]string{}, releases: map[string]string{}}
+	for repo, count := range counts {
+		api.repos[repo] = fmt.Sprintf(`{"stargazers_count": %d}`, count)
+	}
+	decode := func(r *http.Request) map[string]any {
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("invalid request body: %v", err)
+		}
+		return body
+	}
+	serve := func(w http.ResponseWriter, r *http.Request, docs map[string]string) {
+		api.mu.Lock()
+		doc, ok := docs[r.PathValue("owner")+"/"+r.PathValue("name")]
+		api.mu.Unlock()
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = fmt.Fprint(w, doc)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/{owner}/{name}", func(w http.ResponseWriter, r *http.Request) {
+		serve(w, r, api.repos)
+	})
+	mux.HandleFunc("GET /repos/{owner}/{name}/releases/latest", func(w http.ResponseWriter, r *http.Request) {
+		serve(w, r, api.releases)
+	})
+	mux.HandleFunc("GET /repos/me/docs/git/commits/base-sha", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = fmt.Fprint(w, `{"sha": "base-sha", "tree": {"sha": "base-tree"}}`)
+	})
+	mux.HandleFunc("GET /repos/me/docs/git/trees/base-tree", func(w http.ResponseWriter, r *http.Request) {
+		api.mu.Lock()
+		defer api.mu.Unlock()
+		switch {
+		case r.URL.Query().Get("recursive") == "":
+			_, _ = fmt.Fprint(w, `{"sha": "base-tree", "tree": [{"path": "README.md", "mode": "100755", "type": "blob"},
+				{"path": "docs", "mode": "040000", "type": "tree", "sha": "docs-tree"}]}`)
+		case api.truncated:
+			_, _ = fmt.Fprint(w, `{"sha": "base-tree", "tree": [], "truncated": true}`)
+		default:
+			_, _ = fmt.Fprint(w, `{"sha": "base-tree", "tree": [{"path": "README.md", "mode": "100755", "type": "blob"},
+				{"path": "docs", "mode": "040000", "type": "tree"}, {"path": "docs/other.md", "mode": "100644", "type": "blob"}]}`)
+		}
+	})
+	mux.HandleFunc("GET /repos/me/docs/git/trees/docs-tree", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = fmt.Fprint(w, `{"sha": "docs-tree", "tree": [{"path": "other.md", "mode": "100755", "type": "blob"}]}`)
+	})
+	mux.HandleFunc("POST /repos/me/docs/git/trees", func(w http.ResponseWriter, r *http.Request) {
+		api.mu.Lock()
+		defer api.mu.Unlock()
+		api.trees = append(api.trees, decode(r))
+		w.WriteHeader(http.StatusCreated)
+		_, _ = fmt.Fprint(w, `{"sha": "new-tree"}`)
+	})
+	mux.HandleFunc("POST /repos/me/docs/git/commits", func(w http.ResponseWriter, r *http.Request) {
+		api.mu.Lock()
+		defer api.mu.Unlock()
+		api.commits = append(api.commits, decode(r))
+		w.WriteHeader(http.StatusCreated)
+		_, _ = fmt.Fprint(w, `{"sha": "new-sha"}`)
+	})
+	mux.HandleFunc("GET /repos/me/docs/git/ref/heads/{branch...}", func(w http.ResponseWriter, r *http.Request) {
+		api.mu.Lock()
+		defer api.mu.Unlock()
+		switch branch := r.PathValue("branch"); {
+		case branch == "main":
+			_, _ = fmt.Fprint(w, `{"ref": "refs/heads/main", "object": {"sha": "base-sha"}}`)
+		case branch == defaultPRBranch && api.headRef:
+			_, _ = fmt.Fprintf(w, `{"ref": "refs/heads/%s", "object": {"sha": "old-sha"}}`, branch)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	mux.HandleFunc("PATCH /repos/me/docs/git/refs/heads/{branch...}", func(w http.ResponseWriter, r *http.Request) {
+		api.mu.Lock()
+		defer api.mu.Unlock()
+		body := decode(r)
+		api.refs = append(api.refs, fmt.Sprintf("PATCH %s %v %v", r.PathValue("branch"), body["sha"], body["force"]))
+		_, _ = fmt.Fprint(w, `{}`)
+	})
+	mux.HandleFunc("POST /repos/me/docs/git/refs", func(w http.ResponseWriter, r *http.Request) {
+		api.mu.Lock()
+		defer api.mu.Unlock()
+		body := decode(r)
+		api.refs = append(api.refs, fmt.Sprintf("POST %v %v", body["ref"], body["sha"]))
+		w.WriteHeader(http.StatusCreated)
+		_, _ = fmt.Fprint(w, `{}`)
+	})
+	mux.HandleFunc("GET /repos/me/docs/pulls", func(w http.ResponseWriter, r *http.Request) {
+		api.mu.Lock()
+		defer api.mu.Unlock()
+		if r.URL.Query().Get("head") != "me:"+defaultPRBranch || r.URL.Query().Get("base") != "main" {
+			t.Errorf("unexpected pull request query %s", r.URL.RawQuery)
+		}
+		if api.openPR {
+			_, _ = fmt.Fprint(w, `[{"number": 7}]`)
+			return
+		}
+		_, _ = fmt.Fprint(w, `[]`)
+	})
+	mux.HandleFunc("POST /repos/me/docs/pulls", func(w http.ResponseWriter, r *http.Request) {
+		api.mu.Lock()
+		defer api.mu.Unlock()
+		body := decode(r)
+		api.prUpdates = append(api.prUpdates, fmt.Sprintf("POST %v", body["title"]))
+		w.WriteHeader(http.StatusCreated)
+		_, _ = fmt.Fprint(w, `{"number": 7, "html_url": "https://github.com/me/docs/pull/7"}`)
+	})
+	mux.HandleFunc("PATCH /repos/me/docs/pulls/7", func(w http.ResponseWriter, r *http.Request) {
+		api.mu.Lock()
+		defer api.mu.Unlock()
+		body := decode(r)
+		api.prUpdates = append(api.prUpdates, fmt.Sprintf("PATCH %v", body["title"]))
+		_, _ = fmt.Fprint(w, `{"number": 7, "html_url": "https://github.com/me/docs/pull/7"}`)
+	})
+
+	api.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		api.requests.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(api.Close)
+	return api
+}
+
+// client returns a GitHub client for the fake API.
+func (api *fakeAPI) client() *github.Client {
+	client := github.NewClient(api.Server.Client())
+	client.BaseURL, _ = url.Parse(api.URL + "/")
+	return client
+}
+
+// activePushedAt is the last push of owner/active in newRepoInfoAPI.
+var activePushedAt = time.Now().UTC().AddDate(0, 0, -10).Truncate(time.Second)
+
+// newRepoInfoAPI returns a fake API with owner/active, which has a release, and owner/dormant,
+// which has none.
+func newRepoInfoAPI(t *testing.T) *fakeAPI {
+	t.Helper()
+	api := newFakeAPI(t, nil)
+	api.repos["owner/active"] = fmt.Sprintf(`{"stargazers_count": 1234, "pushed_at": %q, "open_issues_count": 12,
+		"license": {"spdx_id": "MIT"}}`, activePushedAt.Format(time.RFC3339))
+	api.repos["owner/dormant"] = `{"stargazers_count": 40, "pushed_at": "2024-01-02T10:00:00Z", "archived": true}`
+	api.releases["owner/active"] = `{"tag_name": "v1.2.3", "published_at": "2026-08-14T09:00:00Z"}`
+	return api
+}
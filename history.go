@@ -0,0 +1,178 @@
+// Package main provides the core functionality for updating GitHub star counts in Markdown and AsciiDoc files.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/stn1slv/github-markdown-stars-updater/pkg/stars"
+)
+
+const (
+	historyDateLayout = "2006-01-02"
+
+	// defaultTrendDays is the trend window used when none is configured.
+	defaultTrendDays = 30
+)
+
+// historyPoint is the star count of a repository on a given day.
+type historyPoint struct {
+	Date  string `json:"date"`
+	Stars int    `json:"stars"`
+}
+
+// starHistory records star counts per repository per run date, keyed by canonical "owner/repo".
+type starHistory struct {
+	path  string
+	repos map[string][]historyPoint
+	dirty bool
+}
+
+// loadHistory reads the history file at path. A missing file yields an empty history.
+func loadHistory(path string) (*starHistory, error) {
+	h := &starHistory{path: path, repos: make(map[string][]historyPoint)}
+
+	data, err := os.ReadFile(filepath.Clean(path))
+	if errors.Is(err, os.ErrNotExist) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var file struct {
+		Repos map[string][]historyPoint `json:"repos"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse history %s: %w", path, err)
+	}
+	if file.Repos != nil {
+		h.repos = file.Repos
+	}
+	return h, nil
+}
+
+// record stores the star count of key for the day of at, replacing an earlier value of the same day.
+// It is a no-op on a nil history.
+func (h *starHistory) record(key string, at time.Time, stars int) {
+	if h == nil {
+		return
+	}
+	date := at.UTC().Format(historyDateLayout)
+	points := h.repos[key]
+	i := sort.Search(len(points), func(i int) bool { return points[i].Date >= date })
+	switch {
+	case i < len(points) && points[i].Date == date:
+		if points[i].Stars == stars {
+			return
+		}
+		points[i].Stars = stars
+	default:
+		points = append(points, historyPoint{})
+		copy(points[i+1:], points[i:])
+		points[i] = historyPoint{Date: date, Stars: stars}
+	}
+	h.repos[key] = points
+	h.dirty = true
+}
+
+// baseline returns the most recent recorded point at least days old at now. When the history does
+// not reach back that far, the oldest point before today is used instead. It also returns the age
+// of the point in days.
+func (h *starHistory) baseline(key string, now time.Time, days int) (historyPoint, int, bool) {
+	if h == nil {
+		return historyPoint{}, 0, false
+	}
+	today := now.UTC().Format(historyDateLayout)
+	cutoff := now.UTC().AddDate(0, 0, -days).Format(historyDateLayout)
+
+	var found historyPoint
+	ok := false
+	for _, p := range h.repos[key] {
+		if p.Date > cutoff {
+			break
+		}
+		found, ok = p, true
+	}
+	if !ok {
+		points := h.repos[key]
+		if len(points) == 0 || points[0].Date >= today {
+			return historyPoint{}, 0, false
+		}
+		found = points[0]
+	}
+
+	date, err := time.Parse(historyDateLayout, found.Date)
+	if err != nil {
+		return historyPoint{}, 0, false
+	}
+	todayDate, _ := time.Parse(historyDateLayout, today)
+	return found, int(todayDate.Sub(date).Hours() / 24), true //nolint:mnd
+}
+
+// since returns the recorded points of key from the day of from on, oldest first.
+func (h *starHistory) since(key string, from time.Time) []historyPoint {
+	if h == nil {
+		return nil
+	}
+	date := from.UTC().Format(historyDateLayout)
+	points := h.repos[key]
+	i := sort.Search(len(points), func(i int) bool { return points[i].Date >= date })
+	return points[i:]
+}
+
+// save writes the history back to disk if anything changed.
+func (h *starHistory) save() error {
+	if h == nil || !h.dirty {
+		return nil
+	}
+	data, err := json.MarshalIndent(struct {
+		Repos map[string][]historyPoint `json:"repos"`
+	}{h.repos}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(h.path); dir != "" {
+		if err := os.MkdirAll(dir, 0o750); err != nil {
+			return err
+		}
+	}
+	// The history is meant to be committed alongside the documents.
+	if err := os.WriteFile(h.path, append(data, '\n'), 0o644); err != nil { //nolint:gosec
+		return err
+	}
+	h.dirty = false
+	return nil
+}
+
+// newTrendField returns the {trend} label field, e.g. "↑140 in 30d". The change is measured against
+// the recorded count at least days old; changes smaller than minDelta render empty.
+func newTrendField(h *starHistory, now time.Time, days, minDelta int) labelField {
+	return func(key string, count int) string {
+		base, age, ok := h.baseline(key, now, days)
+		if !ok {
+			return ""
+		}
+		delta := count - base.Stars
+		if abs(delta) < minDelta || delta == 0 {
+			return ""
+		}
+		arrow := "↑"
+		if delta < 0 {
+			arrow = "↓"
+		}
+		return fmt.Sprintf("%s%s in %dd", arrow, stars.FormatStarCount(abs(delta)), age)
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,92 @@
+// Package main provides the core functionality for updating GitHub star counts in Markdown and AsciiDoc files.
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStarHistoryRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.json")
+	h, err := loadHistory(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	day := time.Date(2026, 3, 10, 8, 0, 0, 0, time.UTC)
+	h.record("owner/repo", day, 100)
+	h.record("owner/repo", day.AddDate(0, 0, -5), 90)
+	h.record("owner/repo", day.Add(6*time.Hour), 105) // same day: replaces the first value
+	if err := h.save(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	reloaded, err := loadHistory(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []historyPoint{{"2026-03-05", 90}, {"2026-03-10", 105}}
+	got := reloaded.repos["owner/repo"]
+	if len(got) != len(expected) || got[0] != expected[0] || got[1] != expected[1] {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestTrendField(t *testing.T) {
+	now := time.Date(2026, 3, 31, 12, 0, 0, 0, time.UTC)
+	h := &starHistory{repos: map[string][]historyPoint{
+		"owner/growing": {{"2026-02-01", 800}, {"2026-03-01", 1000}, {"2026-03-20", 1100}},
+		"owner/young":   {{"2026-03-21", 50}},
+		"owner/dying":   {{"2026-03-01", 5000}},
+		"owner/flat":    {{"2026-03-01", 10}},
+		"owner/today":   {{"2026-03-31", 10}},
+	}}
+	trend := newTrendField(h, now, 30, 5)
+
+	tests := []struct {
+		key      string
+		stars    int
+		expected string
+	}{
+		{"owner/growing", 1140, "↑140 in 30d"},
+		{"owner/young", 60, "↑10 in 10d"},
+		{"owner/dying", 3800, "↓1.2k in 30d"},
+		{"owner/flat", 12, ""},
+		{"owner/today", 100, ""},
+		{"owner/unknown", 100, ""},
+	}
+	for _, tt := range tests {
+		if got := trend(tt.key, tt.stars); got != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.key, tt.expected, got)
+		}
+	}
+}
+
+func TestRunRecordsHistoryAndShowsTrend(t *testing.T) {
+	isolateAuthEnv(t)
+	t.Setenv("GITHUB_TOKEN", "test_token")
+	server := newFakeAPI(t, map[string]int{"owner/a": 1340})
+
+	dir := t.TempDir()
+	historyPath := filepath.Join(dir, "history.json")
+	past := time.Now().UTC().AddDate(0, 0, -30).Format(historyDateLayout)
+	writeFile(t, historyPath, `{"repos": {"owner/a": [{"date": "`+past+`", "stars": 1200}]}}`)
+	doc := filepath.Join(dir, "list.md")
+	writeFile(t, doc, "- [A (⭐1.2k)](https://github.com/owner/a)\n")
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-api-url", server.URL, "-history", historyPath, "-trend", doc}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	if got := mustRead(t, doc); got != "- [A (⭐1.3k ↑140 in 30d)](https://github.com/owner/a)\n" {
+		t.Errorf("unexpected document: %q", got)
+	}
+	today := time.Now().UTC().Format(historyDateLayout)
+	if got := mustRead(t, historyPath); !strings.Contains(got, today) || !strings.Contains(got, "1340") {
+		t.Errorf("expected today's count to be recorded, got %s", got)
+	}
+}
@@ -0,0 +1,116 @@
+// Package main provides the core functionality for updating GitHub star counts in Markdown and AsciiDoc files.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/stn1slv/github-markdown-stars-updater/pkg/stars"
+)
+
+// defaultLabelTemplate renders the plain star count, e.g. "⭐1.2k".
+const defaultLabelTemplate = "⭐{stars}"
+
+// labelField renders one {placeholder} of a label template for the repository with the given
+// canonical key. Optional fields return "" when they have nothing to show.
+type labelField func(key string, stars int) string
+
+// labelPlaceholders lists the placeholders a label template may use.
+var labelPlaceholders = map[string]string{
+	"stars":   "formatted star count, e.g. 1.2k",
+	"count":   "exact star count, e.g. 1234",
+	"metrics": "package registry metrics, e.g. npm 25k/wk",
+	"trend":   "star change over the trend window, e.g. ↑140 in 30d",
+
+	"pushed":       "date of the last push, e.g. pushed 2026-09-30",
+	"release":      "latest release tag, e.g. v1.2.3",
+	"release_date": "publication date of the latest release, e.g. 2026-08-14",
+	"issues":       "open issue count, e.g. 12 open issues",
+	"stale":        "marker shown when the last push is older than the stale period",
+}
+
+// defaultTemplateFor returns the default label template extended with the given optional fields.
+// The trend and the stale marker directly follow the count; other fields are separated by " · ".
+func defaultTemplateFor(fields ...string) string {
+	tmpl := defaultLabelTemplate
+	for _, name := range fields {
+		if name == "trend" || name == "stale" {
+			tmpl += " {" + name + "}"
+		} else {
+			tmpl += " · {" + name + "}"
+		}
+	}
+	return tmpl
+}
+
+var (
+	placeholderRe = regexp.MustCompile(`\{([a-z_]+)\}`)
+	labelSpaceRe  = regexp.MustCompile(` {2,}`)
+)
+
+// validateLabelTemplate checks that tmpl only uses known placeholders and keeps the label
+// replaceable by the updaters on the next run.
+func validateLabelTemplate(tmpl string) error {
+	if !strings.HasPrefix(tmpl, "⭐") {
+		return errors.New("label template must start with ⭐ so that existing labels can be replaced")
+	}
+	if strings.ContainsAny(tmpl, ")]") {
+		return errors.New("label template must not contain ')' or ']'")
+	}
+	for _, match := range placeholderRe.FindAllStringSubmatch(tmpl, -1) {
+		if _, ok := labelPlaceholders[match[1]]; !ok {
+			return fmt.Errorf("unknown placeholder {%s}", match[1])
+		}
+	}
+	return nil
+}
+
+// newLabelFunc returns a LabelFunc rendering tmpl. The stars and count placeholders are always
+// available; fields provides the optional ones. Placeholders without a field render empty, and
+// the spaces and " · " separators around empty values are cleaned up. Repositories with fewer
+// than minStars stars get no label.
+func newLabelFunc(tmpl string, fields map[string]labelField, minStars int) (stars.LabelFunc, error) {
+	if tmpl == "" {
+		tmpl = defaultLabelTemplate
+	}
+	if err := validateLabelTemplate(tmpl); err != nil {
+		return nil, err
+	}
+
+	return func(repoURL string, count int) string {
+		if count < minStars {
+			return ""
+		}
+		key := stars.RepoKey(repoURL)
+		label := placeholderRe.ReplaceAllStringFunc(tmpl, func(ph string) string {
+			name := ph[1 : len(ph)-1]
+			switch name {
+			case "stars":
+				return stars.FormatStarCount(count)
+			case "count":
+				return strconv.Itoa(count)
+			}
+			if field, ok := fields[name]; ok {
+				return field(key, count)
+			}
+			return ""
+		})
+		return cleanLabel(label)
+	}, nil
+}
+
+// cleanLabel removes separators and spaces left behind by empty optional fields.
+func cleanLabel(label string) string {
+	for {
+		cleaned := strings.ReplaceAll(label, " ·  · ", " · ")
+		cleaned = strings.TrimSuffix(strings.TrimRight(cleaned, " "), " ·")
+		if cleaned == label {
+			break
+		}
+		label = cleaned
+	}
+	return strings.TrimSpace(labelSpaceRe.ReplaceAllString(label, " "))
+}
@@ -0,0 +1,163 @@
+// Package main provides the core functionality for updating GitHub star counts in Markdown and AsciiDoc files.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/stn1slv/github-markdown-stars-updater/pkg/stars"
+)
+
+// streamThreshold is the document size from which documents are updated block by block instead
+// of in memory. A variable so that tests can lower it.
+var streamThreshold int64 = 8 << 20
+
+// isLargeDocument reports whether the document at path is processed by processLargeFile. Package
+// metrics resolve the links of the whole document at once, and summary tables and leaderboards
+// are built from it, so documents using them keep the in-memory path.
+func isLargeDocument(path string, opts *options) bool {
+	if opts.metrics {
+		return false
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.Size() < streamThreshold {
+		return false
+	}
+	format, err := formatOf(path, opts)
+	return err != nil || !hasGeneratedBlocks(path, format)
+}
+
+// errBlockFound stops the scan of hasGeneratedBlocks at the first start marker.
+var errBlockFound = errors.New("generated block found")
+
+// hasGeneratedBlocks reports whether the document at path has a "stars:summary" or "stars:top"
+// start marker. Markers in code blocks may be reported too, which only costs the streaming.
+func hasGeneratedBlocks(path string, format stars.Format) bool {
+	markerRe := markdownMarkerRe
+	if format == stars.FormatASCIIDoc {
+		markerRe = asciidocMarkerRe
+	}
+	err := scanDocument(path, func(block string) error {
+		for line := range strings.Lines(block) {
+			match := markerRe.FindStringSubmatch(strings.TrimRight(line, "\r\n"))
+			if match != nil && (match[1] == blockSummary || match[1] == blockTop) {
+				return errBlockFound
+			}
+		}
+		return nil
+	})
+	return errors.Is(err, errBlockFound)
+}
+
+// processLargeFile updates the star counts of a document too large to hold in memory. It reads the
+// document twice, block by block: once to collect the repositories and fetch their data, and once
+// to rewrite the labels into the output. The byte order mark and line endings are copied as they
+// are.
+func processLargeFile(ctx context.Context, filePath string, opts *options, fetcher *starFetcher, stdout io.Writer) error {
+	format, err := formatOf(filePath, opts)
+	if err != nil {
+		return err
+	}
+	finder, err := stars.NewUpdater(format, nil)
+	if err != nil {
+		return err
+	}
+
+	// 1. Find Repos and the stale directive
+	var repos []string
+	seen := make(map[string]bool)
+	staleAfter, directive := opts.staleAfter, false
+	err = scanDocument(filePath, func(block string) error {
+		found, err := finder.FindRepos(block)
+		if err != nil {
+			return err
+		}
+		for _, repoURL := range found {
+			if key, _ := stars.NormalizeRepoURL(repoURL); !seen[key] {
+				seen[key] = true
+				repos = append(repos, repoURL)
+			}
+		}
+		if months, ok := staleAfterDirective(strings.TrimPrefix(block, utf8BOM)); ok && !directive {
+			staleAfter, directive = months, true
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("finding repositories in %s: %w", filePath, err)
+	}
+
+	// 2. Fetch Stars and build the label
+	template := labelTemplate(opts, staleAfter)
+	infos := fetcher.fetchInfo(ctx, repos, opts.exclude, detailFor(template, staleAfter))
+	label, err := newLabelFunc(template, labelFields(opts, fetcher, infos, staleAfter), opts.minStars)
+	if err != nil {
+		return err
+	}
+	output := filePath
+	if opts.outPath != "" {
+		output = opts.outPath
+	}
+	images := newDocumentImages(output)
+	updater, err := stars.NewUpdaterWith(format, stars.WithLabel(label), stars.WithBadge(badgeFunc(opts, images)),
+		stars.WithChart(sparklineCharts(opts, fetcher.history, infos, fetcher.now(), images)))
+	if err != nil {
+		return err
+	}
+	counts := make(map[string]int, len(infos))
+	for key, info := range infos {
+		counts[key] = info.Stars
+	}
+
+	// 3. Rewrite the document block by block
+	rewrite := func(w io.Writer) (bool, error) {
+		f, err := os.Open(filepath.Clean(filePath))
+		if err != nil {
+			return false, err
+		}
+		defer func() { _ = f.Close() }()
+		changed, err := stars.UpdateBlocks(f, w, updater, counts)
+		if err != nil {
+			return false, fmt.Errorf("updating content of %s: %w", filePath, err)
+		}
+		return changed, nil
+	}
+
+	if opts.dryRun {
+		_, err := rewrite(stdout)
+		return err
+	}
+
+	written, err := writeStreamAtomic(output, opts.backup, func(w io.Writer) (bool, error) {
+		changed, err := rewrite(w)
+		// A separate output file is written even when the labels are up to date.
+		return changed || output != filePath, err
+	})
+	if err != nil {
+		return fmt.Errorf("writing updated file: %w", err)
+	}
+	if err := images.write(); err != nil {
+		return err
+	}
+	if !written {
+		_, _ = fmt.Fprintf(stdout, "File %s is already up to date.\n", output)
+		return nil
+	}
+	_, _ = fmt.Fprintf(stdout, "File %s updated successfully.\n", output)
+	return nil
+}
+
+// scanDocument calls fn with the blocks of the document at path, as stars.ScanBlocks splits it.
+func scanDocument(path string, fn func(block string) error) error {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	return stars.ScanBlocks(f, fn)
+}
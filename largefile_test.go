@@ -0,0 +1,127 @@
+// Package main provides the core functionality for updating GitHub star counts in Markdown and AsciiDoc files.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// setStreamThreshold sets the size from which documents are streamed for the duration of t.
+func setStreamThreshold(t *testing.T, threshold int64) {
+	t.Helper()
+	previous := streamThreshold
+	streamThreshold = threshold
+	t.Cleanup(func() { streamThreshold = previous })
+}
+
+func TestIsLargeDocument(t *testing.T) {
+	setStreamThreshold(t, 10)
+	dir := t.TempDir()
+	small, large := filepath.Join(dir, "small.md"), filepath.Join(dir, "large.md")
+	writeFile(t, small, "short")
+	writeFile(t, large, "long enough")
+
+	if isLargeDocument(small, &options{}) || !isLargeDocument(large, &options{}) {
+		t.Error("expected only the document above the threshold to be large")
+	}
+	if isLargeDocument(large, &options{metrics: true}) {
+		t.Error("expected package metrics to keep the in-memory path")
+	}
+	if isLargeDocument(filepath.Join(dir, "missing.md"), &options{}) {
+		t.Error("expected a missing document not to be large")
+	}
+
+	blocks := filepath.Join(dir, "blocks.adoc")
+	writeFile(t, blocks, "= List\n\n// stars:top 3\n// stars:end\n")
+	if isLargeDocument(blocks, &options{}) {
+		t.Error("expected generated blocks to keep the in-memory path")
+	}
+}
+
+func TestRunStreamsLargeDocument(t *testing.T) {
+	isolateAuthEnv(t)
+	t.Setenv("GITHUB_TOKEN", "test_token")
+	server := newRepoInfoAPI(t)
+	setStreamThreshold(t, 1<<10)
+
+	// Enough entries for several blocks, with the stale directive in the first one.
+	var original, expected strings.Builder
+	original.WriteString("<!-- stars:stale-after 12 -->\n")
+	expected.WriteString("<!-- stars:stale-after 12 -->\n")
+	for i := 0; i < 500; i++ {
+		if i%20 == 0 {
+			fmt.Fprintf(&original, "\n## Part %d\n\n", i)
+			fmt.Fprintf(&expected, "\n## Part %d\n\n", i)
+		}
+		original.WriteString("- [Active](https://github.com/owner/active)\n- [Dormant (⭐1)](https://github.com/owner/dormant)\n")
+		expected.WriteString("- [Active (⭐1.2k)](https://github.com/owner/active)\n- [Dormant (⭐40 💤)](https://github.com/owner/dormant)\n")
+	}
+	doc := filepath.Join(t.TempDir(), "list.md")
+	writeFile(t, doc, original.String())
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"-api-url", server.URL, "-label", "⭐{stars} {stale}", "-backup", doc}
+	if code := run(args, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	if got := mustRead(t, doc); got != expected.String() {
+		t.Errorf("unexpected streamed update:\n%s", got)
+	}
+	if got := mustRead(t, doc+backupSuffix); got != original.String() {
+		t.Error("expected the backup to hold the original document")
+	}
+
+	// A second run changes nothing and leaves the file and its backup alone.
+	if err := os.Remove(doc + backupSuffix); err != nil {
+		t.Fatal(err)
+	}
+	stdout.Reset()
+	if code := run(args, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "already up to date") {
+		t.Errorf("expected the document to be up to date, got %q", stdout.String())
+	}
+	if _, err := os.Stat(doc + backupSuffix); !os.IsNotExist(err) {
+		t.Errorf("expected no backup, got %v", err)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(doc)); len(entries) != 1 {
+		t.Errorf("expected no temporary files, got %d entries", len(entries))
+	}
+}
+
+func TestRunRefreshesBlocksOfLargeDocument(t *testing.T) {
+	isolateAuthEnv(t)
+	setStreamThreshold(t, 1<<10)
+	dir := t.TempDir()
+	fixture := filepath.Join(dir, "stars.json")
+	writeFile(t, fixture, `{"o/a": 1500, "o/b": 300, "o/c": 20}`)
+
+	var original strings.Builder
+	original.WriteString("# List\n\n<!-- stars:summary -->\n<!-- stars:end -->\n\n<!-- stars:top 2 -->\n<!-- stars:end -->\n\n## Tools\n\n")
+	for i := 0; i < 50; i++ {
+		original.WriteString("- [C](https://github.com/o/c)\n- [B](https://github.com/o/b)\n- [A](https://github.com/o/a)\n")
+	}
+	doc := filepath.Join(dir, "list.md")
+	writeFile(t, doc, original.String())
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-offline", "-fixture", fixture, doc}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	got := mustRead(t, doc)
+	for _, want := range []string{
+		"<!-- stars:summary -->\n| Section | Entries | Stars | Median | Top project |\n",
+		"| Tools | 3 | ⭐1.8k | ⭐300 | o/a ⭐1.5k |\n",
+		"<!-- stars:top 2 -->\n1. [A (⭐1.5k)](https://github.com/o/a)\n2. [B (⭐300)](https://github.com/o/b)\n<!-- stars:end -->\n",
+		"- [C (⭐20)](https://github.com/o/c)\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected the large document to contain %q, got:\n%s", want, got[:min(len(got), 600)])
+		}
+	}
+}
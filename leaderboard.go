@@ -0,0 +1,99 @@
+// Package main provides the core functionality for updating GitHub star counts in Markdown and AsciiDoc files.
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/stn1slv/github-markdown-stars-updater/pkg/stars"
+)
+
+// blockTop names the generated block that holds the most starred links, e.g.
+// "<!-- stars:top 10 section="Tools" -->".
+const blockTop = "top"
+
+var topArgsRe = regexp.MustCompile(`^(\d+)(?:[ \t]+section="([^"]*)")?$`)
+
+// leaderboardEntry is a repository in a leaderboard, shown with the first link to it.
+type leaderboardEntry struct {
+	key    string
+	markup string
+	stars  int
+}
+
+// leaderboard renders the "stars:top" block with the given arguments: a numbered list of the N most
+// starred repositories linked in content, or in the section with the given heading and its
+// subsections. Each entry repeats the first link to the repository as written, so its link text
+// and annotation are kept and the update gives it the current label. links are the links of
+// content; the copies in blocks do not count.
+func leaderboard(content string, format stars.Format, args string, links []stars.Link, blocks []generatedBlock, infos map[string]repoInfo, exclude []string) (string, error) {
+	match := topArgsRe.FindStringSubmatch(args)
+	if match == nil {
+		return "", fmt.Errorf(`invalid stars:top arguments %q (expected a count and an optional section="<heading>")`, args)
+	}
+	n, err := strconv.Atoi(match[1])
+	if err != nil || n == 0 {
+		return "", fmt.Errorf("invalid stars:top count %q", match[1])
+	}
+	first, last := 0, math.MaxInt
+	if match[2] != "" {
+		if first, last, err = sectionLines(content, format, match[2]); err != nil {
+			return "", err
+		}
+	}
+
+	var entries []leaderboardEntry
+	seen := make(map[string]bool)
+	for _, link := range links {
+		if link.Line < first || link.Line > last || link.Hidden ||
+			slices.ContainsFunc(blocks, func(b generatedBlock) bool { return b.contains(link.Line) }) {
+			continue
+		}
+		entry := leaderboardEntry{key: stars.RepoKey(link.Repo), markup: link.Markup, stars: link.Stars}
+		if link.Pinned {
+			entry.key = stars.RepoKey(link.URL)
+		} else if info, ok := infos[entry.key]; ok {
+			entry.stars = info.Stars
+		} else {
+			// Excluded, or failed to fetch.
+			continue
+		}
+		if seen[entry.key] || isExcluded(exclude, entry.key) {
+			continue
+		}
+		seen[entry.key] = true
+		entries = append(entries, entry)
+	}
+	slices.SortStableFunc(entries, func(a, b leaderboardEntry) int { return cmp.Compare(b.stars, a.stars) })
+
+	var b strings.Builder
+	for i, entry := range entries[:min(n, len(entries))] {
+		if format == stars.FormatASCIIDoc {
+			fmt.Fprintf(&b, ". %s\n", entry.markup)
+		} else {
+			fmt.Fprintf(&b, "%d. %s\n", i+1, entry.markup)
+		}
+	}
+	return b.String(), nil
+}
+
+// sectionLines returns the first and last line of the section with the given heading, including
+// its subsections.
+func sectionLines(content string, format stars.Format, title string) (int, int, error) {
+	headings := parseHeadings(content, format)
+	i := slices.IndexFunc(headings, func(h heading) bool { return h.title == title })
+	if i < 0 {
+		return 0, 0, fmt.Errorf("no section titled %q", title)
+	}
+	for _, h := range headings[i+1:] {
+		if h.level <= headings[i].level {
+			return headings[i].line, h.line - 1, nil
+		}
+	}
+	return headings[i].line, math.MaxInt, nil
+}
@@ -0,0 +1,117 @@
+// Package main provides the core functionality for updating GitHub star counts in Markdown and AsciiDoc files.
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stn1slv/github-markdown-stars-updater/pkg/stars"
+)
+
+func TestLeaderboard(t *testing.T) {
+	content := "# List\n\n<!-- stars:top 9 -->\n1. [Old](https://github.com/o/old)\n<!-- stars:end -->\n\n## Tools\n\n" +
+		"- [A (⭐1)](https://github.com/o/a)\n- [B](https://github.com/o/b)\n- [Pinned](https://github.com/p/p) <!-- stars: pin 500 -->\n" +
+		"- [Hidden](https://github.com/o/c) <!-- stars: hide -->\n\n### CLI\n\n- [A again](https://github.com/o/a)\n- [Gone](https://github.com/o/gone)\n\n" +
+		"## Other\n\n- [C](https://github.com/o/c)\n- [Skip](https://github.com/skip/x)\n"
+	infos := map[string]repoInfo{"o/a": {Stars: 1500}, "o/b": {Stars: 300}, "o/c": {Stars: 9000}, "o/old": {Stars: 1}, "skip/x": {Stars: 99999}}
+	links, err := stars.FindLinks(content, stars.FormatMarkdown)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocks, err := findBlocks(content, stars.FormatMarkdown, blockTop)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		args     string
+		expected string
+		err      string
+	}{
+		{
+			args:     "9",
+			expected: "1. [C](https://github.com/o/c)\n2. [A (⭐1)](https://github.com/o/a)\n3. [Pinned](https://github.com/p/p) <!-- stars: pin 500 -->\n4. [B](https://github.com/o/b)\n",
+		},
+		{args: "2", expected: "1. [C](https://github.com/o/c)\n2. [A (⭐1)](https://github.com/o/a)\n"},
+		{
+			args:     `3 section="Tools"`,
+			expected: "1. [A (⭐1)](https://github.com/o/a)\n2. [Pinned](https://github.com/p/p) <!-- stars: pin 500 -->\n3. [B](https://github.com/o/b)\n",
+		},
+		{args: `5 section="CLI"`, expected: "1. [A again](https://github.com/o/a)\n"},
+		{args: `5 section="Missing"`, err: `no section titled "Missing"`},
+		{args: "0", err: "invalid stars:top count"},
+		{args: "", err: "invalid stars:top arguments"},
+		{args: "ten", err: "invalid stars:top arguments"},
+		{args: "3 section=Tools", err: "invalid stars:top arguments"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.args, func(t *testing.T) {
+			got, err := leaderboard(content, stars.FormatMarkdown, tt.args, links, blocks, infos, []string{"skip/*"})
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected an error containing %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRunRefreshesLeaderboard(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		content  string
+		expected string
+	}{
+		{
+			name: "Markdown",
+			file: "list.md",
+			content: "# List\r\n\r\n<!-- stars:top 2 section=\"Tools\" -->\r\n<!-- stars:end -->\r\n\r\n## Tools\r\n\r\n" +
+				"- [A](https://github.com/o/a)\r\n- [B](https://github.com/o/b)\r\n- [Mono](https://github.com/m/mono) <!-- stars: up/r -->\r\n",
+			expected: "# List\r\n\r\n<!-- stars:top 2 section=\"Tools\" -->\r\n" +
+				"1. [Mono (⭐20k)](https://github.com/m/mono) <!-- stars: up/r -->\r\n2. [A (⭐1.5k)](https://github.com/o/a)\r\n" +
+				"<!-- stars:end -->\r\n\r\n## Tools\r\n\r\n" +
+				"- [A (⭐1.5k)](https://github.com/o/a)\r\n- [B (⭐300)](https://github.com/o/b)\r\n- [Mono (⭐20k)](https://github.com/m/mono) <!-- stars: up/r -->\r\n",
+		},
+		{
+			name:    "AsciiDoc",
+			file:    "list.adoc",
+			content: "= List\n\n// stars:top 2\n. stale entry\n// stars:end\n\n== Tools\n\n* https://github.com/o/a[A]\n* link:https://github.com/o/b[B]\n* link:https://github.com/m/mono[Mono,stars=up/r]\n",
+			expected: "= List\n\n// stars:top 2\n. link:https://github.com/m/mono[Mono (⭐20k),stars=up/r]\n. https://github.com/o/a[A (⭐1.5k)]\n// stars:end\n\n== Tools\n\n" +
+				"* https://github.com/o/a[A (⭐1.5k)]\n* link:https://github.com/o/b[B (⭐300)]\n* link:https://github.com/m/mono[Mono (⭐20k),stars=up/r]\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isolateAuthEnv(t)
+			dir := t.TempDir()
+			fixture := filepath.Join(dir, "stars.json")
+			writeFile(t, fixture, `{"o/a": 1500, "o/b": 300, "up/r": 20000}`)
+			doc := filepath.Join(dir, tt.file)
+			writeFile(t, doc, tt.content)
+
+			var stdout, stderr bytes.Buffer
+			if code := run([]string{"-offline", "-fixture", fixture, "-duplicates", "error", doc}, &stdout, &stderr); code != 0 {
+				t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+			}
+			if got := mustRead(t, doc); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+
+			// The leaderboard is stable, and its links are not reported as duplicates.
+			stdout.Reset()
+			if code := run([]string{"-offline", "-fixture", fixture, "-duplicates", "error", doc}, &stdout, &stderr); code != 0 || !strings.Contains(stdout.String(), "already up to date") {
+				t.Errorf("expected the document to be up to date, got %d: %s%s", code, stdout.String(), stderr.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+// Package main provides the core functionality for updating GitHub star counts in Markdown and AsciiDoc files.
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// findLinks returns every repository link in files, in file and document order, except the
+// copies in generated blocks. Nothing is fetched.
+func findLinks(files []string, opts *options) ([]linkOccurrence, error) {
+	var links []linkOccurrence
+	for _, file := range files {
+		content, _, err := readDocument(file)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", file, err)
+		}
+		format, err := formatOf(file, opts)
+		if err != nil {
+			return nil, err
+		}
+		locations, err := linkLocations(content, format)
+		if err != nil {
+			return nil, fmt.Errorf("finding repositories in %s: %w", file, err)
+		}
+		for _, loc := range locations {
+			links = append(links, linkOccurrence{File: file, Location: loc})
+		}
+	}
+	return links, nil
+}
+
+// runList prints every repository link in files with its position, e.g.
+// "README.md:12:5: owner/repo", marking links the exclude patterns leave untouched.
+func runList(files []string, opts *options, stdout, stderr io.Writer) int {
+	links, err := findLinks(files, opts)
+	if err != nil {
+		_, _ = fmt.Fprintln(stderr, "Error:", err)
+		return 1
+	}
+	repos := make(map[string]bool)
+	for _, link := range links {
+		repos[link.Key] = true
+		suffix := ""
+		if isExcluded(opts.exclude, link.Key) {
+			suffix = " (excluded)"
+		}
+		_, _ = fmt.Fprintf(stdout, "%s:%d:%d: %s%s\n", link.File, link.Line, link.Column, link.Key, suffix)
+	}
+	_, _ = fmt.Fprintf(stdout, "%d link(s) to %d repositories.\n", len(links), len(repos))
+	return 0
+}
@@ -6,141 +6,706 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"net/http"
 	"net/url"
 	"os"
-	"path/filepath"
+	"os/signal"
 	"strings"
+	"time"
 
 	"github.com/google/go-github/v68/github"
+	"github.com/stn1slv/github-markdown-stars-updater/pkg/stars"
 	"golang.org/x/oauth2"
 )
 
-const githubURLPrefix = "https://github.com/"
-
 var version = "dev"
 
 func main() {
-	outPath := flag.String("out", "", "output file path (defaults to input file)")
-	dryRun := flag.Bool("dry-run", false, "print updated markdown to stdout")
-	showVersion := flag.Bool("version", false, "show version info and exit")
-	flag.Parse()
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
 
-	if *showVersion {
-		fmt.Printf("markdown-github-stars-updater version %s\n", version)
-		return
+// options holds the effective settings after merging the config file and the command-line flags.
+type options struct {
+	inputs         []string
+	outPath        string
+	dryRun         bool
+	backup         bool
+	format         string
+	formats        map[string]string
+	formatsDir     string // directory the globs of formats are relative to
+	label          string
+	style          string
+	badgeDir       string
+	exclude        []string
+	apiURL         string
+	cachePath      string
+	cacheTTL       time.Duration
+	minStars       int
+	historyPath    string
+	trend          bool
+	trendDays      int
+	trendMinDelta  int
+	sparklineDir   string
+	sparklineDays  int
+	sparklineTop   int
+	sparklineLinks bool
+	staleAfter     int
+	staleMarker    string
+	metrics        bool
+	packagesPath   string
+	auth           authOptions
+	checkPolicy    bool
+	policy         policyRules
+	sarifPath      string
+	baseRev        string
+	baseFile       string
+	duplicates     string
+	fixturePath    string
+	offline        bool
+	command        string
+	snapshotPath   string
+	reportTop      int
+	reportOutput   string
+	watch          bool
+	watchInterval  time.Duration
+	watchDebounce  time.Duration
+	action         actionOptions
+}
+
+// defaultOptions returns the options of a run without flags or config file, including those of
+// flags the command does not accept.
+func defaultOptions() options {
+	return options{
+		cacheTTL:      defaultCacheTTL,
+		trendDays:     defaultTrendDays,
+		sparklineDays: defaultSparklineDays,
+		staleMarker:   defaultStaleMarker,
+		style:         styleLabel,
+		badgeDir:      defaultBadgeDir,
+		reportTop:     defaultReportTop,
+		reportOutput:  reportText,
+
+		watchInterval: defaultWatchInterval,
+		watchDebounce: defaultWatchDebounce,
 	}
+}
+
+// programName is the name shown in usage messages.
+const programName = "markdown-github-stars-updater"
 
-	if flag.NArg() < 1 {
-		fmt.Fprintln(os.Stderr, "Usage: markdown-github-stars-updater [flags] <path_to_file>")
-		flag.PrintDefaults()
-		os.Exit(1)
+// run executes the command line and returns the process exit code.
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) > 0 && args[0] == "help" {
+		return runHelp(args[1:], stdout, stderr)
+	}
+	cmd, args := lookupCommand(args)
+	opts := defaultOptions()
+	var values flagValues
+	opts.command = cmd.name
+	fs := newFlagSet(cmd, &opts, &values, stderr)
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2 //nolint:mnd
 	}
 
-	filePath := flag.Arg(0)
+	if values.showVersion {
+		_, _ = fmt.Fprintf(stdout, "markdown-github-stars-updater version %s\n", version)
+		return 0
+	}
 
-	contentBytes, err := os.ReadFile(filepath.Clean(filePath))
+	cfg, err := resolveConfig(values.configPath)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Error reading the file:", err)
-		os.Exit(1)
+		_, _ = fmt.Fprintln(stderr, "Error loading config:", err)
+		return 1
+	}
+	setFlags := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { setFlags[f.Name] = true })
+	if values.excludeList != "" {
+		opts.exclude = strings.Split(values.excludeList, ",")
+	}
+	if values.licenseList != "" {
+		opts.policy.licenses = strings.Split(values.licenseList, ",")
+	}
+	opts.inputs = fs.Args()
+	opts.applyConfig(cfg, setFlags)
+	if opts.apiURL != "" {
+		opts.apiURL = apiBaseURL(opts.apiURL)
+	}
+	switch opts.command {
+	case commandApply:
+		opts.offline = true
+	case commandCheck:
+		opts.checkPolicy = true
+	}
+	if opts.baseRev != "" || opts.baseFile != "" {
+		// Pull request mode checks the links that are new compared to the base.
+		opts.checkPolicy = true
+	}
+
+	if err := opts.validate(); err != nil {
+		_, _ = fmt.Fprintln(stderr, "Error:", err)
+		return 1
 	}
-	content := string(contentBytes)
 
-	token, err := getAccessToken()
+	files, err := expandInputs(opts.inputs)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Error:", err)
-		os.Exit(1)
+		_, _ = fmt.Fprintln(stderr, "Error:", err)
+		return 1
 	}
+	if len(files) == 0 {
+		fs.Usage()
+		return 1
+	}
+	if opts.outPath != "" && len(files) > 1 {
+		_, _ = fmt.Fprintln(stderr, "Error: -out can only be used with a single input file")
+		return 1
+	}
+	if opts.baseFile != "" && len(files) > 1 {
+		_, _ = fmt.Fprintln(stderr, "Error: -base-file can only be used with a single input file")
+		return 1
+	}
+	if opts.command == commandList {
+		return runList(files, &opts, stdout, stderr)
+	}
+
+	var mapping map[string][]PackageRef
+	if opts.packagesPath != "" {
+		opts.metrics = true
+		mapping, err = loadPackageMapping(opts.packagesPath)
+		if err != nil {
+			_, _ = fmt.Fprintln(stderr, "Error loading package mapping:", err)
+			return 1
+		}
+	}
+
+	ctx := context.Background()
+	var client *github.Client
+	var pool *tokenPool
+	if !opts.offline {
+		client, pool, err = newClientFromOptions(ctx, opts, stderr)
+		if err != nil {
+			_, _ = fmt.Fprintln(stderr, "Error:", err)
+			return 1
+		}
+	}
+
+	var cache *fetchCache
+	if opts.cachePath != "" {
+		cache, err = loadCache(opts.cachePath, opts.cacheTTL)
+		if err != nil {
+			_, _ = fmt.Fprintln(stderr, "Error loading cache:", err)
+			return 1
+		}
+	}
+
+	fetcher := newStarFetcher(client, newMetricsClient(nil), cache, stderr)
+	if opts.fixturePath != "" {
+		fixture, fixtureErr := stars.LoadFixture(opts.fixturePath)
+		if fixtureErr != nil {
+			_, _ = fmt.Fprintln(stderr, "Error loading fixture:", fixtureErr)
+			return 1
+		}
+		fetcher.source, fetcher.fixture = fixture, fixture
+		if !opts.offline {
+			fetcher.source = stars.Chain(fixture, &stars.GitHubFetcher{Client: client})
+		}
+	}
+	if opts.command == commandApply {
+		snap, snapErr := loadSnapshot(opts.snapshotPath)
+		if snapErr != nil {
+			_, _ = fmt.Fprintln(stderr, "Error loading snapshot:", snapErr)
+			return 1
+		}
+		fetcher.useSnapshot(snap)
+	}
+	if opts.historyPath != "" {
+		fetcher.history, err = loadHistory(opts.historyPath)
+		if err != nil {
+			_, _ = fmt.Fprintln(stderr, "Error loading history:", err)
+			return 1
+		}
+	}
+	exitCode := 0
+	if opts.duplicates != "" {
+		exitCode = checkDuplicates(files, &opts, stderr)
+	}
+	switch {
+	case opts.command == commandFetch:
+		if code := runFetch(ctx, files, &opts, fetcher, stdout, stderr); code != 0 {
+			exitCode = code
+		}
+	case opts.command == commandAction:
+		if code := runAction(ctx, files, &opts, mapping, fetcher, client, stdout, stderr); code != 0 {
+			exitCode = code
+		}
+	case opts.command == commandReport:
+		if code := runReport(ctx, files, &opts, fetcher, stdout, stderr); code != 0 {
+			exitCode = code
+		}
+	case opts.command == commandSections:
+		if code := runSections(ctx, files, &opts, fetcher, stdout, stderr); code != 0 {
+			exitCode = code
+		}
+	case opts.checkPolicy:
+		if code := runPolicy(ctx, files, &opts, fetcher, stdout, stderr); code != 0 {
+			exitCode = code
+		}
+	default:
+		for _, file := range files {
+			if err := processFile(ctx, file, &opts, mapping, fetcher, stdout, stderr); err != nil {
+				_, _ = fmt.Fprintln(stderr, "Error:", err)
+				exitCode = 1
+			}
+		}
+		if opts.watch {
+			watchCtx, stop := signal.NotifyContext(ctx, os.Interrupt)
+			update := func(ctx context.Context, file string) error {
+				fetcher.forgetFailures()
+				return processFile(ctx, file, &opts, mapping, fetcher, stdout, stderr)
+			}
+			_, _ = fmt.Fprintf(stdout, "Watching %d file(s) for changes. Press Ctrl+C to stop.\n", len(files))
+			newWatcher(opts.inputs, opts.watchDebounce, update, stderr).run(watchCtx, opts.watchInterval)
+			stop()
+		}
+	}
+
+	if pool != nil {
+		pool.writeSummary(stderr)
+	}
+	if err := cache.save(); err != nil {
+		_, _ = fmt.Fprintln(stderr, "Warning: Could not save cache:", err)
+	}
+	if err := fetcher.history.save(); err != nil {
+		_, _ = fmt.Fprintln(stderr, "Error saving history:", err)
+		exitCode = 1
+	}
+	return exitCode
+}
+
+// resolveConfig loads the config file at configPath, or discovers one from the working directory
+// upward when configPath is empty. It returns nil when no config file exists.
+func resolveConfig(configPath string) (*fileConfig, error) {
+	if configPath == "" {
+		found, err := findConfig(".")
+		if err != nil || found == "" {
+			return nil, err
+		}
+		configPath = found
+	}
+	return loadConfig(configPath)
+}
+
+// applyConfig fills every option that was not set on the command line from cfg.
+func (o *options) applyConfig(cfg *fileConfig, setFlags map[string]bool) {
+	if cfg == nil {
+		return
+	}
+	if len(o.inputs) == 0 {
+		for _, pattern := range cfg.Inputs {
+			o.inputs = append(o.inputs, cfg.resolve(pattern))
+		}
+	}
+	if !setFlags["format"] {
+		o.formats, o.formatsDir = cfg.Formats, cfg.dir
+	}
+	if !setFlags["label"] {
+		o.label = cfg.Label
+	}
+	if !setFlags["style"] && cfg.Style != "" {
+		o.style = cfg.Style
+	}
+	if !setFlags["badge-dir"] && cfg.BadgeDir != "" {
+		o.badgeDir = cfg.resolve(cfg.BadgeDir)
+	}
+	if !setFlags["exclude"] {
+		o.exclude = cfg.Exclude
+	}
+	if !setFlags["api-url"] {
+		o.apiURL = cfg.Host.APIURL
+	}
+	if !setFlags["cache"] {
+		o.cachePath = cfg.resolve(cfg.Cache.Path)
+	}
+	if !setFlags["cache-ttl"] && cfg.Cache.TTL != 0 {
+		o.cacheTTL = time.Duration(cfg.Cache.TTL)
+	}
+	if !setFlags["min-stars"] {
+		o.minStars = cfg.Thresholds.MinStars
+	}
+	if !setFlags["history"] {
+		o.historyPath = cfg.resolve(cfg.History.Path)
+	}
+	if !setFlags["trend"] {
+		o.trend = cfg.History.Trend
+	}
+	if !setFlags["trend-days"] && cfg.Thresholds.TrendDays != 0 {
+		o.trendDays = cfg.Thresholds.TrendDays
+	}
+	if !setFlags["trend-min-delta"] {
+		o.trendMinDelta = cfg.Thresholds.TrendMinDelta
+	}
+	if !setFlags["sparklines"] {
+		o.sparklineDir = cfg.resolve(cfg.History.Sparklines.Dir)
+	}
+	if !setFlags["sparkline-days"] && cfg.History.Sparklines.Days != 0 {
+		o.sparklineDays = cfg.History.Sparklines.Days
+	}
+	if !setFlags["sparkline-top"] {
+		o.sparklineTop = cfg.History.Sparklines.Top
+	}
+	if !setFlags["sparkline-links"] {
+		o.sparklineLinks = cfg.History.Sparklines.Links
+	}
+	if !setFlags["duplicates"] {
+		o.duplicates = cfg.Duplicates
+	}
+	if !setFlags["policy-min-stars"] {
+		o.policy.minStars = cfg.Policy.MinStars
+	}
+	if !setFlags["policy-forbid-archived"] {
+		o.policy.forbidArchived = cfg.Policy.ForbidArchived
+	}
+	if !setFlags["policy-max-inactive-days"] {
+		o.policy.maxInactiveDays = cfg.Policy.MaxInactiveDays
+	}
+	if !setFlags["policy-licenses"] {
+		o.policy.licenses = cfg.Policy.Licenses
+	}
+	if !setFlags["stale-after"] {
+		o.staleAfter = cfg.Thresholds.StaleAfterMonths
+	}
+	if !setFlags["stale-marker"] && cfg.Thresholds.StaleMarker != "" {
+		o.staleMarker = cfg.Thresholds.StaleMarker
+	}
+}
 
-	client := newGitHubClient(token)
+// validate checks option values that the flag package cannot check.
+func (o *options) validate() error {
+	if o.format != "" && o.format != formatMarkdown && o.format != formatASCIIDoc {
+		return fmt.Errorf("unknown -format %q (expected %q or %q)", o.format, formatMarkdown, formatASCIIDoc)
+	}
+	if o.label != "" {
+		if err := validateLabelTemplate(o.label); err != nil {
+			return fmt.Errorf("invalid label template: %w", err)
+		}
+	}
+	if o.style != styleLabel && o.style != styleShields && o.style != styleSVG {
+		return fmt.Errorf("unknown -style %q (expected %q, %q or %q)", o.style, styleLabel, styleShields, styleSVG)
+	}
+	if o.minStars < 0 {
+		return errors.New("-min-stars must not be negative")
+	}
+	if o.trend && o.historyPath == "" {
+		return errors.New("-trend requires a history file (-history or history.path)")
+	}
+	if o.trendDays <= 0 {
+		return errors.New("-trend-days must be positive")
+	}
+	if o.sparklineDir != "" && o.historyPath == "" {
+		return errors.New("-sparklines requires a history file (-history or history.path)")
+	}
+	if o.sparklineLinks && o.sparklineDir == "" {
+		return errors.New("-sparkline-links requires a sparkline directory (-sparklines or history.sparklines.dir)")
+	}
+	if o.sparklineDays <= 0 || o.sparklineTop < 0 {
+		return errors.New("-sparkline-days must be positive and -sparkline-top must not be negative")
+	}
+	if o.staleAfter < 0 {
+		return errors.New("-stale-after must not be negative")
+	}
+	if strings.ContainsAny(o.staleMarker, ")]") {
+		return errors.New("-stale-marker must not contain ')' or ']'")
+	}
+	if o.policy.minStars < 0 || o.policy.maxInactiveDays < 0 {
+		return errors.New("-policy-min-stars and -policy-max-inactive-days must not be negative")
+	}
+	if o.duplicates != "" && o.duplicates != duplicatesWarn && o.duplicates != duplicatesError {
+		return fmt.Errorf("unknown -duplicates %q (expected %q or %q)", o.duplicates, duplicatesWarn, duplicatesError)
+	}
+	if o.command == commandFetch && o.snapshotPath == "" && o.cachePath == "" {
+		return errors.New("fetch requires -snapshot or a cache (-cache or cache.path)")
+	}
+	if o.command == commandApply && o.snapshotPath == "" {
+		return errors.New("apply requires -snapshot")
+	}
+	if (o.command == commandReport || o.command == commandSections) && o.reportOutput != reportText && o.reportOutput != reportMarkdown && o.reportOutput != reportJSON {
+		return fmt.Errorf("unknown -output %q (expected %q, %q or %q)", o.reportOutput, reportText, reportMarkdown, reportJSON)
+	}
+	if o.reportTop < 0 {
+		return errors.New("-top must not be negative")
+	}
+	if o.watch && (o.outPath != "" || o.dryRun || o.checkPolicy) {
+		return errors.New("-watch updates the documents in place and cannot be combined with -out, -dry-run or policy checks")
+	}
+	if o.command == commandAction {
+		switch o.action.mode {
+		case actionCommit, actionPullRequest, actionNone:
+		default:
+			return fmt.Errorf("unknown -mode %q (expected %q, %q or %q)", o.action.mode, actionCommit, actionPullRequest, actionNone)
+		}
+		if o.offline && o.action.mode != actionNone {
+			return errors.New("-offline requires -mode none, as publishing needs the GitHub API")
+		}
+		if (o.style == styleSVG || o.sparklineDir != "") && o.action.mode != actionNone {
+			return errors.New("-style svg and -sparklines require -mode none, as only the documents are published")
+		}
+		if (o.historyPath != "" || o.cachePath != "") && o.action.mode != actionNone {
+			return errors.New("-history, -trend and -cache require -mode none, as only the documents are published")
+		}
+	}
+	if o.watchInterval <= 0 || o.watchDebounce < 0 {
+		return errors.New("-watch-interval must be positive and -watch-debounce must not be negative")
+	}
+	if o.command == commandApply && (o.metrics || o.packagesPath != "") {
+		return errors.New("apply cannot show package metrics, which snapshots do not include")
+	}
+	if o.offline && o.fixturePath == "" && o.command != commandApply {
+		return errors.New("-offline requires -fixture")
+	}
+	if o.baseRev != "" && o.baseFile != "" {
+		return errors.New("-base and -base-file are mutually exclusive")
+	}
+	if o.checkPolicy && o.baseRev == "" && o.baseFile == "" && o.command == "" && o.policy.detail() == detailStars && o.policy.minStars == 0 {
+		// Pull request mode lists the new links even without rules.
+		return errors.New("-policy needs at least one rule (-policy-min-stars, -policy-forbid-archived, -policy-max-inactive-days, -policy-licenses or the policy section of the config file)")
+	}
+	if o.sarifPath != "" && !o.checkPolicy {
+		return errors.New("-sarif requires -policy")
+	}
+	return nil
+}
 
-	// Select Updater based on extension
-	ext := strings.ToLower(filepath.Ext(filePath))
-	var updater LinkUpdater
+// newClientFromOptions resolves the credentials and returns the GitHub client. pool is non-nil
+// when requests rotate over several tokens.
+func newClientFromOptions(ctx context.Context, opts options, stderr io.Writer) (*github.Client, *tokenPool, error) {
+	opts.auth.apiURL = opts.apiURL
+	creds, err := resolveCredentials(ctx, opts.auth)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	switch ext {
-	case ".md", ".markdown":
-		updater = &MarkdownUpdater{}
-	case ".adoc", ".asciidoc":
-		updater = &ASCIIDocUpdater{}
+	var client *github.Client
+	var pool *tokenPool
+	switch len(creds) {
+	case 0:
+		_, _ = fmt.Fprintln(stderr, "Warning: no GitHub credentials found; using anonymous requests limited to 60 per hour.")
+		client = newGitHubClient(nil)
+	case 1:
+		client = newGitHubClient(creds[0].source)
 	default:
-		// Default to Markdown for backward compatibility if no extension matches, or error out?
-		// Spec says "Tool automatically detects file type or applies appropriate parsing".
-		// Let's assume Markdown if unknown for now, or maybe print a warning?
-		// Given the project name is "markdown-github-stars-updater", defaulting to markdown seems safe,
-		// but explicit support for asciidoc is added.
-		// Let's print a warning and default to markdown for now, or just fail.
-		// Failing is safer to avoid corrupting other files.
-		fmt.Fprintf(os.Stderr, "Unsupported file extension: %s. Supported: .md, .markdown, .adoc, .asciidoc\n", ext)
-		os.Exit(1)
+		pool = newTokenPool(creds, nil)
+		client = github.NewClient(&http.Client{Transport: pool})
+	}
+
+	if opts.apiURL != "" {
+		baseURL, err := url.Parse(opts.apiURL)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid API URL: %w", err)
+		}
+		client.BaseURL = baseURL
+	}
+	return client, pool, nil
+}
+
+// formatOf selects the format of filePath from the forced format, the configured format
+// overrides or the file extension, in that order.
+func formatOf(filePath string, opts *options) (stars.Format, error) {
+	if opts.format != "" {
+		return stars.Format(opts.format), nil
+	}
+	if format := formatFor(opts.formats, opts.formatsDir, filePath); format != "" {
+		return stars.Format(format), nil
+	}
+	return stars.FormatFromPath(filePath)
+}
+
+// newUpdater returns the LinkUpdater for the format of filePath.
+func newUpdater(filePath string, opts *options) (stars.LinkUpdater, error) {
+	format, err := formatOf(filePath, opts)
+	if err != nil {
+		return nil, err
+	}
+	return stars.NewUpdater(format, nil)
+}
+
+// processFile updates the star counts of a single document. Large documents are streamed by
+// processLargeFile.
+func processFile(ctx context.Context, filePath string, opts *options, mapping map[string][]PackageRef, fetcher *starFetcher, stdout, stderr io.Writer) error {
+	if isLargeDocument(filePath, opts) {
+		return processLargeFile(ctx, filePath, opts, fetcher, stdout)
+	}
+
+	content, layout, err := readDocument(filePath)
+	if err != nil {
+		return fmt.Errorf("reading the file: %w", err)
+	}
+
+	format, err := formatOf(filePath, opts)
+	if err != nil {
+		return err
+	}
+	updater, err := stars.NewUpdater(format, nil)
+	if err != nil {
+		return err
 	}
 
 	// 1. Find Repos
 	repos, err := updater.FindRepos(content)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Error finding repositories:", err)
-		os.Exit(1)
+		return fmt.Errorf("finding repositories in %s: %w", filePath, err)
 	}
 
-	// 2. Fetch Stars
-	stars := make(map[string]int)
-	ctx := context.Background()
-	for _, repoURL := range repos {
-		if _, exists := stars[repoURL]; exists {
-			continue
-		}
-		count, fetchErr := getStarsCount(ctx, client, repoURL)
-		if fetchErr != nil {
-			fmt.Fprintf(os.Stderr, "Warning: Could not fetch stars for %s: %v\n", repoURL, fetchErr)
-			continue
-		}
-		stars[repoURL] = count
+	// 2. Pick the stale period; a stale directive in the document overrides it
+	staleAfter := opts.staleAfter
+	if months, ok := staleAfterDirective(content); ok {
+		staleAfter = months
 	}
+	template := labelTemplate(opts, staleAfter)
+
+	// 3. Fetch Stars and the metadata the template needs, once per canonical owner/repo
+	infos := fetcher.fetchInfo(ctx, repos, opts.exclude, detailFor(template, staleAfter))
 
-	// 3. Update Content
-	updatedContent, err := updater.UpdateContent(content, stars)
+	// 4. Build the label from the optional trend, freshness and package registry metrics
+	fields := labelFields(opts, fetcher, infos, staleAfter)
+	if opts.metrics {
+		field, metricsErr := newMetricsField(ctx, content, updater, mapping, fetcher.fetchMetric, stderr)
+		if metricsErr != nil {
+			return fmt.Errorf("resolving package metrics in %s: %w", filePath, metricsErr)
+		}
+		fields["metrics"] = field
+	}
+	label, err := newLabelFunc(template, fields, opts.minStars)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Error updating content:", err)
-		os.Exit(1)
+		return err
 	}
 
-	if *dryRun {
-		fmt.Println(updatedContent)
-		return
+	// 5. Refresh the generated summary tables and leaderboards
+	updatedContent, err := refreshBlocks(content, format, layout, repos, infos, opts.exclude)
+	if err != nil {
+		return fmt.Errorf("refreshing the generated blocks of %s: %w", filePath, err)
 	}
 
+	// 6. Update Content from the fetched counts; failed and excluded repositories keep their links
 	output := filePath
-	if *outPath != "" {
-		output = *outPath
+	if opts.outPath != "" {
+		output = opts.outPath
+	}
+	images := newDocumentImages(output)
+	updatedContent, err = stars.Update(ctx, updatedContent, format, fetchedStars(infos),
+		stars.WithLabel(label), stars.WithBadge(badgeFunc(opts, images)),
+		stars.WithChart(sparklineCharts(opts, fetcher.history, infos, fetcher.now(), images)),
+		stars.WithErrorHandler(func(string, error) {}))
+	if err != nil {
+		return fmt.Errorf("updating content of %s: %w", filePath, err)
+	}
+
+	if opts.dryRun {
+		_, _ = stdout.Write(layout.render(updatedContent))
+		return nil
 	}
 
-	// G306: Expect WriteFile permissions to be 0600 or less (gosec)
-	// We use 0644 because this is a documentation tool and the files are usually public/shared.
-	err = os.WriteFile(output, []byte(updatedContent), 0o644) //nolint:gosec
+	if err := images.write(); err != nil {
+		return err
+	}
+	written, err := writeFileAtomic(output, layout.render(updatedContent), opts.backup)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Error writing updated file:", err)
-		os.Exit(1)
+		return fmt.Errorf("writing updated file: %w", err)
+	}
+	if !written {
+		_, _ = fmt.Fprintf(stdout, "File %s is already up to date.\n", output)
+		return nil
+	}
+
+	_, _ = fmt.Fprintf(stdout, "File %s updated successfully.\n", output)
+	return nil
+}
+
+// labelTemplate returns the label template of a document whose stale period is staleAfter: the
+// -label flag, or the default template for the enabled label fields.
+func labelTemplate(opts *options, staleAfter int) string {
+	if opts.label != "" {
+		return opts.label
+	}
+	var enabled []string
+	if opts.trend {
+		enabled = append(enabled, "trend")
+	}
+	if staleAfter > 0 {
+		enabled = append(enabled, "stale")
+	}
+	if opts.metrics {
+		enabled = append(enabled, "metrics")
+	}
+	return defaultTemplateFor(enabled...)
+}
+
+// labelFields returns the label fields built from the fetched repository data: the freshness
+// fields and, with -trend, the trend.
+func labelFields(opts *options, fetcher *starFetcher, infos map[string]repoInfo, staleAfter int) map[string]labelField {
+	fields := newFreshnessFields(infos, fetcher.now(), staleAfter, opts.staleMarker)
+	if opts.trend {
+		fields["trend"] = newTrendField(fetcher.history, fetcher.now(), opts.trendDays, opts.trendMinDelta)
 	}
+	return fields
+}
 
-	fmt.Println("File updated successfully.")
+// fetchedStars serves the star counts in infos; other repositories were excluded or failed to
+// fetch and are reported as errNotFetched.
+func fetchedStars(infos map[string]repoInfo) stars.StarFetcher {
+	return stars.StarFetcherFunc(func(_ context.Context, repo string) (int, error) {
+		info, ok := infos[repo]
+		if !ok {
+			return 0, errNotFetched
+		}
+		return info.Stars, nil
+	})
 }
 
-// getStarsCount takes a GitHub repository URL and returns the current number of stars.
-func getStarsCount(ctx context.Context, client *github.Client, repoURL string) (int, error) {
-	if !strings.HasPrefix(repoURL, githubURLPrefix) {
-		return 0, fmt.Errorf("invalid GitHub URL: %s", repoURL)
+// getRepoInfo takes a GitHub repository URL and returns the star count and activity metadata of
+// the repository. The latest release is only looked up when withRelease is set, as it costs an
+// extra request.
+func getRepoInfo(ctx context.Context, client *github.Client, repoURL string, withRelease bool) (repoInfo, error) {
+	key, ok := stars.NormalizeRepoURL(repoURL)
+	if !ok {
+		return repoInfo{}, fmt.Errorf("invalid GitHub URL: %s", repoURL)
 	}
 
-	owner, repo, err := parseRepoName(repoURL[len(githubURLPrefix):])
+	owner, repo, err := parseRepoName(key)
 	if err != nil {
-		return 0, err
+		return repoInfo{}, err
 	}
 
 	repository, _, err := client.Repositories.Get(ctx, owner, repo)
 	if err != nil {
-		return 0, err
+		return repoInfo{}, err
+	}
+
+	info := repoInfo{
+		Stars:      repository.GetStargazersCount(),
+		PushedAt:   repository.GetPushedAt().Time,
+		OpenIssues: repository.GetOpenIssuesCount(),
+		Archived:   repository.GetArchived(),
+		License:    repository.GetLicense().GetSPDXID(),
+	}
+	if !withRelease {
+		return info, nil
 	}
 
-	return repository.GetStargazersCount(), nil
+	info.ReleaseChecked = true
+	release, resp, err := client.Repositories.GetLatestRelease(ctx, owner, repo)
+	switch {
+	case err == nil:
+		info.ReleaseTag = release.GetTagName()
+		info.ReleaseDate = release.GetPublishedAt().Time
+	case resp != nil && resp.StatusCode == http.StatusNotFound:
+		// The repository has no releases.
+	default:
+		return repoInfo{}, fmt.Errorf("fetching latest release: %w", err)
+	}
+	return info, nil
 }
 
 // parseRepoName takes a path like "owner/repo" (possibly with trailing segments, query strings, or fragments)
@@ -161,18 +726,10 @@ func parseRepoName(repoPath string) (string, string, error) {
 	return parts[0], parts[1], nil
 }
 
-// getAccessToken retrieves the GitHub access token from the environment variable
-func getAccessToken() (string, error) {
-	token := os.Getenv("GITHUB_TOKEN")
-	if token == "" {
-		return "", errors.New("missing GITHUB_TOKEN; set a GitHub personal access token")
+// newGitHubClient returns a GitHub API client authenticated with ts, or an anonymous client when ts is nil.
+func newGitHubClient(ts oauth2.TokenSource) *github.Client {
+	if ts == nil {
+		return github.NewClient(nil)
 	}
-	return token, nil
-}
-
-func newGitHubClient(token string) *github.Client {
-	ctx := context.Background()
-	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
-	tc := oauth2.NewClient(ctx, ts)
-	return github.NewClient(tc)
+	return github.NewClient(oauth2.NewClient(context.Background(), ts))
 }
@@ -2,60 +2,15 @@
 package main
 
 import (
-	"context"
-	"fmt"
-	"net/http"
-	"net/http/httptest"
-	"net/url"
+	"bytes"
+	"path/filepath"
+	"strings"
 	"testing"
-
-	"github.com/google/go-github/v68/github"
 )
 
-func TestFormatStarCount(t *testing.T) {
-	tests := []struct {
-		stars    int
-		expected string
-	}{
-		{999, "999"},
-		{1000, "1k"},
-		{2501, "2.5k"},
-		{4708, "4.7k"},
-		{5038, "5k"},
-		{6100, "6.1k"},
-		{12000, "12k"},
-		{78456, "78k"},
-	}
-
-	for _, test := range tests {
-		result := formatStarCount(test.stars)
-		if result != test.expected {
-			t.Errorf("For %d stars, expected '%s' but got '%s'", test.stars, test.expected, result)
-		}
-	}
-}
-
-func TestRemoveStarsInfo(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected string
-	}{
-		{"Project1 (⭐1k)", "Project1"},
-		{"Project2 (⭐1.5k)", "Project2"},
-		{"Project3", "Project3"},
-		{"Project4 (⭐1k) (extra notes)", "Project4 (extra notes)"},
-	}
-
-	for _, test := range tests {
-		output := removeStarsInfo(test.input)
-		if output != test.expected {
-			t.Errorf("Input: %s\nExpected: %s\nGot: %s", test.input, test.expected, output)
-		}
-	}
-}
-
 func TestGetAccessToken(t *testing.T) {
 	t.Setenv("GITHUB_TOKEN", "test_token")
+	t.Setenv("GH_TOKEN", "other_token")
 
 	token, err := getAccessToken()
 	if err != nil {
@@ -68,6 +23,7 @@ func TestGetAccessToken(t *testing.T) {
 
 func TestGetAccessTokenMissing(t *testing.T) {
 	t.Setenv("GITHUB_TOKEN", "")
+	t.Setenv("GH_TOKEN", "")
 
 	_, err := getAccessToken()
 	if err == nil {
@@ -154,172 +110,66 @@ func TestParseRepoName(t *testing.T) {
 	}
 }
 
-func TestMarkdownFindRepos(t *testing.T) {
-	tests := []struct {
-		name     string
-		content  string
-		expected []string
-	}{
-		{
-			name:     "Single link",
-			content:  "- [Project](https://github.com/owner/repo)",
-			expected: []string{"https://github.com/owner/repo"},
-		},
-		{
-			name:     "Multiple links",
-			content:  "[A](https://github.com/a/b) and [B](https://github.com/c/d)",
-			expected: []string{"https://github.com/a/b", "https://github.com/c/d"},
-		},
-		{
-			name:     "Link with existing stars",
-			content:  "[Repo (⭐100)](https://github.com/owner/repo)",
-			expected: []string{"https://github.com/owner/repo"},
-		},
-		{
-			name:     "Non-GitHub link ignored",
-			content:  "[Docs](https://docs.example.com/guide)",
-			expected: []string{},
-		},
-		{
-			name:     "No links",
-			content:  "Just some text without links.",
-			expected: []string{},
-		},
-	}
+func TestRunOfflineFixture(t *testing.T) {
+	isolateAuthEnv(t)
+	dir := t.TempDir()
+	fixture := filepath.Join(dir, "stars.yaml")
+	writeFile(t, fixture, "owner/a: 1500\n")
+	doc := filepath.Join(dir, "list.md")
+	writeFile(t, doc, "- [A](https://github.com/owner/a)\n- [B (⭐3)](https://github.com/owner/b)\n")
 
-	updater := &MarkdownUpdater{}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got, err := updater.FindRepos(tt.content)
-			if err != nil {
-				t.Fatalf("unexpected error: %v", err)
-			}
-			if len(got) != len(tt.expected) {
-				t.Fatalf("expected %v, got %v", tt.expected, got)
-			}
-			for i := range got {
-				if got[i] != tt.expected[i] {
-					t.Errorf("index %d: expected %q, got %q", i, tt.expected[i], got[i])
-				}
-			}
-		})
-	}
-}
-
-// Helper to simulate the update flow for tests.
-func runUpdateFlow(t *testing.T, content string, client *github.Client, updater LinkUpdater) string {
-	t.Helper()
-
-	repos, err := updater.FindRepos(content)
-	if err != nil {
-		t.Fatalf("FindRepos failed: %v", err)
-	}
-
-	stars := make(map[string]int)
-	ctx := context.Background()
-	for _, repoURL := range repos {
-		count, fetchErr := getStarsCount(ctx, client, repoURL)
-		if fetchErr != nil {
-			t.Fatalf("getStarsCount failed for %s: %v", repoURL, fetchErr)
-		}
-		stars[repoURL] = count
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-offline", "-fixture", fixture, doc}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
 	}
-
-	updated, err := updater.UpdateContent(content, stars)
-	if err != nil {
-		t.Fatalf("UpdateContent failed: %v", err)
+	expected := "- [A (⭐1.5k)](https://github.com/owner/a)\n- [B (⭐3)](https://github.com/owner/b)\n"
+	if got := mustRead(t, doc); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
 	}
-	return updated
-}
-
-func TestUpdateStarCounts(t *testing.T) {
-	mux := http.NewServeMux()
-	mux.HandleFunc("/repos/testowner/testrepo", func(w http.ResponseWriter, _ *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		_, _ = fmt.Fprint(w, `{"stargazers_count": 42}`)
-	})
-
-	server := httptest.NewServer(mux)
-	defer server.Close()
-
-	client := github.NewClient(server.Client())
-	baseURL, _ := url.Parse(server.URL + "/")
-	client.BaseURL = baseURL
-
-	md := "- [TestRepo](https://github.com/testowner/testrepo)"
-	updated := runUpdateFlow(t, md, client, &MarkdownUpdater{})
-	expected := "- [TestRepo (⭐42)](https://github.com/testowner/testrepo)"
-	if updated != expected {
-		t.Errorf("expected %q, got %q", expected, updated)
+	if strings.Contains(stderr.String(), "no GitHub credentials") || !strings.Contains(stderr.String(), "owner/b") {
+		t.Errorf("expected only a warning about owner/b, got %q", stderr.String())
 	}
-}
-
-func TestUpdateStarCountsMultiple(t *testing.T) {
-	mux := http.NewServeMux()
-	mux.HandleFunc("/repos/owner/repo1", func(w http.ResponseWriter, _ *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		_, _ = fmt.Fprint(w, `{"stargazers_count": 1}`)
-	})
-	mux.HandleFunc("/repos/owner/repo2", func(w http.ResponseWriter, _ *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		_, _ = fmt.Fprint(w, `{"stargazers_count": 2}`)
-	})
 
-	server := httptest.NewServer(mux)
-	defer server.Close()
-
-	client := github.NewClient(server.Client())
-	baseURL, _ := url.Parse(server.URL + "/")
-	client.BaseURL = baseURL
-
-	md := "- [R1](https://github.com/owner/repo1)\n- [R2](https://github.com/owner/repo2)"
-	updated := runUpdateFlow(t, md, client, &MarkdownUpdater{})
-	expected := "- [R1 (⭐1)](https://github.com/owner/repo1)\n- [R2 (⭐2)](https://github.com/owner/repo2)"
-	if updated != expected {
-		t.Errorf("expected %q, got %q", expected, updated)
+	// The API fills in what the fixture lacks when running online.
+	t.Setenv("GITHUB_TOKEN", "test_token")
+	server := newFakeAPI(t, map[string]int{"owner/a": 1, "owner/b": 20})
+	if code := run([]string{"-api-url", server.URL, "-fixture", fixture, doc}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
 	}
-}
-
-func TestUpdateStarCountsExistingStars(t *testing.T) {
-	mux := http.NewServeMux()
-	mux.HandleFunc("/repos/owner/repo", func(w http.ResponseWriter, _ *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		_, _ = fmt.Fprint(w, `{"stargazers_count": 10}`)
-	})
-
-	server := httptest.NewServer(mux)
-	defer server.Close()
-
-	client := github.NewClient(server.Client())
-	baseURL, _ := url.Parse(server.URL + "/")
-	client.BaseURL = baseURL
-
-	md := "- [R (⭐5)](https://github.com/owner/repo)"
-	updated := runUpdateFlow(t, md, client, &MarkdownUpdater{})
-	expected := "- [R (⭐10)](https://github.com/owner/repo)"
-	if updated != expected {
-		t.Errorf("expected %q, got %q", expected, updated)
+	expected = "- [A (⭐1.5k)](https://github.com/owner/a)\n- [B (⭐20)](https://github.com/owner/b)\n"
+	if got := mustRead(t, doc); got != expected || server.requests.Load() != 1 {
+		t.Errorf("expected %q with 1 API request, got %q with %d", expected, got, server.requests.Load())
 	}
 }
 
-func TestUpdateStarCountsAsciiDoc(t *testing.T) {
-	mux := http.NewServeMux()
-	mux.HandleFunc("/repos/testowner/adoc-repo", func(w http.ResponseWriter, _ *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		_, _ = fmt.Fprint(w, `{"stargazers_count": 99}`)
-	})
-
-	server := httptest.NewServer(mux)
-	defer server.Close()
-
-	client := github.NewClient(server.Client())
-	baseURL, _ := url.Parse(server.URL + "/")
-	client.BaseURL = baseURL
-
-	adoc := "link:https://github.com/testowner/adoc-repo[My Repo]"
-	updated := runUpdateFlow(t, adoc, client, &ASCIIDocUpdater{})
-	expected := "link:https://github.com/testowner/adoc-repo[My Repo (⭐99)]"
-	if updated != expected {
-		t.Errorf("expected %q, got %q", expected, updated)
+func TestRunWithAnnotations(t *testing.T) {
+	isolateAuthEnv(t)
+	t.Setenv("GITHUB_TOKEN", "test_token")
+	server := newFakeAPI(t, map[string]int{"upstream/repo": 4321, "org/mono": 1})
+	dir := t.TempDir()
+	doc := filepath.Join(dir, "list.md")
+	writeFile(t, doc, "- [Tool](https://github.com/org/mono) <!-- stars: upstream/repo -->\n"+
+		"- [Pinned](https://github.com/owner/p) <!-- stars: pin 1500 -->\n"+
+		"- [Hidden (⭐3)](https://github.com/owner/h) <!-- stars: hide -->\n")
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"update", "-api-url", server.URL, doc}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	expected := "- [Tool (⭐4.3k)](https://github.com/org/mono) <!-- stars: upstream/repo -->\n" +
+		"- [Pinned (⭐1.5k)](https://github.com/owner/p) <!-- stars: pin 1500 -->\n" +
+		"- [Hidden](https://github.com/owner/h) <!-- stars: hide -->\n"
+	if got := mustRead(t, doc); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+	if server.requests.Load() != 1 {
+		t.Errorf("expected only the upstream repository to be fetched, got %d requests", server.requests.Load())
+	}
+
+	// An invalid annotation fails the document with its line.
+	writeFile(t, doc, "- [Tool](https://github.com/org/mono) <!-- stars: pin many -->\n")
+	stderr.Reset()
+	if code := run([]string{"update", "-api-url", server.URL, doc}, &stdout, &stderr); code != 1 || !strings.Contains(stderr.String(), "line 1: invalid pinned star count") {
+		t.Errorf("expected exit code 1 with the invalid annotation, got %d: %s", code, stderr.String())
 	}
 }
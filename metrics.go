@@ -0,0 +1,343 @@
+// Package main provides the core functionality for updating GitHub star counts in Markdown and AsciiDoc files.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/stn1slv/github-markdown-stars-updater/pkg/stars"
+)
+
+// Registry identifies a package registry that provides a popularity metric.
+type Registry string
+
+// Supported package registries.
+const (
+	RegistryNPM    Registry = "npm"
+	RegistryPyPI   Registry = "pypi"
+	RegistryCrates Registry = "crates"
+	RegistryGo     Registry = "go"
+	RegistryDocker Registry = "docker"
+)
+
+// PackageRef points to a package in one of the supported registries.
+type PackageRef struct {
+	Registry Registry
+	Name     string
+}
+
+// String returns the "registry:name" form used in mapping files.
+func (p PackageRef) String() string {
+	return string(p.Registry) + ":" + p.Name
+}
+
+// parsePackageRef parses a "registry:name" reference such as "npm:react".
+func parsePackageRef(s string) (PackageRef, error) {
+	registry, name, ok := strings.Cut(strings.TrimSpace(s), ":")
+	if !ok || name == "" {
+		return PackageRef{}, fmt.Errorf("invalid package reference %q: expected registry:name", s)
+	}
+	switch r := Registry(strings.ToLower(registry)); r {
+	case RegistryNPM, RegistryPyPI, RegistryCrates, RegistryGo, RegistryDocker:
+		return PackageRef{Registry: r, Name: name}, nil
+	default:
+		return PackageRef{}, fmt.Errorf("unknown package registry %q in %q", registry, s)
+	}
+}
+
+const pkgNameChars = `[^/\s)\]\[?#"'<>]+`
+
+var (
+	npmLinkRe    = regexp.MustCompile(`https?://(?:www\.)?npmjs\.com/package/((?:@` + pkgNameChars + `/)?` + pkgNameChars + `)`)
+	pypiLinkRe   = regexp.MustCompile(`https?://pypi\.org/project/(` + pkgNameChars + `)`)
+	cratesLinkRe = regexp.MustCompile(`https?://crates\.io/crates/(` + pkgNameChars + `)`)
+	goLinkRe     = regexp.MustCompile(`https?://pkg\.go\.dev/([^\s)\]\[?#"'<>@]+)`)
+	dockerLinkRe = regexp.MustCompile(`https?://hub\.docker\.com/(?:r/(` + pkgNameChars + `/` + pkgNameChars + `)|_/(` + pkgNameChars + `))`)
+
+	goImportedByRe = regexp.MustCompile(`(?s)Imported by:?\s*(?:<[^>]*>\s*)*([\d,]+)`)
+)
+
+// packageLink is a package reference found at a byte offset of a line.
+type packageLink struct {
+	ref PackageRef
+	pos int
+}
+
+// findPackageLinks finds all package registry links in the given text.
+func findPackageLinks(text string) []packageLink {
+	var links []packageLink
+	collect := func(re *regexp.Regexp, registry Registry) {
+		for _, m := range re.FindAllStringSubmatchIndex(text, -1) {
+			name := ""
+			for i := 2; i+1 < len(m); i += 2 {
+				if m[i] >= 0 {
+					name = text[m[i]:m[i+1]]
+					break
+				}
+			}
+			if registry == RegistryDocker && !strings.Contains(name, "/") {
+				name = "library/" + name
+			}
+			links = append(links, packageLink{ref: PackageRef{Registry: registry, Name: strings.TrimSuffix(name, "/")}, pos: m[0]})
+		}
+	}
+	collect(npmLinkRe, RegistryNPM)
+	collect(pypiLinkRe, RegistryPyPI)
+	collect(cratesLinkRe, RegistryCrates)
+	collect(goLinkRe, RegistryGo)
+	collect(dockerLinkRe, RegistryDocker)
+	sort.SliceStable(links, func(i, j int) bool { return links[i].pos < links[j].pos })
+	return links
+}
+
+// collectPackageRefs associates the package links found in content with the GitHub repository
+// links reported by updater. A package link belongs to the closest repository link preceding it
+// on the same line (or the first one if the package link comes first). References from mapping
+// are added for every matching repository. Both mapping and the result are keyed by the canonical
+// "owner/repo" key.
+func collectPackageRefs(content string, updater stars.LinkUpdater, mapping map[string][]PackageRef) (map[string][]PackageRef, error) {
+	refs := make(map[string][]PackageRef)
+	add := func(repoURL string, ref PackageRef) {
+		key := stars.RepoKey(repoURL)
+		for _, existing := range refs[key] {
+			if existing == ref {
+				return
+			}
+		}
+		refs[key] = append(refs[key], ref)
+	}
+
+	for line := range strings.SplitSeq(content, "\n") {
+		repos, err := updater.FindRepos(line)
+		if err != nil {
+			return nil, err
+		}
+		if len(repos) == 0 {
+			continue
+		}
+
+		for _, link := range findPackageLinks(line) {
+			owner := repos[0]
+			for _, repoURL := range repos {
+				if idx := strings.Index(line, repoURL); idx >= 0 && idx < link.pos {
+					owner = repoURL
+				}
+			}
+			add(owner, link.ref)
+		}
+
+		for _, repoURL := range repos {
+			for _, ref := range mapping[stars.RepoKey(repoURL)] {
+				add(repoURL, ref)
+			}
+		}
+	}
+	return refs, nil
+}
+
+// loadPackageMapping reads a JSON file mapping "owner/repo" to a list of "registry:name" references.
+func loadPackageMapping(path string) (map[string][]PackageRef, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string][]string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse package mapping %s: %w", path, err)
+	}
+
+	mapping := make(map[string][]PackageRef, len(raw))
+	for repo, entries := range raw {
+		key := stars.RepoKey(repo)
+		for _, entry := range entries {
+			ref, err := parsePackageRef(entry)
+			if err != nil {
+				return nil, fmt.Errorf("package mapping %s, %s: %w", path, repo, err)
+			}
+			mapping[key] = append(mapping[key], ref)
+		}
+	}
+	return mapping, nil
+}
+
+// MetricsClient fetches popularity metrics from package registries.
+type MetricsClient struct {
+	HTTPClient *http.Client
+
+	NPMBaseURL    string
+	PyPIBaseURL   string
+	CratesBaseURL string
+	GoBaseURL     string
+	DockerBaseURL string
+}
+
+// newMetricsClient returns a MetricsClient that talks to the public registry endpoints.
+func newMetricsClient(httpClient *http.Client) *MetricsClient {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &MetricsClient{
+		HTTPClient:    httpClient,
+		NPMBaseURL:    "https://api.npmjs.org",
+		PyPIBaseURL:   "https://pypistats.org",
+		CratesBaseURL: "https://crates.io",
+		GoBaseURL:     "https://pkg.go.dev",
+		DockerBaseURL: "https://hub.docker.com",
+	}
+}
+
+// Fetch returns the popularity metric for the given package: weekly downloads for npm and PyPI,
+// total downloads for crates.io, the number of importers for Go modules and pulls for Docker Hub.
+func (c *MetricsClient) Fetch(ctx context.Context, ref PackageRef) (int, error) {
+	switch ref.Registry {
+	case RegistryNPM:
+		var resp struct {
+			Downloads int `json:"downloads"`
+		}
+		err := c.getJSON(ctx, c.NPMBaseURL+"/downloads/point/last-week/"+ref.Name, &resp)
+		return resp.Downloads, err
+	case RegistryPyPI:
+		var resp struct {
+			Data struct {
+				LastWeek int `json:"last_week"`
+			} `json:"data"`
+		}
+		err := c.getJSON(ctx, c.PyPIBaseURL+"/api/packages/"+url.PathEscape(strings.ToLower(ref.Name))+"/recent", &resp)
+		return resp.Data.LastWeek, err
+	case RegistryCrates:
+		var resp struct {
+			Crate struct {
+				Downloads int `json:"downloads"`
+			} `json:"crate"`
+		}
+		err := c.getJSON(ctx, c.CratesBaseURL+"/api/v1/crates/"+url.PathEscape(ref.Name), &resp)
+		return resp.Crate.Downloads, err
+	case RegistryGo:
+		return c.fetchGoImporters(ctx, ref.Name)
+	case RegistryDocker:
+		var resp struct {
+			PullCount int `json:"pull_count"`
+		}
+		err := c.getJSON(ctx, c.DockerBaseURL+"/v2/repositories/"+ref.Name+"/", &resp)
+		return resp.PullCount, err
+	default:
+		return 0, fmt.Errorf("unsupported package registry %q", ref.Registry)
+	}
+}
+
+// fetchGoImporters scrapes the "Imported by" counter from the pkg.go.dev module page,
+// which has no JSON API for it.
+func (c *MetricsClient) fetchGoImporters(ctx context.Context, module string) (int, error) {
+	body, err := c.get(ctx, c.GoBaseURL+"/"+module+"?tab=importedby")
+	if err != nil {
+		return 0, err
+	}
+	match := goImportedByRe.FindSubmatch(body)
+	if match == nil {
+		return 0, fmt.Errorf("importer count not found for Go module %s", module)
+	}
+	return strconv.Atoi(strings.ReplaceAll(string(match[1]), ",", ""))
+}
+
+func (c *MetricsClient) getJSON(ctx context.Context, rawURL string, v any) error {
+	body, err := c.get(ctx, rawURL)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("failed to decode response from %s: %w", rawURL, err)
+	}
+	return nil
+}
+
+func (c *MetricsClient) get(ctx context.Context, rawURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "markdown-github-stars-updater/"+version)
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: unexpected status %s", rawURL, resp.Status)
+	}
+	return body, nil
+}
+
+// formatMetric formats a package metric for display next to the star count, e.g. "npm 3.4M/wk".
+func formatMetric(ref PackageRef, value int) string {
+	count := stars.FormatCount(value)
+	switch ref.Registry {
+	case RegistryNPM, RegistryPyPI:
+		return fmt.Sprintf("%s %s/wk", ref.Registry, count)
+	case RegistryGo:
+		return fmt.Sprintf("go %s importers", count)
+	case RegistryDocker:
+		return fmt.Sprintf("docker %s pulls", count)
+	default:
+		return fmt.Sprintf("%s %s", ref.Registry, count)
+	}
+}
+
+// metricsLabel joins the formatted metrics of the given references. References without a fetched
+// value are skipped.
+func metricsLabel(refs []PackageRef, values map[PackageRef]int) string {
+	parts := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		if value, ok := values[ref]; ok {
+			parts = append(parts, formatMetric(ref, value))
+		}
+	}
+	return strings.Join(parts, " · ")
+}
+
+// newMetricsField resolves the package references of the repositories in content, fetches each
+// metric once and returns the {metrics} label field. Metrics that cannot be fetched are reported
+// as warnings and left out of the label.
+func newMetricsField(ctx context.Context, content string, updater stars.LinkUpdater, mapping map[string][]PackageRef, fetch func(context.Context, PackageRef) (int, error), warn io.Writer) (labelField, error) {
+	refs, err := collectPackageRefs(content, updater, mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[PackageRef]int)
+	attempted := make(map[PackageRef]bool)
+	for _, list := range refs {
+		for _, ref := range list {
+			if attempted[ref] {
+				continue
+			}
+			attempted[ref] = true
+			value, fetchErr := fetch(ctx, ref)
+			if fetchErr != nil {
+				_, _ = fmt.Fprintf(warn, "Warning: Could not fetch %s metric for %s: %v\n", ref.Registry, ref.Name, fetchErr)
+				continue
+			}
+			values[ref] = value
+		}
+	}
+
+	return func(key string, _ int) string {
+		return metricsLabel(refs[key], values)
+	}, nil
+}
@@ -0,0 +1,203 @@
+// Package main provides the core functionality for updating GitHub star counts in Markdown and AsciiDoc files.
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/stn1slv/github-markdown-stars-updater/pkg/stars"
+)
+
+func TestParsePackageRef(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    PackageRef
+		wantErr bool
+	}{
+		{input: "npm:react", want: PackageRef{RegistryNPM, "react"}},
+		{input: "npm:@types/node", want: PackageRef{RegistryNPM, "@types/node"}},
+		{input: "PyPI:requests", want: PackageRef{RegistryPyPI, "requests"}},
+		{input: "go:github.com/spf13/cobra", want: PackageRef{RegistryGo, "github.com/spf13/cobra"}},
+		{input: "docker:library/nginx", want: PackageRef{RegistryDocker, "library/nginx"}},
+		{input: "maven:junit", wantErr: true},
+		{input: "react", wantErr: true},
+		{input: "npm:", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parsePackageRef(tt.input)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("expected error for %q, got %v", tt.input, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("unexpected error for %q: %v", tt.input, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("For %q, expected %v, got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestCollectPackageRefs(t *testing.T) {
+	content := "- [Lib](https://github.com/owner/lib) - [npm](https://www.npmjs.com/package/@scope/lib), " +
+		"[image](https://hub.docker.com/_/nginx)\n" +
+		"- [Tool](https://github.com/owner/tool) see https://pkg.go.dev/github.com/owner/tool/v2 " +
+		"and [Other](https://github.com/owner/other) on https://crates.io/crates/other\n" +
+		"- [Py](https://pypi.org/project/requests) has no repository link\n" +
+		"- [Mapped](https://github.com/Owner/Mapped)"
+	mapping := map[string][]PackageRef{"owner/mapped": {{RegistryPyPI, "mapped"}}}
+
+	got, err := collectPackageRefs(content, &stars.MarkdownUpdater{}, mapping)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string][]PackageRef{
+		"owner/lib":    {{RegistryNPM, "@scope/lib"}, {RegistryDocker, "library/nginx"}},
+		"owner/tool":   {{RegistryGo, "github.com/owner/tool/v2"}},
+		"owner/other":  {{RegistryCrates, "other"}},
+		"owner/mapped": {{RegistryPyPI, "mapped"}},
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	for repoURL, refs := range expected {
+		if !slices.Equal(got[repoURL], refs) {
+			t.Errorf("%s: expected %v, got %v", repoURL, refs, got[repoURL])
+		}
+	}
+}
+
+func TestLoadPackageMapping(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "packages.json")
+	data := `{"https://github.com/Owner/Repo": ["npm:repo", "crates:repo"]}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	mapping, err := loadPackageMapping(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []PackageRef{{RegistryNPM, "repo"}, {RegistryCrates, "repo"}}
+	if !slices.Equal(mapping["owner/repo"], expected) {
+		t.Errorf("expected %v, got %v", expected, mapping["owner/repo"])
+	}
+
+	if err := os.WriteFile(path, []byte(`{"owner/repo": ["bower:repo"]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadPackageMapping(path); err == nil {
+		t.Error("expected error for unknown registry, got nil")
+	}
+}
+
+func TestMetricsClientFetch(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/downloads/point/last-week/@scope/lib", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = fmt.Fprint(w, `{"downloads": 3450000, "package": "@scope/lib"}`)
+	})
+	mux.HandleFunc("/api/packages/requests/recent", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = fmt.Fprint(w, `{"data": {"last_day": 1, "last_week": 1200, "last_month": 5000}}`)
+	})
+	mux.HandleFunc("/api/v1/crates/serde", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = fmt.Fprint(w, `{"crate": {"downloads": 420000000}}`)
+	})
+	mux.HandleFunc("/github.com/spf13/cobra", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("tab") != "importedby" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = fmt.Fprint(w, `<span data-test-id="UnitHeader-importedby">Imported by: <a href="?tab=importedby">183,547</a></span>`)
+	})
+	mux.HandleFunc("/v2/repositories/library/nginx/", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = fmt.Fprint(w, `{"pull_count": 1000000000}`)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := newMetricsClient(server.Client())
+	client.NPMBaseURL = server.URL
+	client.PyPIBaseURL = server.URL
+	client.CratesBaseURL = server.URL
+	client.GoBaseURL = server.URL
+	client.DockerBaseURL = server.URL
+
+	tests := []struct {
+		ref      PackageRef
+		expected int
+		label    string
+	}{
+		{PackageRef{RegistryNPM, "@scope/lib"}, 3450000, "npm 3.4M/wk"},
+		{PackageRef{RegistryPyPI, "Requests"}, 1200, "pypi 1.2k/wk"},
+		{PackageRef{RegistryCrates, "serde"}, 420000000, "crates 420M"},
+		{PackageRef{RegistryGo, "github.com/spf13/cobra"}, 183547, "go 183k importers"},
+		{PackageRef{RegistryDocker, "library/nginx"}, 1000000000, "docker 1B pulls"},
+	}
+
+	for _, tt := range tests {
+		got, err := client.Fetch(context.Background(), tt.ref)
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", tt.ref, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("%v: expected %d, got %d", tt.ref, tt.expected, got)
+		}
+		if label := formatMetric(tt.ref, got); label != tt.label {
+			t.Errorf("%v: expected label %q, got %q", tt.ref, tt.label, label)
+		}
+	}
+
+	if _, err := client.Fetch(context.Background(), PackageRef{RegistryNPM, "missing"}); err == nil {
+		t.Error("expected error for missing package, got nil")
+	}
+}
+
+func TestUpdateContentWithMetrics(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/downloads/point/last-week/lib", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = fmt.Fprint(w, `{"downloads": 25000}`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := newMetricsClient(server.Client())
+	client.NPMBaseURL = server.URL
+
+	md := "- [Lib (⭐1k · npm 1k/wk)](https://github.com/owner/lib) - [npm](https://www.npmjs.com/package/lib)\n" +
+		"- [Broken](https://github.com/owner/broken) - [npm](https://www.npmjs.com/package/broken)"
+	counts := map[string]int{"https://github.com/owner/lib": 1500, "https://github.com/owner/broken": 7}
+
+	updater := &stars.MarkdownUpdater{}
+	field, err := newMetricsField(context.Background(), md, updater, nil, client.Fetch, io.Discard)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	label, err := newLabelFunc(defaultTemplateFor("metrics"), map[string]labelField{"metrics": field}, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	updated, err := (&stars.MarkdownUpdater{Label: label}).UpdateContent(md, counts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := "- [Lib (⭐1.5k · npm 25k/wk)](https://github.com/owner/lib) - [npm](https://www.npmjs.com/package/lib)\n" +
+		"- [Broken (⭐7)](https://github.com/owner/broken) - [npm](https://www.npmjs.com/package/broken)"
+	if updated != expected {
+		t.Errorf("expected %q, got %q", expected, updated)
+	}
+}
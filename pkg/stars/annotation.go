@@ -0,0 +1,175 @@
+package stars
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// markdownAnnotationRe matches a "<!-- stars: ... -->" comment right after a Markdown link, on
+	// the same line.
+	markdownAnnotationRe = regexp.MustCompile(`^[ \t]*<!--[ \t]*stars:[ \t]*(.*?)[ \t]*-->`)
+	// asciidocAnnotationRe matches a stars attribute in the attribute list of an AsciiDoc link,
+	// e.g. "[Project,stars=upstream/repo]" or "[Project,stars="pin 1200"]".
+	asciidocAnnotationRe = regexp.MustCompile(`,[ \t]*stars=(?:"([^"]*)"|([^,]*))`)
+	// annotationValueRe finds the value of any annotation, for Locate.
+	annotationValueRe = regexp.MustCompile(`(?:<!--[ \t]*stars:|,[ \t]*stars=)[ \t]*"?([^\s",\]>]+)`)
+)
+
+// directiveNames are document directives that share the "stars:" comment prefix with annotations.
+var directiveNames = []string{"stale-after", "summary", "top", "end"}
+
+// annotation overrides how the star count of a single link is looked up.
+type annotation struct {
+	target string // repository URL to look up instead of the link, if set
+	pinned bool   // stars is a manual value and nothing is looked up
+	stars  int
+	hide   bool // the link gets no label
+}
+
+// parseAnnotation parses the value of an annotation: "hide", "pin <count>" or the repository to
+// look up, as "owner/repo" or a URL. ok is false for document directives.
+func parseAnnotation(value string) (a annotation, ok bool, err error) {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return annotation{}, false, fmt.Errorf("empty stars annotation")
+	}
+	for _, name := range directiveNames {
+		if fields[0] == name {
+			return annotation{}, false, nil
+		}
+	}
+
+	switch {
+	case fields[0] == "hide" && len(fields) == 1:
+		return annotation{hide: true}, true, nil
+	case fields[0] == "pin" && len(fields) == 2:
+		count, err := strconv.Atoi(fields[1])
+		if err != nil || count < 0 {
+			return annotation{}, false, fmt.Errorf("invalid pinned star count %q", fields[1])
+		}
+		return annotation{pinned: true, stars: count}, true, nil
+	case len(fields) == 1:
+		if _, ok := NormalizeRepoURL(value); ok {
+			return annotation{target: value}, true, nil
+		}
+		if parts := strings.Split(value, "/"); len(parts) == 2 && parts[0] != "" && parts[1] != "" {
+			target := "https://github.com/" + value
+			if _, ok := NormalizeRepoURL(target); ok {
+				return annotation{target: target}, true, nil
+			}
+		}
+	}
+	return annotation{}, false, fmt.Errorf("invalid stars annotation %q (expected owner/repo, a repository URL, \"pin <count>\" or \"hide\")", value)
+}
+
+// markdownAnnotation returns the annotation following the Markdown link that ends at offset end.
+func markdownAnnotation(content string, end int) (annotation, bool, error) {
+	match := markdownAnnotationRe.FindStringSubmatch(content[end:])
+	if match == nil {
+		return annotation{}, false, nil
+	}
+	a, ok, err := parseAnnotation(match[1])
+	if err != nil {
+		return annotation{}, false, fmt.Errorf("line %d: %w", lineAt(content, end), err)
+	}
+	return a, ok, nil
+}
+
+// asciidocAnnotation returns the annotation in text, the attribute list of an AsciiDoc link that
+// starts at offset start, and the offset in text where the link text ends: the first comma
+// outside double quotes when the list has an annotation, or len(text).
+func asciidocAnnotation(content string, start int, text string) (annotation, int, bool, error) {
+	match := asciidocAnnotationRe.FindStringSubmatchIndex(text)
+	if match == nil {
+		return annotation{}, len(text), false, nil
+	}
+	var value string
+	if match[2] >= 0 {
+		value = text[match[2]:match[3]]
+	} else {
+		value = text[match[4]:match[5]]
+	}
+	a, ok, err := parseAnnotation(value)
+	if err != nil {
+		return annotation{}, 0, false, fmt.Errorf("line %d: %w", lineAt(content, start), err)
+	}
+	if !ok {
+		return annotation{}, len(text), false, nil
+	}
+	return a, linkTextEnd(text), true, nil
+}
+
+// linkTextEnd returns the offset of the first comma outside double quotes in attrs.
+func linkTextEnd(attrs string) int {
+	quoted := false
+	for i, r := range attrs {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == ',' && !quoted:
+			return i
+		}
+	}
+	return len(attrs)
+}
+
+// lookup returns the repository URL whose star count the link to repoURL shows, and false when
+// the annotation pins or hides the label instead.
+func (a annotation) lookup(repoURL string) (string, bool) {
+	if a.pinned || a.hide {
+		return "", false
+	}
+	return a.repo(repoURL), true
+}
+
+// repo returns the URL of the repository the label of a link to repoURL is about.
+func (a annotation) repo(repoURL string) string {
+	if a.target != "" {
+		return a.target
+	}
+	return repoURL
+}
+
+// label returns the label of a link to repoURL with the annotation; ok is false when the star
+// count of the repository to look up is not in index.
+func (a annotation) label(fn LabelFunc, repoURL string, index map[string]int) (string, bool) {
+	switch {
+	case a.hide:
+		return "", true
+	case a.pinned:
+		return renderLabel(fn, repoURL, a.stars), true
+	}
+	lookupURL, _ := a.lookup(repoURL)
+	key, ok := NormalizeRepoURL(lookupURL)
+	if !ok {
+		return "", false
+	}
+	count, ok := index[key]
+	if !ok {
+		return "", false
+	}
+	return renderLabel(fn, lookupURL, count), true
+}
+
+// indexAnnotation returns the offset and length of the first annotation value at or after from
+// that names the repository with the canonical key, or -1.
+func indexAnnotation(content, key string, from int) (int, int) {
+	for from < len(content) {
+		match := annotationValueRe.FindStringSubmatchIndex(content[from:])
+		if match == nil {
+			break
+		}
+		if RepoKey(content[from+match[2]:from+match[3]]) == key {
+			return from + match[2], match[3] - match[2]
+		}
+		from += match[1]
+	}
+	return -1, 0
+}
+
+func lineAt(content string, offset int) int {
+	return strings.Count(content[:offset], "\n") + 1
+}
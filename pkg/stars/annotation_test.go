@@ -0,0 +1,168 @@
+package stars
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseAnnotation(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected annotation
+		ok       bool
+		err      string
+	}{
+		{"upstream/repo", annotation{target: "https://github.com/upstream/repo"}, true, ""},
+		{"https://github.com/Upstream/Repo.git", annotation{target: "https://github.com/Upstream/Repo.git"}, true, ""},
+		{"pin 1200", annotation{pinned: true, stars: 1200}, true, ""},
+		{"pin  0", annotation{pinned: true}, true, ""},
+		{"hide", annotation{hide: true}, true, ""},
+		{"stale-after 12", annotation{}, false, ""},
+		{"top 10 section=\"Tools\"", annotation{}, false, ""},
+		{"end", annotation{}, false, ""},
+		{"pin many", annotation{}, false, "invalid pinned star count"},
+		{"pin -1", annotation{}, false, "invalid pinned star count"},
+		{"hdie", annotation{}, false, "invalid stars annotation"},
+		{"topics/go", annotation{}, false, "invalid stars annotation"},
+		{"owner/repo/extra", annotation{}, false, "invalid stars annotation"},
+		{"", annotation{}, false, "empty stars annotation"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, ok, err := parseAnnotation(tt.value)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected an error containing %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ok != tt.ok || got != tt.expected {
+				t.Errorf("expected %+v, %v, got %+v, %v", tt.expected, tt.ok, got, ok)
+			}
+		})
+	}
+}
+
+func TestAnnotations(t *testing.T) {
+	stars := map[string]int{"org/mono": 50, "upstream/repo": 12345, "owner/fork": 3}
+	tests := []struct {
+		name     string
+		updater  LinkUpdater
+		content  string
+		repos    []string
+		expected string
+	}{
+		{
+			name:     "Markdown redirect",
+			updater:  &MarkdownUpdater{},
+			content:  "- [Tool](https://github.com/org/mono/tree/main/tool) <!-- stars: upstream/repo --> - a tool\n",
+			repos:    []string{"https://github.com/upstream/repo"},
+			expected: "- [Tool (⭐12k)](https://github.com/org/mono/tree/main/tool) <!-- stars: upstream/repo --> - a tool\n",
+		},
+		{
+			name:     "Markdown redirect to a URL replaces an old label",
+			updater:  &MarkdownUpdater{},
+			content:  "[Fork (⭐3)](https://github.com/owner/fork)<!--stars:https://github.com/upstream/repo-->",
+			repos:    []string{"https://github.com/upstream/repo"},
+			expected: "[Fork (⭐12k)](https://github.com/owner/fork)<!--stars:https://github.com/upstream/repo-->",
+		},
+		{
+			name:     "Markdown pin and hide",
+			updater:  &MarkdownUpdater{},
+			content:  "[A](https://github.com/org/mono) <!-- stars: pin 2500 -->\n[B (⭐3)](https://github.com/owner/fork) <!-- stars: hide -->\n[C](https://github.com/owner/fork)",
+			repos:    []string{"https://github.com/owner/fork"},
+			expected: "[A (⭐2.5k)](https://github.com/org/mono) <!-- stars: pin 2500 -->\n[B](https://github.com/owner/fork) <!-- stars: hide -->\n[C (⭐3)](https://github.com/owner/fork)",
+		},
+		{
+			name:     "Markdown annotation on the next line or after other text is not applied",
+			updater:  &MarkdownUpdater{},
+			content:  "[A](https://github.com/owner/fork)\n<!-- stars: hide -->\n[B](https://github.com/owner/fork) see <!-- stars: hide -->",
+			repos:    []string{"https://github.com/owner/fork", "https://github.com/owner/fork"},
+			expected: "[A (⭐3)](https://github.com/owner/fork)\n<!-- stars: hide -->\n[B (⭐3)](https://github.com/owner/fork) see <!-- stars: hide -->",
+		},
+		{
+			name:     "Markdown directive after a link",
+			updater:  &MarkdownUpdater{},
+			content:  "[A](https://github.com/owner/fork) <!-- stars:stale-after 6 -->",
+			repos:    []string{"https://github.com/owner/fork"},
+			expected: "[A (⭐3)](https://github.com/owner/fork) <!-- stars:stale-after 6 -->",
+		},
+		{
+			name:     "AsciiDoc redirect",
+			updater:  &ASCIIDocUpdater{},
+			content:  "* link:https://github.com/org/mono[Tool (⭐50),stars=upstream/repo] - a tool\n",
+			repos:    []string{"https://github.com/upstream/repo"},
+			expected: "* link:https://github.com/org/mono[Tool (⭐12k),stars=upstream/repo] - a tool\n",
+		},
+		{
+			name:     "AsciiDoc pin and hide with other attributes",
+			updater:  &ASCIIDocUpdater{},
+			content:  "https://github.com/org/mono[A,window=_blank,stars=\"pin 7\"] https://github.com/owner/fork[B (⭐3), stars=hide, role=x]",
+			repos:    []string{},
+			expected: "https://github.com/org/mono[A (⭐7),window=_blank,stars=\"pin 7\"] https://github.com/owner/fork[B, stars=hide, role=x]",
+		},
+		{
+			name:     "AsciiDoc quoted link text with a comma",
+			updater:  &ASCIIDocUpdater{},
+			content:  "https://github.com/org/mono[\"Tools, and more (⭐1)\",stars=upstream/repo]",
+			repos:    []string{"https://github.com/upstream/repo"},
+			expected: "https://github.com/org/mono[\"Tools, and more (⭐12k)\",stars=upstream/repo]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repos, err := tt.updater.FindRepos(tt.content)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(repos, tt.repos) {
+				t.Errorf("expected repos %v, got %v", tt.repos, repos)
+			}
+			updated, err := tt.updater.UpdateContent(tt.content, stars)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if updated != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, updated)
+			}
+		})
+	}
+}
+
+func TestInvalidAnnotation(t *testing.T) {
+	tests := []struct {
+		updater LinkUpdater
+		content string
+	}{
+		{&MarkdownUpdater{}, "# List\n\n[A](https://github.com/owner/a) <!-- stars: pin lots -->\n"},
+		{&ASCIIDocUpdater{}, "= List\n\nhttps://github.com/owner/a[A,stars=hdie]\n"},
+	}
+	for _, tt := range tests {
+		if _, err := tt.updater.FindRepos(tt.content); err == nil || !strings.Contains(err.Error(), "line 3:") {
+			t.Errorf("FindRepos: expected an error on line 3, got %v", err)
+		}
+		if _, err := tt.updater.UpdateContent(tt.content, map[string]int{"owner/a": 1}); err == nil {
+			t.Error("UpdateContent: expected an error")
+		}
+	}
+}
+
+func TestLocateAnnotation(t *testing.T) {
+	content := "[Mono](https://github.com/org/mono) <!-- stars: upstream/repo -->\n[Up](https://github.com/upstream/repo)\n"
+	repos, err := (&MarkdownUpdater{}).FindRepos(content)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := Locate(content, repos)
+	expected := []Location{
+		{URL: "https://github.com/upstream/repo", Key: "upstream/repo", Line: 1, Column: 49},
+		{URL: "https://github.com/upstream/repo", Key: "upstream/repo", Line: 2, Column: 6},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+}
@@ -0,0 +1,97 @@
+package stars
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var asciidocLinkRe = regexp.MustCompile(`(?:link:)?(` + githubURLPattern + `/[^/\[\s]+/[^\[\s]+)\[([^\]]*)\]`)
+
+// ASCIIDocUpdater implements LinkUpdater for AsciiDoc files.
+type ASCIIDocUpdater struct {
+	// Label renders the text inside the parentheses; DefaultLabel is used when nil.
+	Label LabelFunc
+	// Badge, if set, shows the label as a badge image after the link instead of in the link text.
+	Badge BadgeFunc
+	// Chart, if set, adds a chart image after the label or badge.
+	Chart ChartFunc
+}
+
+// FindRepos finds all GitHub repository links in the given content. A link with a
+// "stars=owner/repo" attribute is reported as the annotated repository, and links whose attribute
+// pins or hides the label are left out.
+func (a *ASCIIDocUpdater) FindRepos(content string) ([]string, error) {
+	matches := asciidocLinkRe.FindAllStringSubmatchIndex(content, -1)
+
+	repos := make([]string, 0, len(matches))
+	for _, match := range matches {
+		repoURL := content[match[2]:match[3]]
+		if _, ok := NormalizeRepoURL(repoURL); !ok {
+			continue
+		}
+		ann, _, _, err := asciidocAnnotation(content, match[0], content[match[4]:match[5]])
+		if err != nil {
+			return nil, err
+		}
+		if lookupURL, ok := ann.lookup(repoURL); ok {
+			repos = append(repos, lookupURL)
+		}
+	}
+	return repos, nil
+}
+
+// UpdateContent updates the content by injecting star counts using the provided map.
+// The map may be keyed by repository URLs in any supported form or by "owner/repo".
+func (a *ASCIIDocUpdater) UpdateContent(content string, stars map[string]int) (string, error) {
+	return a.updateIndexed(content, indexStars(stars))
+}
+
+// updateIndexed is UpdateContent with the star counts keyed by canonical "owner/repo".
+func (a *ASCIIDocUpdater) updateIndexed(content string, index map[string]int) (string, error) {
+	matches := asciidocLinkRe.FindAllStringSubmatchIndex(content, -1)
+	var err error
+	updated := rewrite(content, matches, func(match []int) (string, int, bool) {
+		fullMatch := content[match[0]:match[1]]
+		repoURL := content[match[2]:match[3]]
+		text := content[match[4]:match[5]]
+		if _, ok := NormalizeRepoURL(repoURL); !ok || err != nil {
+			return "", 0, false
+		}
+
+		// With an annotation, the text is an attribute list and the label goes at the end of the
+		// first attribute, inside its quotes if it has any.
+		ann, textEnd, _, annErr := asciidocAnnotation(content, match[0], text)
+		if annErr != nil {
+			err = annErr
+			return "", 0, false
+		}
+		label, ok := ann.label(a.Label, repoURL, index)
+		if !ok {
+			return "", 0, false
+		}
+
+		// Badges and charts follow the link; the previous ones are replaced.
+		end := match[1] + len(asciidocImagesRe.FindString(content[match[1]:]))
+		imgs := images(a.Badge, a.Chart, ann.repo(repoURL), label, asciidocImage)
+		if a.Badge != nil {
+			label = ""
+		}
+
+		prefix := ""
+		if strings.HasPrefix(fullMatch, "link:") {
+			prefix = "link:"
+		}
+
+		linkText := text[:textEnd]
+		newText := withStarsInfo(linkText, label) + text[textEnd:]
+		if quoted := strings.TrimSpace(linkText); len(quoted) >= 2 && quoted[0] == '"' && quoted[len(quoted)-1] == '"' {
+			newText = `"` + withStarsInfo(quoted[1:len(quoted)-1], label) + `"` + text[textEnd:]
+		}
+		return fmt.Sprintf("%s%s[%s]%s", prefix, repoURL, newText, imgs), end, true
+	})
+	if err != nil {
+		return "", err
+	}
+	return updated, nil
+}
@@ -1,5 +1,4 @@
-// Package main provides the core functionality for updating GitHub star counts in Markdown and AsciiDoc files.
-package main
+package stars
 
 import (
 	"slices"
@@ -100,6 +99,12 @@ func TestAsciiDocUpdateContent(t *testing.T) {
 			stars:    map[string]int{"https://github.com/owner/repo/tree/main": 42},
 			expected: "link:https://github.com/owner/repo/tree/main[Source (⭐42)]",
 		},
+		{
+			name:     "Lookup by canonical owner/repo",
+			content:  "link:http://www.GitHub.com/Owner/Repo.git[Repo] and https://github.com/owner/repo/tree/main[Source]",
+			stars:    map[string]int{"owner/repo": 7},
+			expected: "link:http://www.GitHub.com/Owner/Repo.git[Repo (⭐7)] and https://github.com/owner/repo/tree/main[Source (⭐7)]",
+		},
 	}
 
 	updater := &ASCIIDocUpdater{}
@@ -0,0 +1,74 @@
+package stars
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// BadgeFunc returns the URL of the badge image showing label, the rendered label of the
+// repository at repoURL, e.g. a shields.io URL or the path of a local SVG file. The label is the
+// image's alternative text, which starts with "⭐" so that the badge can be found and replaced on
+// the next run.
+type BadgeFunc func(repoURL, label string) string
+
+// ChartFunc returns the URL of the chart image shown after the label of the repository at
+// repoURL, e.g. a star history sparkline, and false when the repository has no chart. Charts have
+// the alternative text "📈", by which they are found and replaced on the next run.
+type ChartFunc func(repoURL string) (string, bool)
+
+// chartAlt is the alternative text of chart images.
+const chartAlt = "📈"
+
+var (
+	// markdownImagesRe matches the badge and chart images right after a Markdown link, e.g.
+	// " ![⭐1.2k](badge.svg) ![📈](chart.svg)".
+	markdownImagesRe = regexp.MustCompile(`^(?:[ \t]*!\[(?:⭐|📈)[^\]]*\]\([^)\s]*\))+`)
+	// asciidocImagesRe matches the badge and chart images right after an AsciiDoc link, e.g.
+	// " image:badge.svg[⭐1.2k] image:chart.svg[📈]".
+	asciidocImagesRe = regexp.MustCompile(`^(?:[ \t]*image:[^\[\s]+\[(?:⭐|📈)[^\]]*\])+`)
+)
+
+// images returns the badge and chart images shown after a link whose label is about the
+// repository at repoURL, formatted by image, or "" without a label.
+func images(badge BadgeFunc, chart ChartFunc, repoURL, label string, image func(url, alt string) string) string {
+	if label == "" {
+		return ""
+	}
+	var s string
+	if badge != nil {
+		s += image(badge(repoURL, label), label)
+	}
+	if chart != nil {
+		if url, ok := chart(repoURL); ok {
+			s += image(url, chartAlt)
+		}
+	}
+	return s
+}
+
+func markdownImage(url, alt string) string {
+	return " ![" + alt + "](" + url + ")"
+}
+
+func asciidocImage(url, alt string) string {
+	return " image:" + url + "[" + alt + "]"
+}
+
+// ShieldsBadge is a BadgeFunc returning a static shields.io badge with the label baked in, e.g.
+// "https://img.shields.io/badge/stars-1.2k-blue".
+func ShieldsBadge(_ string, label string) string {
+	return "https://img.shields.io/badge/stars-" + shieldsEscape(BadgeMessage(label)) + "-blue"
+}
+
+// BadgeMessage returns the text a badge shows for label: the label without its "⭐" prefix.
+func BadgeMessage(label string) string {
+	return strings.TrimSpace(strings.TrimPrefix(label, "⭐"))
+}
+
+// shieldsEscape escapes s for a path segment of a static shields.io badge, where "-" and "_" are
+// separators and must be doubled.
+func shieldsEscape(s string) string {
+	s = strings.NewReplacer("-", "--", "_", "__").Replace(s)
+	return url.PathEscape(s)
+}
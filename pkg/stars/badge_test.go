@@ -0,0 +1,138 @@
+package stars
+
+import (
+	"testing"
+)
+
+func TestBadges(t *testing.T) {
+	badge := func(repoURL, label string) string { return "badges/" + RepoKey(repoURL) + ".svg" }
+	counts := map[string]int{"owner/a": 1234, "upstream/repo": 12345}
+	tests := []struct {
+		name     string
+		updater  LinkUpdater
+		content  string
+		expected string
+	}{
+		{
+			name:     "Markdown inserts a badge and removes the text label",
+			updater:  &MarkdownUpdater{Badge: badge},
+			content:  "- [A (⭐1)](https://github.com/owner/a) - text\n",
+			expected: "- [A](https://github.com/owner/a) ![⭐1.2k](badges/owner/a.svg) - text\n",
+		},
+		{
+			name:     "Markdown replaces a badge after the annotation",
+			updater:  &MarkdownUpdater{Badge: badge},
+			content:  "[M](https://github.com/org/mono) <!-- stars: upstream/repo --> ![⭐1](https://img.shields.io/badge/stars-1-blue)",
+			expected: "[M](https://github.com/org/mono) <!-- stars: upstream/repo --> ![⭐12k](badges/upstream/repo.svg)",
+		},
+		{
+			name:     "Markdown hidden link loses its badge",
+			updater:  &MarkdownUpdater{Badge: badge},
+			content:  "[A](https://github.com/owner/a) <!-- stars: hide --> ![⭐1](badges/owner/a.svg)",
+			expected: "[A](https://github.com/owner/a) <!-- stars: hide -->",
+		},
+		{
+			name:     "Markdown text labels remove badges",
+			updater:  &MarkdownUpdater{},
+			content:  "[A](https://github.com/owner/a) ![⭐1](badges/owner/a.svg) ![logo](logo.png)",
+			expected: "[A (⭐1.2k)](https://github.com/owner/a) ![logo](logo.png)",
+		},
+		{
+			name:     "AsciiDoc inserts and replaces badges",
+			updater:  &ASCIIDocUpdater{Badge: badge},
+			content:  "* link:https://github.com/owner/a[A (⭐1)]\n* https://github.com/org/mono[M,stars=upstream/repo] image:old.svg[⭐1] text\n",
+			expected: "* link:https://github.com/owner/a[A] image:badges/owner/a.svg[⭐1.2k]\n* https://github.com/org/mono[M,stars=upstream/repo] image:badges/upstream/repo.svg[⭐12k] text\n",
+		},
+		{
+			name:     "AsciiDoc text labels remove badges",
+			updater:  &ASCIIDocUpdater{},
+			content:  "link:https://github.com/owner/a[A] image:badges/owner/a.svg[⭐1]",
+			expected: "link:https://github.com/owner/a[A (⭐1.2k)]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updated, err := tt.updater.UpdateContent(tt.content, counts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if updated != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, updated)
+			}
+			again, err := tt.updater.UpdateContent(updated, counts)
+			if err != nil || again != updated {
+				t.Errorf("expected a stable result, got %q, %v", again, err)
+			}
+		})
+	}
+}
+
+func TestCharts(t *testing.T) {
+	chart := func(repoURL string) (string, bool) {
+		if key := RepoKey(repoURL); key != "owner/none" {
+			return "charts/" + key + ".svg", true
+		}
+		return "", false
+	}
+	badge := func(repoURL, label string) string { return "badge.svg" }
+	counts := map[string]int{"owner/a": 1234, "owner/none": 5}
+	tests := []struct {
+		name     string
+		updater  LinkUpdater
+		content  string
+		expected string
+	}{
+		{
+			name:     "Markdown chart after the label",
+			updater:  &MarkdownUpdater{Chart: chart},
+			content:  "- [A](https://github.com/owner/a) ![📈](old.svg) - text\n- [N](https://github.com/owner/none) ![📈](old.svg)\n",
+			expected: "- [A (⭐1.2k)](https://github.com/owner/a) ![📈](charts/owner/a.svg) - text\n- [N (⭐5)](https://github.com/owner/none)\n",
+		},
+		{
+			name:     "Markdown chart after the badge",
+			updater:  &MarkdownUpdater{Badge: badge, Chart: chart},
+			content:  "[A](https://github.com/owner/a) ![📈](charts/owner/a.svg) ![⭐1](badge.svg)",
+			expected: "[A](https://github.com/owner/a) ![⭐1.2k](badge.svg) ![📈](charts/owner/a.svg)",
+		},
+		{
+			name:     "AsciiDoc chart",
+			updater:  &ASCIIDocUpdater{Badge: badge, Chart: chart},
+			content:  "link:https://github.com/owner/a[A (⭐1)] image:x.svg[📈]",
+			expected: "link:https://github.com/owner/a[A] image:badge.svg[⭐1.2k] image:charts/owner/a.svg[📈]",
+		},
+		{
+			name:     "Charts are removed when disabled",
+			updater:  &ASCIIDocUpdater{},
+			content:  "link:https://github.com/owner/a[A] image:badge.svg[⭐1] image:charts/owner/a.svg[📈] image:logo.png[Logo]",
+			expected: "link:https://github.com/owner/a[A (⭐1.2k)] image:logo.png[Logo]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updated, err := tt.updater.UpdateContent(tt.content, counts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if updated != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, updated)
+			}
+		})
+	}
+}
+
+func TestShieldsBadge(t *testing.T) {
+	tests := []struct {
+		label    string
+		expected string
+	}{
+		{"⭐1.2k", "https://img.shields.io/badge/stars-1.2k-blue"},
+		{"⭐1.3k ↑140 in 30d", "https://img.shields.io/badge/stars-1.3k%20%E2%86%91140%20in%2030d-blue"},
+		{"⭐40 · pushed 2026-09-30", "https://img.shields.io/badge/stars-40%20%C2%B7%20pushed%202026--09--30-blue"},
+		{"⭐5 · my_tag", "https://img.shields.io/badge/stars-5%20%C2%B7%20my__tag-blue"},
+	}
+	for _, tt := range tests {
+		if got := ShieldsBadge("https://github.com/owner/a", tt.label); got != tt.expected {
+			t.Errorf("ShieldsBadge(%q): expected %q, got %q", tt.label, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+// Package stars updates the GitHub star count labels of repository links in Markdown and AsciiDoc
+// documents, e.g. "[Project](https://github.com/owner/repo)" becomes
+// "[Project (⭐1.2k)](https://github.com/owner/repo)".
+//
+// Update is the entry point: it finds the links in a document, looks up each repository once
+// through a StarFetcher and rewrites the labels. UpdateStream does the same for documents too
+// large to hold in memory, reading them block by block. The building blocks are exported for
+// callers that need more control: the LinkUpdater implementations MarkdownUpdater and
+// ASCIIDocUpdater separate finding links from rewriting them, NormalizeRepoURL maps every form of
+// a repository link to its canonical "owner/repo" key, FindLinks and Locate report where the
+// links are, and FormatStarCount renders counts the way labels show them.
+//
+// Labels always start with "⭐" and sit in parentheses at the end of the link text, so that they
+// can be found and replaced on the next run. Text outside the labels is left untouched.
+package stars
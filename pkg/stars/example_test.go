@@ -0,0 +1,87 @@
+package stars_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/stn1slv/github-markdown-stars-updater/pkg/stars"
+)
+
+func ExampleUpdate() {
+	counts := map[string]int{"golang/go": 128400, "owner/tool": 2501}
+	fetcher := stars.StarFetcherFunc(func(_ context.Context, repo string) (int, error) {
+		return counts[repo], nil
+	})
+
+	doc := "- [Go (⭐120k)](https://github.com/golang/go)\n- [Tool](https://github.com/Owner/Tool.git)"
+	updated, err := stars.Update(context.Background(), doc, stars.FormatMarkdown, fetcher)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(updated)
+	// Output:
+	// - [Go (⭐128k)](https://github.com/golang/go)
+	// - [Tool (⭐2.5k)](https://github.com/Owner/Tool.git)
+}
+
+func ExampleUpdateStream() {
+	fetcher := stars.NewMapFetcher(map[string]int{"golang/go": 128400})
+
+	// Any io.ReadSeeker works, e.g. an *os.File of a document too large to read into memory.
+	doc := strings.NewReader("# Languages\n\n- [Go](https://github.com/golang/go)\n")
+	if err := stars.UpdateStream(context.Background(), doc, os.Stdout, stars.FormatMarkdown, fetcher); err != nil {
+		panic(err)
+	}
+	// Output:
+	// # Languages
+	//
+	// - [Go (⭐128k)](https://github.com/golang/go)
+}
+
+func ExampleWithLabel() {
+	fetcher := stars.StarFetcherFunc(func(context.Context, string) (int, error) { return 1234, nil })
+	label := func(_ string, count int) string { return fmt.Sprintf("⭐%d", count) }
+
+	updated, _ := stars.Update(context.Background(), "link:https://github.com/owner/repo[Repo]",
+		stars.FormatASCIIDoc, fetcher, stars.WithLabel(label))
+	fmt.Println(updated)
+	// Output: link:https://github.com/owner/repo[Repo (⭐1234)]
+}
+
+func ExampleNormalizeRepoURL() {
+	for _, link := range []string{
+		"https://github.com/owner/repo",
+		"http://www.GitHub.com/Owner/Repo.git",
+		"https://github.com/owner/repo/tree/main/docs",
+		"https://github.com/topics/go",
+	} {
+		key, ok := stars.NormalizeRepoURL(link)
+		fmt.Printf("%q %v\n", key, ok)
+	}
+	// Output:
+	// "owner/repo" true
+	// "owner/repo" true
+	// "owner/repo" true
+	// "" false
+}
+
+func ExampleMarkdownUpdater() {
+	updater := &stars.MarkdownUpdater{}
+	doc := "[A](https://github.com/owner/a) and [B (⭐1)](https://github.com/owner/b)"
+
+	repos, _ := updater.FindRepos(doc)
+	fmt.Println(repos)
+
+	updated, _ := updater.UpdateContent(doc, map[string]int{"owner/a": 999, "owner/b": 10500})
+	fmt.Println(updated)
+	// Output:
+	// [https://github.com/owner/a https://github.com/owner/b]
+	// [A (⭐999)](https://github.com/owner/a) and [B (⭐10k)](https://github.com/owner/b)
+}
+
+func ExampleFormatStarCount() {
+	fmt.Println(stars.FormatStarCount(999), stars.FormatStarCount(4708), stars.FormatStarCount(78456))
+	// Output: 999 4.7k 78k
+}
@@ -0,0 +1,17 @@
+package stars
+
+import "context"
+
+// StarFetcher looks up the star count of a repository.
+type StarFetcher interface {
+	// FetchStars returns the star count of the repository with the canonical key "owner/repo".
+	FetchStars(ctx context.Context, repo string) (int, error)
+}
+
+// StarFetcherFunc adapts a function to the StarFetcher interface.
+type StarFetcherFunc func(ctx context.Context, repo string) (int, error)
+
+// FetchStars calls f.
+func (f StarFetcherFunc) FetchStars(ctx context.Context, repo string) (int, error) {
+	return f(ctx, repo)
+}
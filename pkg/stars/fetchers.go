@@ -0,0 +1,115 @@
+package stars
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/go-github/v68/github"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrNotFound is returned, possibly wrapped, by fetchers that have no star count for a repository.
+var ErrNotFound = errors.New("repository not found")
+
+// MapFetcher is an in-memory StarFetcher serving fixed star counts keyed by canonical
+// "owner/repo". Use NewMapFetcher to build one from keys in other forms.
+type MapFetcher map[string]int
+
+// NewMapFetcher returns a MapFetcher for counts, which may be keyed by repository URLs in any form
+// accepted by NormalizeRepoURL or by "owner/repo".
+func NewMapFetcher(counts map[string]int) MapFetcher {
+	return MapFetcher(indexStars(counts))
+}
+
+// FetchStars returns the count stored for repo, or ErrNotFound.
+func (m MapFetcher) FetchStars(_ context.Context, repo string) (int, error) {
+	count, ok := m[RepoKey(repo)]
+	if !ok {
+		return 0, fmt.Errorf("%s: %w", repo, ErrNotFound)
+	}
+	return count, nil
+}
+
+// LoadFixture reads star counts from a JSON or YAML file mapping repositories to counts, e.g.
+//
+//	{"owner/repo": 1234, "https://github.com/other/tool": 56}
+//
+// Files ending in .json are parsed as JSON, all others as YAML.
+func LoadFixture(path string) (MapFetcher, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+
+	var counts map[string]int
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, &counts)
+	} else {
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		if err = dec.Decode(&counts); errors.Is(err, io.EOF) {
+			err = nil
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse fixture %s: %w", path, err)
+	}
+	return NewMapFetcher(counts), nil
+}
+
+// GitHubFetcher fetches star counts from the GitHub REST API.
+type GitHubFetcher struct {
+	// Client is the API client; an unauthenticated client for github.com is used when nil.
+	Client *github.Client
+}
+
+// FetchStars returns the stargazer count of repo. Repositories the API does not know, or does not
+// show to the client, are reported as ErrNotFound.
+func (g *GitHubFetcher) FetchStars(ctx context.Context, repo string) (int, error) {
+	owner, name, ok := strings.Cut(RepoKey(repo), "/")
+	if !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+		return 0, fmt.Errorf("invalid repository %q (expected owner/repo)", repo)
+	}
+
+	client := g.Client
+	if client == nil {
+		client = github.NewClient(nil)
+	}
+	repository, resp, err := client.Repositories.Get(ctx, owner, name)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return 0, fmt.Errorf("%s: %w", repo, ErrNotFound)
+		}
+		return 0, err
+	}
+	return repository.GetStargazersCount(), nil
+}
+
+// Chain returns a StarFetcher that asks each fetcher in turn and returns the first count found,
+// e.g. a fixture file with the GitHub API as fallback. When every fetcher fails, the result
+// matches ErrNotFound if all of them reported it, and joins the other errors otherwise.
+func Chain(fetchers ...StarFetcher) StarFetcher {
+	return StarFetcherFunc(func(ctx context.Context, repo string) (int, error) {
+		var errs []error
+		for _, f := range fetchers {
+			count, err := f.FetchStars(ctx, repo)
+			if err == nil {
+				return count, nil
+			}
+			if !errors.Is(err, ErrNotFound) {
+				errs = append(errs, err)
+			}
+		}
+		if len(errs) == 0 {
+			return 0, fmt.Errorf("%s: %w", repo, ErrNotFound)
+		}
+		return 0, errors.Join(errs...)
+	})
+}
@@ -0,0 +1,151 @@
+package stars
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// newGitHubAPI returns a client for a GitHub API stand-in serving the given star counts.
+func newGitHubAPI(t *testing.T, counts map[string]int) *github.Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count, ok := counts[strings.TrimPrefix(r.URL.Path, "/repos/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"stargazers_count": %d}`, count)
+	}))
+	t.Cleanup(server.Close)
+
+	client := github.NewClient(server.Client())
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	return client
+}
+
+func TestGitHubFetcherUpdate(t *testing.T) {
+	fetcher := &GitHubFetcher{Client: newGitHubAPI(t, map[string]int{
+		"testowner/testrepo": 42, "owner/repo1": 1, "owner/repo2": 2, "owner/repo": 10, "testowner/adoc-repo": 99,
+	})}
+
+	tests := []struct {
+		name     string
+		content  string
+		format   Format
+		expected string
+	}{
+		{
+			name:     "Single link",
+			content:  "- [TestRepo](https://github.com/testowner/testrepo)",
+			format:   FormatMarkdown,
+			expected: "- [TestRepo (⭐42)](https://github.com/testowner/testrepo)",
+		},
+		{
+			name:     "Multiple links",
+			content:  "- [R1](https://github.com/owner/repo1)\n- [R2](https://github.com/owner/repo2)",
+			format:   FormatMarkdown,
+			expected: "- [R1 (⭐1)](https://github.com/owner/repo1)\n- [R2 (⭐2)](https://github.com/owner/repo2)",
+		},
+		{
+			name:     "Existing stars",
+			content:  "- [R (⭐5)](https://github.com/owner/repo)",
+			format:   FormatMarkdown,
+			expected: "- [R (⭐10)](https://github.com/owner/repo)",
+		},
+		{
+			name:     "AsciiDoc",
+			content:  "link:https://github.com/testowner/adoc-repo[My Repo]",
+			format:   FormatASCIIDoc,
+			expected: "link:https://github.com/testowner/adoc-repo[My Repo (⭐99)]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updated, err := Update(context.Background(), tt.content, tt.format, fetcher)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if updated != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, updated)
+			}
+		})
+	}
+
+	if _, err := fetcher.FetchStars(context.Background(), "owner/missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestMapFetcher(t *testing.T) {
+	fetcher := NewMapFetcher(map[string]int{"https://github.com/Owner/Repo.git": 7})
+	if count, err := fetcher.FetchStars(context.Background(), "owner/repo"); err != nil || count != 7 {
+		t.Errorf("expected 7, got %d, %v", count, err)
+	}
+	if _, err := fetcher.FetchStars(context.Background(), "owner/other"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestLoadFixture(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"stars.json", `{"owner/a": 1500, "https://github.com/Owner/B": 3}`},
+		{"stars.yaml", "owner/a: 1500\nhttps://github.com/Owner/B: 3\n"},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(dir, tt.name)
+		if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		fetcher, err := LoadFixture(path)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		if fetcher["owner/a"] != 1500 || fetcher["owner/b"] != 3 {
+			t.Errorf("%s: unexpected counts %v", tt.name, fetcher)
+		}
+	}
+
+	bad := filepath.Join(dir, "bad.json")
+	if err := os.WriteFile(bad, []byte(`{"owner/a": "many"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadFixture(bad); err == nil {
+		t.Error("expected an error for a non-numeric count")
+	}
+}
+
+func TestChain(t *testing.T) {
+	fixture := MapFetcher{"owner/pinned": 100}
+	failing := StarFetcherFunc(func(context.Context, string) (int, error) { return 0, errors.New("rate limited") })
+	api := MapFetcher{"owner/pinned": 1, "owner/live": 2}
+
+	chain := Chain(fixture, api)
+	for repo, expected := range map[string]int{"owner/pinned": 100, "owner/live": 2} {
+		if count, err := chain.FetchStars(context.Background(), repo); err != nil || count != expected {
+			t.Errorf("%s: expected %d, got %d, %v", repo, expected, count, err)
+		}
+	}
+	if _, err := chain.FetchStars(context.Background(), "owner/none"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound when no fetcher knows the repository, got %v", err)
+	}
+
+	_, err := Chain(fixture, failing).FetchStars(context.Background(), "owner/none")
+	if err == nil || errors.Is(err, ErrNotFound) || !strings.Contains(err.Error(), "rate limited") {
+		t.Errorf("expected the real failure to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+package stars
+
+import "fmt"
+
+// FormatStarCount formats a star count for display in a label: exact below 1000, with one
+// decimal below 10k ("2.5k") and in whole thousands above ("78k").
+func FormatStarCount(stars int) string {
+	switch {
+	case stars < 1000:
+		return fmt.Sprintf("%d", stars)
+	case stars < 10000:
+		wholePart := stars / 1000
+		decimalPart := (stars % 1000) / 100
+		if decimalPart == 0 {
+			return fmt.Sprintf("%dk", wholePart)
+		}
+		return fmt.Sprintf("%d.%dk", wholePart, decimalPart)
+	default:
+		return fmt.Sprintf("%dk", stars/1000)
+	}
+}
+
+// FormatCount formats large counters such as download numbers. Values below one million
+// use the same format as FormatStarCount; larger values are shown in millions or billions.
+func FormatCount(n int) string {
+	switch {
+	case n < 1_000_000:
+		return FormatStarCount(n)
+	case n < 1_000_000_000:
+		return formatScaled(n, 1_000_000, "M")
+	default:
+		return formatScaled(n, 1_000_000_000, "B")
+	}
+}
+
+// formatScaled renders n divided by unit with at most one decimal digit for values below ten units.
+func formatScaled(n, unit int, suffix string) string {
+	wholePart := n / unit
+	if wholePart >= 10 {
+		return fmt.Sprintf("%d%s", wholePart, suffix)
+	}
+	decimalPart := (n % unit) / (unit / 10) //nolint:mnd
+	if decimalPart == 0 {
+		return fmt.Sprintf("%d%s", wholePart, suffix)
+	}
+	return fmt.Sprintf("%d.%d%s", wholePart, decimalPart, suffix)
+}
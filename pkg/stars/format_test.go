@@ -0,0 +1,49 @@
+package stars
+
+import (
+	"testing"
+)
+
+func TestFormatStarCount(t *testing.T) {
+	tests := []struct {
+		stars    int
+		expected string
+	}{
+		{999, "999"},
+		{1000, "1k"},
+		{2501, "2.5k"},
+		{4708, "4.7k"},
+		{5038, "5k"},
+		{6100, "6.1k"},
+		{12000, "12k"},
+		{78456, "78k"},
+	}
+
+	for _, test := range tests {
+		result := FormatStarCount(test.stars)
+		if result != test.expected {
+			t.Errorf("For %d stars, expected '%s' but got '%s'", test.stars, test.expected, result)
+		}
+	}
+}
+
+func TestFormatCount(t *testing.T) {
+	tests := []struct {
+		value    int
+		expected string
+	}{
+		{999, "999"},
+		{2501, "2.5k"},
+		{999_999, "999k"},
+		{1_000_000, "1M"},
+		{3_450_000, "3.4M"},
+		{42_000_000, "42M"},
+		{1_200_000_000, "1.2B"},
+	}
+
+	for _, test := range tests {
+		if got := FormatCount(test.value); got != test.expected {
+			t.Errorf("For %d, expected '%s' but got '%s'", test.value, test.expected, got)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package stars
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Link is a repository link in a document, with its annotation applied.
+type Link struct {
+	Markup string // the link as written, with its annotation, e.g. "[Tool (⭐1.2k)](https://github.com/owner/tool)"
+	URL    string // the link target as written
+	Repo   string // URL of the repository whose stars the link shows; "" when pinned or hidden
+	Pinned bool   // the link shows the pinned count Stars
+	Stars  int
+	Hidden bool // the link shows no label
+	Line   int  // 1-based
+	Column int  // 1-based, in characters, of URL
+}
+
+// FindLinks returns the repository links of content in document order. Unlike FindRepos, it
+// also returns the links whose annotation pins or hides their label.
+func FindLinks(content string, format Format) ([]Link, error) {
+	var links []Link
+	offset, line, lineStart := 0, 1, 0
+	add := func(start, urlStart int, markup, repoURL string, ann annotation) {
+		if n := strings.Count(content[offset:start], "\n"); n > 0 {
+			line += n
+			lineStart = strings.LastIndexByte(content[:start], '\n') + 1
+		}
+		offset = start
+		link := Link{Markup: markup, URL: repoURL, Pinned: ann.pinned, Stars: ann.stars, Hidden: ann.hide, Line: line,
+			Column: utf8.RuneCountInString(content[lineStart:urlStart]) + 1}
+		link.Repo, _ = ann.lookup(repoURL)
+		links = append(links, link)
+	}
+
+	switch format {
+	case FormatMarkdown:
+		for _, match := range markdownLinkRe.FindAllStringSubmatchIndex(content, -1) {
+			repoURL := content[match[4]:match[5]]
+			if _, ok := NormalizeRepoURL(repoURL); !ok {
+				continue
+			}
+			ann, ok, err := markdownAnnotation(content, match[1])
+			if err != nil {
+				return nil, err
+			}
+			end := match[1]
+			if ok {
+				end += len(markdownAnnotationRe.FindString(content[end:]))
+			}
+			add(match[0], match[4], content[match[0]:end], repoURL, ann)
+		}
+	case FormatASCIIDoc:
+		for _, match := range asciidocLinkRe.FindAllStringSubmatchIndex(content, -1) {
+			repoURL := content[match[2]:match[3]]
+			if _, ok := NormalizeRepoURL(repoURL); !ok {
+				continue
+			}
+			ann, _, _, err := asciidocAnnotation(content, match[0], content[match[4]:match[5]])
+			if err != nil {
+				return nil, err
+			}
+			add(match[0], match[2], content[match[0]:match[1]], repoURL, ann)
+		}
+	default:
+		return nil, fmt.Errorf("unknown format %q (expected %q or %q)", format, FormatMarkdown, FormatASCIIDoc)
+	}
+	return links, nil
+}
@@ -0,0 +1,57 @@
+package stars
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindLinks(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   Format
+		content  string
+		expected []Link
+	}{
+		{
+			name:   "Markdown",
+			format: FormatMarkdown,
+			content: "# List\n\n- [A (⭐3)](https://github.com/owner/a) - text\n- [Tool](https://github.com/org/mono/tree/main/tool) <!-- stars: up/tool --> - text\n" +
+				"[P](https://github.com/owner/p)<!--stars:pin 7--> [H](https://github.com/owner/h) <!-- stars: hide -->\n" +
+				"[D](https://github.com/owner/d) <!-- stars:stale-after 6 --> [Topics](https://github.com/topics/go)\n",
+			expected: []Link{
+				{Markup: "[A (⭐3)](https://github.com/owner/a)", URL: "https://github.com/owner/a", Repo: "https://github.com/owner/a", Line: 3, Column: 12},
+				{Markup: "[Tool](https://github.com/org/mono/tree/main/tool) <!-- stars: up/tool -->", URL: "https://github.com/org/mono/tree/main/tool", Repo: "https://github.com/up/tool", Line: 4, Column: 10},
+				{Markup: "[P](https://github.com/owner/p)<!--stars:pin 7-->", URL: "https://github.com/owner/p", Pinned: true, Stars: 7, Line: 5, Column: 5},
+				{Markup: "[H](https://github.com/owner/h) <!-- stars: hide -->", URL: "https://github.com/owner/h", Hidden: true, Line: 5, Column: 55},
+				{Markup: "[D](https://github.com/owner/d)", URL: "https://github.com/owner/d", Repo: "https://github.com/owner/d", Line: 6, Column: 5},
+			},
+		},
+		{
+			name:    "AsciiDoc",
+			format:  FormatASCIIDoc,
+			content: "= List\n\n* link:https://github.com/owner/a[A]\n* https://github.com/org/mono[\"Mono, tool\",stars=up/tool]\n",
+			expected: []Link{
+				{Markup: "link:https://github.com/owner/a[A]", URL: "https://github.com/owner/a", Repo: "https://github.com/owner/a", Line: 3, Column: 8},
+				{Markup: "https://github.com/org/mono[\"Mono, tool\",stars=up/tool]", URL: "https://github.com/org/mono", Repo: "https://github.com/up/tool", Line: 4, Column: 3},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			links, err := FindLinks(tt.content, tt.format)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(links, tt.expected) {
+				t.Errorf("expected %+v, got %+v", tt.expected, links)
+			}
+		})
+	}
+
+	if _, err := FindLinks("[A](https://github.com/owner/a) <!-- stars: pin x -->", FormatMarkdown); err == nil {
+		t.Error("expected an error for an invalid annotation")
+	}
+	if _, err := FindLinks("", Format("rst")); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
@@ -0,0 +1,85 @@
+package stars
+
+import (
+	"fmt"
+	"regexp"
+)
+
+var markdownLinkRe = regexp.MustCompile(`\[([^\]]+)\]\((` + githubURLPattern + `/[^/)\s]+/[^)\s]+)\)`)
+
+// MarkdownUpdater implements LinkUpdater for Markdown files.
+type MarkdownUpdater struct {
+	// Label renders the text inside the parentheses; DefaultLabel is used when nil.
+	Label LabelFunc
+	// Badge, if set, shows the label as a badge image after the link instead of in the link text.
+	Badge BadgeFunc
+	// Chart, if set, adds a chart image after the label or badge.
+	Chart ChartFunc
+}
+
+// FindRepos finds all GitHub repository links in the given content. A link followed by a
+// "<!-- stars: owner/repo -->" annotation is reported as the annotated repository, and links whose
+// annotation pins or hides the label are left out.
+func (m *MarkdownUpdater) FindRepos(content string) ([]string, error) {
+	matches := markdownLinkRe.FindAllStringSubmatchIndex(content, -1)
+	repos := make([]string, 0, len(matches))
+	for _, match := range matches {
+		repoURL := content[match[4]:match[5]]
+		if _, ok := NormalizeRepoURL(repoURL); !ok {
+			continue
+		}
+		ann, _, err := markdownAnnotation(content, match[1])
+		if err != nil {
+			return nil, err
+		}
+		if lookupURL, ok := ann.lookup(repoURL); ok {
+			repos = append(repos, lookupURL)
+		}
+	}
+	return repos, nil
+}
+
+// UpdateContent updates the content by injecting star counts using the provided map.
+// The map may be keyed by repository URLs in any supported form or by "owner/repo".
+func (m *MarkdownUpdater) UpdateContent(content string, stars map[string]int) (string, error) {
+	return m.updateIndexed(content, indexStars(stars))
+}
+
+// updateIndexed is UpdateContent with the star counts keyed by canonical "owner/repo".
+func (m *MarkdownUpdater) updateIndexed(content string, index map[string]int) (string, error) {
+	matches := markdownLinkRe.FindAllStringSubmatchIndex(content, -1)
+	var err error
+	updated := rewrite(content, matches, func(match []int) (string, int, bool) {
+		itemName := content[match[2]:match[3]]
+		repoURL := content[match[4]:match[5]]
+		if _, ok := NormalizeRepoURL(repoURL); !ok || err != nil {
+			return "", 0, false
+		}
+
+		ann, annotated, annErr := markdownAnnotation(content, match[1])
+		if annErr != nil {
+			err = annErr
+			return "", 0, false
+		}
+		label, ok := ann.label(m.Label, repoURL, index)
+		if !ok {
+			return "", 0, false
+		}
+
+		// Badges and charts follow the link and its annotation; the previous ones are replaced.
+		annEnd := match[1]
+		if annotated {
+			annEnd += len(markdownAnnotationRe.FindString(content[annEnd:]))
+		}
+		end := annEnd + len(markdownImagesRe.FindString(content[annEnd:]))
+		imgs := images(m.Badge, m.Chart, ann.repo(repoURL), label, markdownImage)
+		if m.Badge != nil {
+			label = ""
+		}
+		return fmt.Sprintf("[%s](%s)%s%s", withStarsInfo(itemName, label), repoURL, content[match[1]:annEnd], imgs), end, true
+	})
+	if err != nil {
+		return "", err
+	}
+	return updated, nil
+}
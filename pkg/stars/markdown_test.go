@@ -0,0 +1,81 @@
+package stars
+
+import (
+	"testing"
+)
+
+func TestMarkdownFindRepos(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected []string
+	}{
+		{
+			name:     "Single link",
+			content:  "- [Project](https://github.com/owner/repo)",
+			expected: []string{"https://github.com/owner/repo"},
+		},
+		{
+			name:     "Multiple links",
+			content:  "[A](https://github.com/a/b) and [B](https://github.com/c/d)",
+			expected: []string{"https://github.com/a/b", "https://github.com/c/d"},
+		},
+		{
+			name:     "Link with existing stars",
+			content:  "[Repo (⭐100)](https://github.com/owner/repo)",
+			expected: []string{"https://github.com/owner/repo"},
+		},
+		{
+			name:     "URL variants keep their original text",
+			content:  "[A](http://www.GitHub.com/Owner/Repo.git) [B](https://github.com/owner/repo/tree/main/sub)",
+			expected: []string{"http://www.GitHub.com/Owner/Repo.git", "https://github.com/owner/repo/tree/main/sub"},
+		},
+		{
+			name:     "Non-repository GitHub link ignored",
+			content:  "[Topic](https://github.com/topics/markdown)",
+			expected: []string{},
+		},
+		{
+			name:     "Non-GitHub link ignored",
+			content:  "[Docs](https://docs.example.com/guide)",
+			expected: []string{},
+		},
+		{
+			name:     "No links",
+			content:  "Just some text without links.",
+			expected: []string{},
+		},
+	}
+
+	updater := &MarkdownUpdater{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := updater.FindRepos(tt.content)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(got) != len(tt.expected) {
+				t.Fatalf("expected %v, got %v", tt.expected, got)
+			}
+			for i := range got {
+				if got[i] != tt.expected[i] {
+					t.Errorf("index %d: expected %q, got %q", i, tt.expected[i], got[i])
+				}
+			}
+		})
+	}
+}
+
+func TestMarkdownUpdateContentNormalized(t *testing.T) {
+	md := "- [A](http://www.GitHub.com/Owner/Repo.git)\n- [B (⭐1)](https://github.com/owner/repo/tree/main/sub)"
+	stars := map[string]int{"owner/repo": 1200}
+
+	updated, err := (&MarkdownUpdater{}).UpdateContent(md, stars)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "- [A (⭐1.2k)](http://www.GitHub.com/Owner/Repo.git)\n- [B (⭐1.2k)](https://github.com/owner/repo/tree/main/sub)"
+	if updated != expected {
+		t.Errorf("expected %q, got %q", expected, updated)
+	}
+}
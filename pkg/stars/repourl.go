@@ -0,0 +1,145 @@
+package stars
+
+import (
+	"net/url"
+	"path"
+	"strings"
+	"unicode/utf8"
+)
+
+// githubURLPattern matches the scheme and host part of a GitHub link: http or https, an optional
+// "www." prefix and any casing of the host name.
+const githubURLPattern = `(?i:https?://(?:www\.)?github\.com)`
+
+// reservedOwners are first path segments on github.com that are not user or organization names.
+var reservedOwners = map[string]bool{
+	"about": true, "apps": true, "collections": true, "customer-stories": true, "enterprise": true,
+	"events": true, "explore": true, "features": true, "issues": true, "login": true, "marketplace": true,
+	"notifications": true, "orgs": true, "pricing": true, "pulls": true, "search": true, "security": true,
+	"settings": true, "site": true, "sponsors": true, "topics": true, "trending": true, "users": true,
+}
+
+// NormalizeRepoURL returns the canonical "owner/repo" key of a GitHub repository link. The key is
+// lower-cased, so links that differ only in scheme, "www." prefix, casing, a trailing ".git" or
+// deep paths such as "/tree/main/sub" map to the same repository. ok is false when the link does
+// not point to a repository.
+func NormalizeRepoURL(raw string) (key string, ok bool) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", false
+	}
+	if scheme := strings.ToLower(u.Scheme); scheme != "http" && scheme != "https" {
+		return "", false
+	}
+	if host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www."); host != "github.com" {
+		return "", false
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(u.Path, "/"), "/", 3) //nolint:mnd
+	if len(parts) < 2 {
+		return "", false
+	}
+	owner := strings.ToLower(parts[0])
+	repo := strings.TrimSuffix(strings.ToLower(parts[1]), ".git")
+	if owner == "" || repo == "" || reservedOwners[owner] {
+		return "", false
+	}
+	return owner + "/" + repo, true
+}
+
+// RepoKey returns the canonical key of a repository reference, which may be a repository URL in
+// any form accepted by NormalizeRepoURL or a plain "owner/repo" path.
+func RepoKey(s string) string {
+	if key, ok := NormalizeRepoURL(s); ok {
+		return key
+	}
+	return strings.ToLower(strings.Trim(strings.TrimSuffix(s, ".git"), "/"))
+}
+
+// MatchRepo reports whether the repository with the canonical key matches pattern, a repository
+// reference or a glob such as "owner/*".
+func MatchRepo(pattern, key string) bool {
+	ok, _ := path.Match(RepoKey(pattern), key)
+	return ok
+}
+
+// indexStars re-keys the star counts by canonical repository key.
+func indexStars(stars map[string]int) map[string]int {
+	index := make(map[string]int, len(stars))
+	for k, v := range stars {
+		index[RepoKey(k)] = v
+	}
+	return index
+}
+
+// Location is the position of a repository link in a document.
+type Location struct {
+	URL    string // the link as written
+	Key    string // canonical "owner/repo"
+	Line   int    // 1-based
+	Column int    // 1-based, in characters
+}
+
+// Locate returns the position of every link in repos, the FindRepos result for content, in
+// document order. Links are found as the URL immediately followed by ")" (Markdown) or "["
+// (AsciiDoc), searching on from the previous link. Repositories that FindRepos took from an
+// annotation are located at the annotation instead.
+func Locate(content string, repos []string) []Location {
+	locations := make([]Location, 0, len(repos))
+	offset := 0
+	// Lines are counted on from the previous link rather than from the top of the document.
+	counted, line := 0, 1
+	for _, repoURL := range repos {
+		key, ok := NormalizeRepoURL(repoURL)
+		if !ok {
+			continue
+		}
+		pos, length := indexLink(content, repoURL, offset), len(repoURL)
+		// A repository named by an annotation before the next link to it is located at the annotation.
+		end := len(content)
+		if pos >= 0 {
+			end = pos
+		}
+		if annPos, annLength := indexAnnotation(content[:end], key, offset); annPos >= 0 {
+			pos, length = annPos, annLength
+		}
+		if pos < 0 {
+			// Not expected for FindRepos results; restart from the top rather than losing the link.
+			if pos = indexLink(content, repoURL, 0); pos < 0 {
+				continue
+			}
+		}
+		offset = pos + length
+
+		if pos < counted {
+			counted, line = 0, 1
+		}
+		line += strings.Count(content[counted:pos], "\n")
+		counted = pos
+		lineStart := strings.LastIndexByte(content[:pos], '\n') + 1
+		locations = append(locations, Location{
+			URL:    repoURL,
+			Key:    key,
+			Line:   line,
+			Column: utf8.RuneCountInString(content[lineStart:pos]) + 1,
+		})
+	}
+	return locations
+}
+
+// indexLink returns the offset of the first occurrence of repoURL at or after from that is
+// followed by ")" or "[", or -1.
+func indexLink(content, repoURL string, from int) int {
+	for from <= len(content) {
+		i := strings.Index(content[from:], repoURL)
+		if i < 0 {
+			return -1
+		}
+		pos := from + i
+		if end := pos + len(repoURL); end < len(content) && (content[end] == ')' || content[end] == '[') {
+			return pos
+		}
+		from = pos + 1
+	}
+	return -1
+}
@@ -0,0 +1,60 @@
+package stars
+
+import (
+	"testing"
+)
+
+func TestNormalizeRepoURL(t *testing.T) {
+	tests := []struct {
+		input  string
+		want   string
+		wantOK bool
+	}{
+		{"https://github.com/owner/repo", "owner/repo", true},
+		{"http://github.com/owner/repo", "owner/repo", true},
+		{"https://www.github.com/owner/repo", "owner/repo", true},
+		{"https://GitHub.com/Owner/Repo", "owner/repo", true},
+		{"https://github.com/owner/repo.git", "owner/repo", true},
+		{"https://github.com/owner/repo/", "owner/repo", true},
+		{"https://github.com/owner/repo/tree/main/sub", "owner/repo", true},
+		{"https://github.com/owner/repo?tab=readme#usage", "owner/repo", true},
+		{"https://github.com/owner", "", false},
+		{"https://github.com/topics/go", "", false},
+		{"https://github.com/sponsors/owner", "", false},
+		{"https://gitlab.com/owner/repo", "", false},
+		{"ftp://github.com/owner/repo", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := NormalizeRepoURL(tt.input)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("For %q, expected (%q, %v), got (%q, %v)", tt.input, tt.want, tt.wantOK, got, ok)
+		}
+	}
+}
+
+func TestLocateRepos(t *testing.T) {
+	content := "# List\n\n- [Ä (⭐1)](https://github.com/o/r) and [R2](https://github.com/o/r2)\n- [Again](https://github.com/o/r)\n"
+	repos, _ := (&MarkdownUpdater{}).FindRepos(content)
+
+	got := Locate(content, repos)
+	expected := []Location{
+		{"https://github.com/o/r", "o/r", 3, 12},
+		{"https://github.com/o/r2", "o/r2", 3, 45},
+		{"https://github.com/o/r", "o/r", 4, 11},
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("expected %d locations, got %v", len(expected), got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("location %d: expected %+v, got %+v", i, expected[i], got[i])
+		}
+	}
+
+	adoc := "= List\n\n* https://github.com/o/r[R]\n"
+	repos, _ = (&ASCIIDocUpdater{}).FindRepos(adoc)
+	if got := Locate(adoc, repos); len(got) != 1 || got[0].Line != 3 || got[0].Column != 3 {
+		t.Errorf("unexpected AsciiDoc location: %+v", got)
+	}
+}
@@ -0,0 +1,139 @@
+package stars
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	// blockSize is the size from which a block ends at the next blank line. Links do not span
+	// paragraphs, so a blank line is a safe place to split a document. Small blocks also keep the
+	// link patterns fast, as the regexp package matches short inputs with a quicker engine.
+	blockSize = 4 << 10
+	// maxParagraphSize is the size from which a block ends at the next line break, so that long
+	// paragraphs and lists without blank lines are split too. Only a link broken across that line
+	// break is missed.
+	maxParagraphSize = 64 << 10
+	// maxLineSize is the longest line ScanBlocks accepts.
+	maxLineSize = 16 << 20
+)
+
+// ScanBlocks reads a document from r and calls fn with consecutive blocks of whole lines, which
+// together make up the document byte for byte. Blocks end at a blank line once they hold 4 KiB,
+// or at any line break once they hold 64 KiB, so that memory use stays bounded however large the
+// document is. A line longer than 16 MiB is an error.
+func ScanBlocks(r io.Reader, fn func(block string) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 2*blockSize), maxLineSize)
+	scanner.Split(splitBlocks)
+	for scanner.Scan() {
+		if err := fn(scanner.Text()); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return fmt.Errorf("reading document: line longer than %d MiB", maxLineSize>>20)
+		}
+		return fmt.Errorf("reading document: %w", err)
+	}
+	return nil
+}
+
+// splitBlocks is the bufio.SplitFunc of ScanBlocks.
+func splitBlocks(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	start := 0
+	for {
+		i := bytes.IndexByte(data[start:], '\n')
+		if i < 0 {
+			break
+		}
+		end := start + i + 1
+		blank := len(bytes.TrimSpace(data[start:end])) == 0
+		if end >= maxParagraphSize || (end >= blockSize && blank) {
+			return end, data[:end], nil
+		}
+		start = end
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// UpdateStream is Update for documents too large to hold in memory. It reads src twice, block by
+// block as ScanBlocks splits it: the first pass collects the linked repositories and fetches their
+// star counts, the second rewrites the labels and writes the document to dst. Memory use depends
+// on the block size and the number of distinct repositories, not on the size of the document.
+func UpdateStream(ctx context.Context, src io.ReadSeeker, dst io.Writer, format Format, fetcher StarFetcher, opts ...Option) error {
+	o := newUpdateOptions(opts)
+	updater, err := NewUpdaterWith(format, opts...)
+	if err != nil {
+		return err
+	}
+
+	start, err := src.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	var repos []string
+	seen := make(map[string]bool)
+	err = ScanBlocks(src, func(block string) error {
+		found, err := updater.FindRepos(block)
+		if err != nil {
+			return err
+		}
+		for _, repoURL := range found {
+			if key, _ := NormalizeRepoURL(repoURL); !seen[key] {
+				seen[key] = true
+				repos = append(repos, repoURL)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	stars, err := o.fetchStars(ctx, repos, fetcher)
+	if err != nil {
+		return err
+	}
+	if _, err := src.Seek(start, io.SeekStart); err != nil {
+		return err
+	}
+	_, err = UpdateBlocks(src, dst, updater, stars)
+	return err
+}
+
+// indexedUpdater is implemented by the LinkUpdaters of this package, which can rewrite a block with
+// star counts that are already keyed by canonical "owner/repo".
+type indexedUpdater interface {
+	updateIndexed(content string, index map[string]int) (string, error)
+}
+
+// UpdateBlocks is the second pass of UpdateStream: it reads the document from src block by block,
+// as ScanBlocks splits it, rewrites the labels with updater and the star counts in stars, and
+// writes the result to dst. The counts are keyed once for the whole document rather than once per
+// block. changed reports whether any label changed.
+func UpdateBlocks(src io.Reader, dst io.Writer, updater LinkUpdater, stars map[string]int) (changed bool, err error) {
+	index := indexStars(stars)
+	update := updater.UpdateContent
+	if u, ok := updater.(indexedUpdater); ok {
+		update = u.updateIndexed
+	}
+	err = ScanBlocks(src, func(block string) error {
+		updated, err := update(block, index)
+		if err != nil {
+			return err
+		}
+		changed = changed || updated != block
+		_, err = io.WriteString(dst, updated)
+		return err
+	})
+	return changed, err
+}
@@ -0,0 +1,208 @@
+package stars
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+// catalogue returns a Markdown or AsciiDoc document with n links to the given number of distinct
+// repositories, split into sections of ten list items.
+func catalogue(format Format, n, repos int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		if i%10 == 0 {
+			fmt.Fprintf(&b, "\n## Section %d\n\n", i/10)
+		}
+		url := fmt.Sprintf("https://github.com/owner/repo%d", i%repos)
+		if format == FormatASCIIDoc {
+			fmt.Fprintf(&b, "* %s[Project %d] - a library for doing something useful.\n", url, i)
+		} else {
+			fmt.Fprintf(&b, "- [Project %d](%s) - a library for doing something useful.\n", i, url)
+		}
+	}
+	return b.String()
+}
+
+func catalogueFetcher() StarFetcher {
+	return StarFetcherFunc(func(_ context.Context, repo string) (int, error) {
+		var n int
+		if _, err := fmt.Sscanf(repo, "owner/repo%d", &n); err != nil {
+			return 0, err
+		}
+		return n * 123, nil
+	})
+}
+
+func TestScanBlocks(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"empty", ""},
+		{"no trailing newline", "a\nb"},
+		{"short document", "# Title\n\n- [A](https://github.com/owner/a)\n"},
+		{"sections", catalogue(FormatMarkdown, 5000, 5000)},
+		{"one long list", strings.Repeat("- [A](https://github.com/owner/a)\r\n", 100000)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			blocks := 0
+			err := ScanBlocks(strings.NewReader(tt.content), func(block string) error {
+				blocks++
+				if len(block) > maxParagraphSize+1024 {
+					t.Errorf("block of %d bytes", len(block))
+				}
+				if block != tt.content[b.Len():b.Len()+len(block)] {
+					t.Fatalf("block %d does not continue the document", blocks)
+				}
+				b.WriteString(block)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if b.String() != tt.content {
+				t.Errorf("the blocks do not make up the document")
+			}
+			if len(tt.content) > 2*maxParagraphSize && blocks < 2 {
+				t.Errorf("expected a large document to be split, got %d block(s)", blocks)
+			}
+		})
+	}
+}
+
+func TestScanBlocksErrors(t *testing.T) {
+	stop := errors.New("stop")
+	if err := ScanBlocks(strings.NewReader("a\n"), func(string) error { return stop }); !errors.Is(err, stop) {
+		t.Errorf("expected the callback error, got %v", err)
+	}
+	long := strings.NewReader(strings.Repeat("a", maxLineSize+1))
+	if err := ScanBlocks(long, func(string) error { return nil }); err == nil || !strings.Contains(err.Error(), "line longer than") {
+		t.Errorf("expected a line length error, got %v", err)
+	}
+}
+
+func TestUpdateStream(t *testing.T) {
+	for _, format := range []Format{FormatMarkdown, FormatASCIIDoc} {
+		t.Run(string(format), func(t *testing.T) {
+			content := catalogue(format, 30000, 10000)
+			fetches := 0
+			fetcher := StarFetcherFunc(func(ctx context.Context, repo string) (int, error) {
+				fetches++
+				return catalogueFetcher().FetchStars(ctx, repo)
+			})
+
+			expected, err := Update(context.Background(), content, format, catalogueFetcher(), WithExclude("owner/repo1"))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var out bytes.Buffer
+			if err := UpdateStream(context.Background(), strings.NewReader(content), &out, format, fetcher, WithExclude("owner/repo1")); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if out.String() != expected {
+				t.Errorf("the streamed update differs from Update")
+			}
+			if fetches != 9999 {
+				t.Errorf("expected one fetch per included repository, got %d", fetches)
+			}
+		})
+	}
+}
+
+func TestUpdateStreamErrors(t *testing.T) {
+	failing := StarFetcherFunc(func(context.Context, string) (int, error) { return 0, ErrNotFound })
+	content := "- [A](https://github.com/owner/a)\n"
+
+	var out bytes.Buffer
+	if err := UpdateStream(context.Background(), strings.NewReader(content), &out, FormatMarkdown, failing); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the fetch error, got %v", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("expected no output after a fetch error, got %q", out.String())
+	}
+
+	var failed []string
+	err := UpdateStream(context.Background(), strings.NewReader(content), &out, FormatMarkdown, failing,
+		WithErrorHandler(func(repo string, _ error) { failed = append(failed, repo) }))
+	if err != nil || out.String() != content || len(failed) != 1 {
+		t.Errorf("expected the link untouched and one reported failure, got %v, %q, %v", err, out.String(), failed)
+	}
+
+	if err := UpdateStream(context.Background(), strings.NewReader(content), io.Discard, "text", failing); err == nil {
+		t.Error("expected an unknown format error")
+	}
+}
+
+func benchmarkSizes(b *testing.B, run func(b *testing.B, content string)) {
+	for _, n := range []int{1000, 10000, 100000, 500000} {
+		// Every repository is linked twice, as in catalogues that list projects in several sections.
+		content := catalogue(FormatMarkdown, n, n/2)
+		b.Run(fmt.Sprintf("%dKiB", len(content)>>10), func(b *testing.B) {
+			b.SetBytes(int64(len(content)))
+			b.ReportAllocs()
+			run(b, content)
+		})
+	}
+}
+
+func BenchmarkUpdate(b *testing.B) {
+	benchmarkSizes(b, func(b *testing.B, content string) {
+		for b.Loop() {
+			if _, err := Update(context.Background(), content, FormatMarkdown, catalogueFetcher()); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkUpdateStream(b *testing.B) {
+	benchmarkSizes(b, func(b *testing.B, content string) {
+		for b.Loop() {
+			err := UpdateStream(context.Background(), strings.NewReader(content), io.Discard, FormatMarkdown, catalogueFetcher())
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// updateByReplace is the former Markdown rewrite, which replaced the first occurrence of each
+// link in turn and so copied the whole document once per link. It is kept as a benchmark baseline.
+func updateByReplace(content string, stars map[string]int) string {
+	index := indexStars(stars)
+	for _, match := range markdownLinkRe.FindAllStringSubmatch(content, -1) {
+		key, ok := NormalizeRepoURL(match[2])
+		if !ok {
+			continue
+		}
+		count, ok := index[key]
+		if !ok {
+			continue
+		}
+		updated := fmt.Sprintf("[%s](%s)", withStarsInfo(match[1], renderLabel(nil, match[2], count)), match[2])
+		content = strings.Replace(content, match[0], updated, 1)
+	}
+	return content
+}
+
+func BenchmarkUpdateByReplace(b *testing.B) {
+	counts := make(map[string]int)
+	for i := 0; i < 50000; i++ {
+		counts[fmt.Sprintf("owner/repo%d", i)] = i * 123
+	}
+	benchmarkSizes(b, func(b *testing.B, content string) {
+		if len(content) > 1<<20 {
+			b.Skip("quadratic; takes minutes on larger documents")
+		}
+		for b.Loop() {
+			updateByReplace(content, counts)
+		}
+	})
+}
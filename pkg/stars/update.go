@@ -0,0 +1,109 @@
+package stars
+
+import (
+	"context"
+	"fmt"
+)
+
+// Option configures Update.
+type Option func(*updateOptions)
+
+type updateOptions struct {
+	label   LabelFunc
+	badge   BadgeFunc
+	chart   ChartFunc
+	exclude []string
+	onError func(repo string, err error)
+}
+
+// WithLabel renders the labels with fn instead of DefaultLabel.
+func WithLabel(fn LabelFunc) Option {
+	return func(o *updateOptions) { o.label = fn }
+}
+
+// WithBadge shows the labels as badge images from fn after the links instead of in the link text.
+func WithBadge(fn BadgeFunc) Option {
+	return func(o *updateOptions) { o.badge = fn }
+}
+
+// WithChart adds the chart images from fn after the labels or badges.
+func WithChart(fn ChartFunc) Option {
+	return func(o *updateOptions) { o.chart = fn }
+}
+
+// WithExclude leaves the links to repositories matching one of the patterns untouched. Patterns
+// are repository references or globs such as "owner/*", as accepted by MatchRepo.
+func WithExclude(patterns ...string) Option {
+	return func(o *updateOptions) { o.exclude = append(o.exclude, patterns...) }
+}
+
+// WithErrorHandler makes Update report repositories whose star count cannot be fetched to fn and
+// leave their links untouched, instead of failing.
+func WithErrorHandler(fn func(repo string, err error)) Option {
+	return func(o *updateOptions) { o.onError = fn }
+}
+
+// Update returns content with the star count label of every GitHub repository link refreshed. The
+// star count of each repository is fetched once, however many links point to it. Without
+// WithErrorHandler, the first fetch error is returned.
+func Update(ctx context.Context, content string, format Format, fetcher StarFetcher, opts ...Option) (string, error) {
+	o := newUpdateOptions(opts)
+	updater, err := NewUpdaterWith(format, opts...)
+	if err != nil {
+		return "", err
+	}
+	repos, err := updater.FindRepos(content)
+	if err != nil {
+		return "", err
+	}
+
+	stars, err := o.fetchStars(ctx, repos, fetcher)
+	if err != nil {
+		return "", err
+	}
+	return updater.UpdateContent(content, stars)
+}
+
+func newUpdateOptions(opts []Option) *updateOptions {
+	var o updateOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &o
+}
+
+// fetchStars looks up the star count of every repository linked by repos once, skipping excluded
+// repositories and, with an error handler, those whose count cannot be fetched.
+func (o *updateOptions) fetchStars(ctx context.Context, repos []string, fetcher StarFetcher) (map[string]int, error) {
+	stars := make(map[string]int)
+	failed := make(map[string]bool)
+	for _, repoURL := range repos {
+		key, ok := NormalizeRepoURL(repoURL)
+		if !ok || failed[key] || o.excluded(key) {
+			continue
+		}
+		if _, done := stars[key]; done {
+			continue
+		}
+		count, err := fetcher.FetchStars(ctx, key)
+		if err != nil {
+			if o.onError == nil {
+				return nil, fmt.Errorf("fetching stars for %s: %w", key, err)
+			}
+			o.onError(key, err)
+			failed[key] = true
+			continue
+		}
+		stars[key] = count
+	}
+	return stars, nil
+}
+
+func (o *updateOptions) excluded(key string) bool {
+	for _, pattern := range o.exclude {
+		if MatchRepo(pattern, key) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package stars
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestUpdate(t *testing.T) {
+	counts := map[string]int{"owner/a": 1500, "owner/b": 7, "skip/c": 3}
+	var calls []string
+	fetcher := StarFetcherFunc(func(_ context.Context, repo string) (int, error) {
+		calls = append(calls, repo)
+		count, ok := counts[repo]
+		if !ok {
+			return 0, errors.New("not found")
+		}
+		return count, nil
+	})
+
+	content := "- [A](https://github.com/owner/a)\n- [A again](https://github.com/Owner/A.git)\n- [B](https://github.com/owner/b)\n- [C](https://github.com/skip/c)\n"
+	got, err := Update(context.Background(), content, FormatMarkdown, fetcher, WithExclude("skip/*"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "- [A (⭐1.5k)](https://github.com/owner/a)\n- [A again (⭐1.5k)](https://github.com/Owner/A.git)\n- [B (⭐7)](https://github.com/owner/b)\n- [C](https://github.com/skip/c)\n"
+	if got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+	if len(calls) != 2 {
+		t.Errorf("expected one fetch per repository, got %v", calls)
+	}
+
+	missing := "- [A](https://github.com/owner/a)\n- [Gone](https://github.com/owner/gone)\n"
+	if _, err := Update(context.Background(), missing, FormatMarkdown, fetcher); err == nil {
+		t.Error("expected the fetch error without an error handler")
+	}
+
+	var failed []string
+	got, err = Update(context.Background(), missing, FormatMarkdown, fetcher,
+		WithErrorHandler(func(repo string, _ error) { failed = append(failed, repo) }),
+		WithLabel(func(_ string, stars int) string { return "⭐" + FormatCount(stars) + " stars" }))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "- [A (⭐1.5k stars)](https://github.com/owner/a)\n- [Gone](https://github.com/owner/gone)\n" || len(failed) != 1 || failed[0] != "owner/gone" {
+		t.Errorf("unexpected result %q with failures %v", got, failed)
+	}
+
+	if _, err := Update(context.Background(), content, Format("rst"), fetcher); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestFormatFromPath(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected Format
+		wantErr  bool
+	}{
+		{"README.md", FormatMarkdown, false},
+		{"docs/Guide.ADOC", FormatASCIIDoc, false},
+		{"notes.txt", "", true},
+	}
+	for _, tt := range tests {
+		got, err := FormatFromPath(tt.path)
+		if got != tt.expected || (err != nil) != tt.wantErr {
+			t.Errorf("For %s, expected %q (error %v), got %q, %v", tt.path, tt.expected, tt.wantErr, got, err)
+		}
+	}
+}
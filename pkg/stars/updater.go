@@ -0,0 +1,125 @@
+package stars
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// LinkUpdater defines the interface for updating repo links in different file formats.
+type LinkUpdater interface {
+	// FindRepos finds all GitHub repository links in the given content.
+	FindRepos(content string) ([]string, error)
+
+	// UpdateContent updates the content by injecting star counts using the provided map.
+	UpdateContent(content string, stars map[string]int) (string, error)
+}
+
+// LabelFunc renders the text placed inside the parentheses after a link, e.g. "⭐1.2k".
+// The result must start with "⭐" so that the label can be found and replaced on the next run.
+// An empty result removes the label from the link.
+type LabelFunc func(repoURL string, stars int) string
+
+// Format is a supported document format.
+type Format string
+
+// Supported document formats.
+const (
+	FormatMarkdown Format = "markdown"
+	FormatASCIIDoc Format = "asciidoc"
+)
+
+// FormatFromPath returns the format of a document from its file extension: .md and .markdown
+// are Markdown, .adoc and .asciidoc are AsciiDoc. Other extensions are an error, as guessing
+// could corrupt other files.
+func FormatFromPath(path string) (Format, error) {
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".md", ".markdown":
+		return FormatMarkdown, nil
+	case ".adoc", ".asciidoc":
+		return FormatASCIIDoc, nil
+	default:
+		return "", fmt.Errorf("unsupported file extension: %s. Supported: .md, .markdown, .adoc, .asciidoc", ext)
+	}
+}
+
+// NewUpdater returns the LinkUpdater for format, rendering labels with label. A nil label renders
+// the plain star count, as DefaultLabel does.
+func NewUpdater(format Format, label LabelFunc) (LinkUpdater, error) {
+	return NewUpdaterWith(format, WithLabel(label))
+}
+
+// NewUpdaterWith returns the LinkUpdater for format configured by the WithLabel, WithBadge and
+// WithChart options. Other options only apply to Update and UpdateStream.
+func NewUpdaterWith(format Format, opts ...Option) (LinkUpdater, error) {
+	o := newUpdateOptions(opts)
+	switch format {
+	case FormatMarkdown:
+		return &MarkdownUpdater{Label: o.label, Badge: o.badge, Chart: o.chart}, nil
+	case FormatASCIIDoc:
+		return &ASCIIDocUpdater{Label: o.label, Badge: o.badge, Chart: o.chart}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q (expected %q or %q)", format, FormatMarkdown, FormatASCIIDoc)
+	}
+}
+
+var (
+	starsInfoRe  = regexp.MustCompile(`\s*\(⭐[^)]*\)`)
+	multiSpaceRe = regexp.MustCompile(`\s{2,}`)
+)
+
+// DefaultLabel renders the plain star count label, e.g. "⭐1.2k".
+func DefaultLabel(_ string, stars int) string {
+	return "⭐" + FormatStarCount(stars)
+}
+
+// renderLabel renders the label for a link using fn, falling back to DefaultLabel when fn is nil.
+func renderLabel(fn LabelFunc, repoURL string, stars int) string {
+	if fn == nil {
+		fn = DefaultLabel
+	}
+	return fn(repoURL, stars)
+}
+
+// rewrite returns content with every match, given as submatch byte offsets in document order,
+// replaced by the text replace returns for it. The replaced text runs from the start of the match
+// to the end offset replace returns, which lets a replacement take in a badge after the link.
+// Matches for which replace reports false are kept as they are. Each link is rewritten exactly
+// where it was found, in a single pass.
+func rewrite(content string, matches [][]int, replace func(match []int) (string, int, bool)) string {
+	var b strings.Builder
+	b.Grow(len(content))
+	last := 0
+	for _, match := range matches {
+		if match[0] < last {
+			// Inside the badge replaced with the previous link.
+			continue
+		}
+		replacement, end, ok := replace(match)
+		if !ok {
+			continue
+		}
+		b.WriteString(content[last:match[0]])
+		b.WriteString(replacement)
+		last = end
+	}
+	b.WriteString(content[last:])
+	return b.String()
+}
+
+// removeStarsInfo removes the existing star count information from the input string.
+func removeStarsInfo(input string) string {
+	result := starsInfoRe.ReplaceAllString(input, "")
+	result = multiSpaceRe.ReplaceAllString(result, " ")
+	return strings.TrimSpace(result)
+}
+
+// withStarsInfo replaces the star count information in text with label, or removes it when label is empty.
+func withStarsInfo(text, label string) string {
+	clean := removeStarsInfo(text)
+	if label == "" {
+		return clean
+	}
+	return clean + " (" + label + ")"
+}
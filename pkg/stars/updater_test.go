@@ -0,0 +1,74 @@
+package stars
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRemoveStarsInfo(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"Project1 (⭐1k)", "Project1"},
+		{"Project2 (⭐1.5k)", "Project2"},
+		{"Project3", "Project3"},
+		{"Project4 (⭐1k) (extra notes)", "Project4 (extra notes)"},
+	}
+
+	for _, test := range tests {
+		output := removeStarsInfo(test.input)
+		if output != test.expected {
+			t.Errorf("Input: %s\nExpected: %s\nGot: %s", test.input, test.expected, output)
+		}
+	}
+}
+
+func TestUpdateContentRewritesInPlace(t *testing.T) {
+	// A label that differs per link shows which occurrence was rewritten.
+	counter := func() LabelFunc {
+		n := 0
+		return func(string, int) string {
+			n++
+			return fmt.Sprintf("⭐%d", n)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		updater  LinkUpdater
+		content  string
+		expected string
+	}{
+		{
+			name:    "Markdown link equal to an earlier rewrite",
+			updater: &MarkdownUpdater{Label: counter()},
+			content: "[A](https://github.com/owner/a)\n[A (⭐1)](https://github.com/owner/a)\n[A](https://github.com/owner/a)",
+			expected: "[A (⭐1)](https://github.com/owner/a)\n[A (⭐2)](https://github.com/owner/a)\n" +
+				"[A (⭐3)](https://github.com/owner/a)",
+		},
+		{
+			name:     "Markdown links around an unknown repository",
+			updater:  &MarkdownUpdater{Label: counter()},
+			content:  "[A](https://github.com/owner/a) [X](https://github.com/owner/x) [A](https://github.com/owner/a)",
+			expected: "[A (⭐1)](https://github.com/owner/a) [X](https://github.com/owner/x) [A (⭐2)](https://github.com/owner/a)",
+		},
+		{
+			name:     "AsciiDoc link equal to an earlier rewrite",
+			updater:  &ASCIIDocUpdater{Label: counter()},
+			content:  "https://github.com/owner/a[A] link:https://github.com/owner/a[A (⭐1)]",
+			expected: "https://github.com/owner/a[A (⭐1)] link:https://github.com/owner/a[A (⭐2)]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updated, err := tt.updater.UpdateContent(tt.content, map[string]int{"owner/a": 1})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if updated != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, updated)
+			}
+		})
+	}
+}
@@ -0,0 +1,252 @@
+// Package main provides the core functionality for updating GitHub star counts in Markdown and AsciiDoc files.
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/stn1slv/github-markdown-stars-updater/pkg/stars"
+)
+
+// Policy rule identifiers, as shown in violations and SARIF reports.
+const (
+	ruleMinStars = "min-stars"
+	ruleArchived = "archived"
+	ruleInactive = "inactive"
+	ruleLicense  = "license"
+	// ruleUnreachable is reported for links whose repository could not be fetched.
+	ruleUnreachable = "unreachable"
+)
+
+// policyRuleDescriptions describes every policy rule for the SARIF report.
+var policyRuleDescriptions = map[string]string{
+	ruleMinStars: "Listed repositories must have a minimum number of stars.",
+	ruleArchived: "Listed repositories must not be archived.",
+	ruleInactive: "Listed repositories must have been pushed to recently.",
+	ruleLicense:  "Listed repositories must use an allowed license.",
+
+	ruleUnreachable: "Listed repositories must exist and be accessible.",
+}
+
+// policyRules are the requirements every listed repository must meet. Zero values disable a rule.
+type policyRules struct {
+	minStars        int
+	forbidArchived  bool
+	maxInactiveDays int
+	licenses        []string // allowed SPDX identifiers
+}
+
+// detail returns how much repository data the rules need.
+func (p policyRules) detail() infoDetail {
+	if p.forbidArchived || p.maxInactiveDays > 0 || len(p.licenses) > 0 {
+		return detailActivity
+	}
+	return detailStars
+}
+
+// violation is a repository link that breaks a policy rule.
+type violation struct {
+	File    string
+	Line    int
+	Column  int
+	Rule    string
+	Repo    string
+	Message string
+}
+
+// String formats the violation like a compiler diagnostic, e.g.
+// "README.md:12:3: owner/repo has 12 stars, fewer than the required 50 (min-stars)".
+func (v violation) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s (%s)", v.File, v.Line, v.Column, v.Message, v.Rule)
+}
+
+// evaluate returns a violation without position for every rule the repository key breaks.
+func (p policyRules) evaluate(key string, info repoInfo, now time.Time) []violation {
+	var broken []violation
+	if p.minStars > 0 && info.Stars < p.minStars {
+		broken = append(broken, violation{Rule: ruleMinStars, Repo: key,
+			Message: fmt.Sprintf("%s has %d stars, fewer than the required %d", key, info.Stars, p.minStars)})
+	}
+	if p.forbidArchived && info.Archived {
+		broken = append(broken, violation{Rule: ruleArchived, Repo: key, Message: key + " is archived"})
+	}
+	if p.maxInactiveDays > 0 && !info.PushedAt.IsZero() && info.PushedAt.Before(now.AddDate(0, 0, -p.maxInactiveDays)) {
+		broken = append(broken, violation{Rule: ruleInactive, Repo: key,
+			Message: fmt.Sprintf("%s has had no push in %d days (last push %s)", key, p.maxInactiveDays, info.PushedAt.UTC().Format(historyDateLayout))})
+	}
+	if len(p.licenses) > 0 && !p.allowsLicense(info.License) {
+		license := info.License
+		if license == "" || license == "NOASSERTION" {
+			license = "no recognised license"
+		}
+		broken = append(broken, violation{Rule: ruleLicense, Repo: key,
+			Message: fmt.Sprintf("%s has %s, expected one of %s", key, license, strings.Join(p.licenses, ", "))})
+	}
+	return broken
+}
+
+func (p policyRules) allowsLicense(spdx string) bool {
+	for _, allowed := range p.licenses {
+		if strings.EqualFold(allowed, spdx) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkedLink is a repository link that was checked against the policy.
+type checkedLink struct {
+	File string
+	stars.Location
+	Stars   int
+	Fetched bool
+}
+
+// String formats the link like a violation, e.g. "README.md:12:3: owner/repo (⭐1.2k)".
+func (l checkedLink) String() string {
+	status := "could not be fetched"
+	if l.Fetched {
+		status = "(⭐" + stars.FormatStarCount(l.Stars) + ")"
+	}
+	return fmt.Sprintf("%s:%d:%d: %s %s", l.File, l.Line, l.Column, l.Key, status)
+}
+
+// checkPolicy fetches the repositories linked from files and returns the checked links and every
+// link that breaks the rules, in file and document order. With a base version (-base or
+// -base-file), only links that are new compared to the base are fetched and checked. Documents
+// are not modified.
+func checkPolicy(ctx context.Context, files []string, opts *options, fetcher *starFetcher) ([]checkedLink, []violation, error) {
+	var checked []checkedLink
+	var violations []violation
+	for _, file := range files {
+		content, _, err := readDocument(file)
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading %s: %w", file, err)
+		}
+
+		format, err := formatOf(file, opts)
+		if err != nil {
+			return nil, nil, err
+		}
+		// Annotations only change the label: the linked repository is checked.
+		locations, err := linkLocations(content, format)
+		if err != nil {
+			return nil, nil, fmt.Errorf("finding repositories in %s: %w", file, err)
+		}
+		repos := locationURLs(locations)
+
+		if opts.baseRev != "" || opts.baseFile != "" {
+			base, baseErr := readBase(ctx, file, opts)
+			if baseErr != nil {
+				return nil, nil, baseErr
+			}
+			baseLocations, baseErr := linkLocations(base, format)
+			if baseErr != nil {
+				return nil, nil, fmt.Errorf("finding repositories in the base of %s: %w", file, baseErr)
+			}
+			repos = newRepos(repos, locationURLs(baseLocations))
+			locations = filterLocations(locations, repos)
+		}
+
+		infos := fetcher.fetchInfo(ctx, repos, opts.exclude, opts.policy.detail())
+		for _, loc := range locations {
+			if isExcluded(opts.exclude, loc.Key) {
+				continue
+			}
+			info, ok := infos[loc.Key]
+			checked = append(checked, checkedLink{File: file, Location: loc, Stars: info.Stars, Fetched: ok})
+			if !ok {
+				violations = append(violations, violation{
+					File: file, Line: loc.Line, Column: loc.Column,
+					Rule: ruleUnreachable, Repo: loc.Key, Message: loc.Key + " could not be fetched",
+				})
+				continue
+			}
+			for _, v := range opts.policy.evaluate(loc.Key, info, fetcher.now()) {
+				v.File, v.Line, v.Column = file, loc.Line, loc.Column
+				violations = append(violations, v)
+			}
+		}
+	}
+	return checked, violations, nil
+}
+
+// locationURLs returns the link URLs of locations.
+func locationURLs(locations []stars.Location) []string {
+	urls := make([]string, 0, len(locations))
+	for _, loc := range locations {
+		urls = append(urls, loc.URL)
+	}
+	return urls
+}
+
+// filterLocations returns the locations of the links in repos.
+func filterLocations(locations []stars.Location, repos []string) []stars.Location {
+	keep := make(map[string]bool, len(repos))
+	for _, repoURL := range repos {
+		keep[repoURL] = true
+	}
+	var filtered []stars.Location
+	for _, loc := range locations {
+		if keep[loc.URL] {
+			filtered = append(filtered, loc)
+		}
+	}
+	return filtered
+}
+
+// writeViolations prints one line per violation followed by a summary.
+func writeViolations(w io.Writer, violations []violation) {
+	for _, v := range violations {
+		_, _ = fmt.Fprintln(w, v)
+	}
+	if len(violations) == 0 {
+		_, _ = fmt.Fprintln(w, "No policy violations found.")
+		return
+	}
+	_, _ = fmt.Fprintf(w, "%d policy violation(s) found.\n", len(violations))
+}
+
+// runPolicy checks files, or the links new since the base version, against the policy rules,
+// reports the violations on stdout and in the SARIF file, if requested, and returns the exit code.
+func runPolicy(ctx context.Context, files []string, opts *options, fetcher *starFetcher, stdout, stderr io.Writer) int {
+	checked, violations, err := checkPolicy(ctx, files, opts, fetcher)
+	if err != nil {
+		_, _ = fmt.Fprintln(stderr, "Error:", err)
+		return 1
+	}
+	if opts.baseRev != "" || opts.baseFile != "" {
+		// Pull request mode: list the new links so reviewers see what was checked.
+		for _, link := range checked {
+			_, _ = fmt.Fprintln(stdout, link)
+		}
+		_, _ = fmt.Fprintf(stdout, "%d new repository link(s) checked.\n", len(checked))
+	}
+	writeViolations(stdout, violations)
+
+	if opts.sarifPath != "" {
+		f, err := os.Create(filepath.Clean(opts.sarifPath))
+		if err != nil {
+			_, _ = fmt.Fprintln(stderr, "Error writing SARIF report:", err)
+			return 1
+		}
+		err = writeSARIF(f, violations, repoRoot(ctx))
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			_, _ = fmt.Fprintln(stderr, "Error writing SARIF report:", err)
+			return 1
+		}
+	}
+
+	if len(violations) > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,202 @@
+// Package main provides the core functionality for updating GitHub star counts in Markdown and AsciiDoc files.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPolicyEvaluate(t *testing.T) {
+	now := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+	rules := policyRules{minStars: 50, forbidArchived: true, maxInactiveDays: 365, licenses: []string{"MIT", "Apache-2.0"}}
+
+	tests := []struct {
+		name     string
+		info     repoInfo
+		expected []string
+	}{
+		{"Compliant", repoInfo{Stars: 50, PushedAt: now.AddDate(0, -1, 0), License: "mit"}, nil},
+		{"Too few stars", repoInfo{Stars: 49, PushedAt: now, License: "MIT"}, []string{ruleMinStars}},
+		{"Archived and inactive", repoInfo{Stars: 100, Archived: true, PushedAt: now.AddDate(-2, 0, 0), License: "MIT"}, []string{ruleArchived, ruleInactive}},
+		{"No license", repoInfo{Stars: 100, PushedAt: now, License: "NOASSERTION"}, []string{ruleLicense}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := rules.evaluate("o/r", tt.info, now)
+			if len(got) != len(tt.expected) {
+				t.Fatalf("expected rules %v, got %v", tt.expected, got)
+			}
+			for i, v := range got {
+				if v.Rule != tt.expected[i] || v.Repo != "o/r" {
+					t.Errorf("expected rule %s, got %+v", tt.expected[i], v)
+				}
+			}
+		})
+	}
+}
+
+func TestRunPolicy(t *testing.T) {
+	isolateAuthEnv(t)
+	t.Setenv("GITHUB_TOKEN", "test_token")
+	server := newRepoInfoAPI(t)
+
+	dir := t.TempDir()
+	doc := filepath.Join(dir, "list.md")
+	content := "# List\n- [Active](https://github.com/owner/active)\n- [Dormant](https://github.com/owner/dormant)\n"
+	writeFile(t, doc, content)
+	sarifPath := filepath.Join(dir, "policy.sarif")
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"-api-url", server.URL, "-policy", "-policy-min-stars", "50", "-policy-forbid-archived", "-sarif", sarifPath, doc}
+	if code := run(args, &stdout, &stderr); code != 1 {
+		t.Fatalf("expected exit code 1, got %d: %s", code, stderr.String())
+	}
+	expected := doc + ":3:13: owner/dormant has 40 stars, fewer than the required 50 (min-stars)\n" +
+		doc + ":3:13: owner/dormant is archived (archived)\n" +
+		"2 policy violation(s) found.\n"
+	if stdout.String() != expected {
+		t.Errorf("expected %q, got %q", expected, stdout.String())
+	}
+	if got := mustRead(t, doc); got != content {
+		t.Errorf("expected the document to be left untouched, got %q", got)
+	}
+
+	var log sarifLog
+	data, err := os.ReadFile(sarifPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &log); err != nil {
+		t.Fatalf("invalid SARIF: %v", err)
+	}
+	results := log.Runs[0].Results
+	if log.Version != sarifVersion || len(results) != 2 || results[0].RuleID != ruleMinStars ||
+		results[0].Locations[0].PhysicalLocation.Region.StartLine != 3 {
+		t.Errorf("unexpected SARIF log: %s", data)
+	}
+
+	stdout.Reset()
+	if code := run([]string{"-api-url", server.URL, "-policy", "-policy-min-stars", "10", doc}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stdout.String())
+	}
+	if !strings.Contains(stdout.String(), "No policy violations found.") {
+		t.Errorf("unexpected output: %q", stdout.String())
+	}
+}
+
+func TestRunPolicySARIFWithConfig(t *testing.T) {
+	isolateAuthEnv(t)
+	t.Setenv("GITHUB_TOKEN", "test_token")
+	server := newRepoInfoAPI(t)
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, ".stars-updater.yaml"), "inputs: [docs/list.md]\npolicy:\n  forbid_archived: true\nhost:\n  api_url: "+server.URL+"\n")
+	writeFile(t, filepath.Join(dir, "docs", "list.md"), "- [Dormant](https://github.com/owner/dormant)\n")
+	gitInit := func(t *testing.T) {
+		if _, err := exec.LookPath("git"); err != nil {
+			t.Skip("git is not available")
+		}
+		if out, err := exec.Command("git", "-C", dir, "init", "-q").CombinedOutput(); err != nil {
+			t.Fatalf("git init: %v\n%s", err, out)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		workspace string
+		setup     func(t *testing.T)
+	}{
+		{"GitHub Actions workspace", dir, func(*testing.T) {}},
+		{"git work tree", "", gitInit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setup(t)
+			t.Setenv("GITHUB_WORKSPACE", tt.workspace)
+			// Run from a subdirectory: the config file is found in the parent.
+			t.Chdir(filepath.Join(dir, "docs"))
+			sarifPath := filepath.Join(t.TempDir(), "policy.sarif")
+
+			var stdout, stderr bytes.Buffer
+			if code := run([]string{"-policy", "-sarif", sarifPath}, &stdout, &stderr); code != 1 {
+				t.Fatalf("expected exit code 1, got %d: %s", code, stderr.String())
+			}
+			var log sarifLog
+			if err := json.Unmarshal([]byte(mustRead(t, sarifPath)), &log); err != nil {
+				t.Fatalf("invalid SARIF: %v", err)
+			}
+			results := log.Runs[0].Results
+			if len(results) != 1 {
+				t.Fatalf("expected one result, got %+v", results)
+			}
+			if got := results[0].Locations[0].PhysicalLocation.ArtifactLocation; got != (sarifArtifactLocation{URI: "docs/list.md", URIBaseID: srcRoot}) {
+				t.Errorf("expected the location relative to the repository root, got %+v", got)
+			}
+		})
+	}
+}
+
+func TestSARIFArtifactOutsideRoot(t *testing.T) {
+	root, other := t.TempDir(), t.TempDir()
+	file := filepath.Join(other, "list.md")
+	writeFile(t, file, "")
+	got := sarifArtifact(root, file)
+	if got.URIBaseID != "" || !strings.HasPrefix(got.URI, "file:///") || !strings.HasSuffix(got.URI, "/list.md") {
+		t.Errorf("expected an absolute file URI, got %+v", got)
+	}
+}
+
+func TestRunPolicySkipsGeneratedBlocks(t *testing.T) {
+	isolateAuthEnv(t)
+	t.Setenv("GITHUB_TOKEN", "test_token")
+	server := newRepoInfoAPI(t)
+
+	doc := filepath.Join(t.TempDir(), "list.md")
+	writeFile(t, doc, "<!-- stars:top 1 -->\n1. [Dormant (⭐40)](https://github.com/owner/dormant)\n<!-- stars:end -->\n\n"+
+		"- [Dormant (⭐40)](https://github.com/owner/dormant)\n")
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-api-url", server.URL, "-policy", "-policy-forbid-archived", doc}, &stdout, &stderr); code != 1 {
+		t.Fatalf("expected exit code 1, got %d: %s", code, stderr.String())
+	}
+	expected := doc + ":5:19: owner/dormant is archived (archived)\n1 policy violation(s) found.\n"
+	if stdout.String() != expected {
+		t.Errorf("expected %q, got %q", expected, stdout.String())
+	}
+}
+
+func TestRunPolicyAnnotatedLinks(t *testing.T) {
+	isolateAuthEnv(t)
+	dir := t.TempDir()
+	fixture := filepath.Join(dir, "stars.yaml")
+	writeFile(t, fixture, "owner/small: 20\nowner/big: 900\n")
+
+	tests := []struct {
+		name string
+		link string
+		want string
+	}{
+		{"pinned", "- [Small](https://github.com/owner/small) <!-- stars: pin 9999 -->\n", ":1:11: owner/small has 20 stars"},
+		{"hidden", "- [Small](https://github.com/owner/small) <!-- stars: hide -->\n", ":1:11: owner/small has 20 stars"},
+		{"redirected", "- [Small](https://github.com/owner/small) <!-- stars: owner/big -->\n", ":1:11: owner/small has 20 stars"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := filepath.Join(dir, tt.name+".md")
+			writeFile(t, doc, tt.link)
+			var stdout, stderr bytes.Buffer
+			if code := run([]string{"check", "-offline", "-fixture", fixture, "-policy-min-stars", "50", doc}, &stdout, &stderr); code != 1 {
+				t.Fatalf("expected exit code 1, got %d: %s%s", code, stdout.String(), stderr.String())
+			}
+			if !strings.Contains(stdout.String(), doc+tt.want) || !strings.Contains(stdout.String(), "1 policy violation(s) found.") {
+				t.Errorf("expected a violation for the linked repository, got %q", stdout.String())
+			}
+		})
+	}
+}
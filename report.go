@@ -0,0 +1,228 @@
+// Package main provides the core functionality for updating GitHub star counts in Markdown and AsciiDoc files.
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/stn1slv/github-markdown-stars-updater/pkg/stars"
+)
+
+// Supported output formats of the report command.
+const (
+	reportText     = "text"
+	reportMarkdown = "markdown"
+	reportJSON     = "json"
+)
+
+// defaultReportTop is the number of repositories listed in the top section of a report.
+const defaultReportTop = 10
+
+// report holds the statistics about the repositories linked from a set of documents.
+type report struct {
+	Documents    []documentStats `json:"documents"`
+	Links        int             `json:"links"`
+	Repositories int             `json:"repositories"`
+	TotalStars   int             `json:"total_stars"`
+	MedianStars  int             `json:"median_stars"`
+	Archived     int             `json:"archived"`
+	Stale        int             `json:"stale"`
+	Top          []repoStats     `json:"top"`
+	Unreachable  []string        `json:"unreachable,omitempty"`
+
+	// withActivity is false when only star counts were available, e.g. offline.
+	withActivity bool
+	staleAfter   int
+}
+
+// documentStats are the statistics of a single document. Stars counts each repository once.
+type documentStats struct {
+	File         string `json:"file"`
+	Links        int    `json:"links"`
+	Repositories int    `json:"repositories"`
+	Stars        int    `json:"stars"`
+}
+
+// repoStats are the statistics of a single repository across all documents.
+type repoStats struct {
+	Repo     string `json:"repo"`
+	Stars    int    `json:"stars"`
+	Links    int    `json:"links"`
+	Archived bool   `json:"archived,omitempty"`
+	Stale    bool   `json:"stale,omitempty"`
+}
+
+// buildReport fetches the repositories linked from files and returns their statistics. Excluded
+// repositories are left out.
+func buildReport(ctx context.Context, files []string, opts *options, fetcher *starFetcher) (*report, error) {
+	links, err := findLinks(files, opts)
+	if err != nil {
+		return nil, err
+	}
+	r := &report{withActivity: !opts.offline, staleAfter: opts.staleAfter}
+	detail := detailActivity
+	if opts.offline {
+		detail = detailStars
+	}
+
+	linkCounts := make(map[string]int)
+	infos := make(map[string]repoInfo)
+	for _, file := range files {
+		var urls []string
+		repos := make(map[string]bool)
+		doc := documentStats{File: file}
+		for _, link := range links {
+			if link.File != file || isExcluded(opts.exclude, link.Key) {
+				continue
+			}
+			urls = append(urls, link.URL)
+			repos[link.Key] = true
+			linkCounts[link.Key]++
+			doc.Links++
+		}
+		for key, info := range fetcher.fetchInfo(ctx, urls, opts.exclude, detail) {
+			infos[key] = info
+			doc.Stars += info.Stars
+		}
+		doc.Repositories = len(repos)
+		r.Documents = append(r.Documents, doc)
+		r.Links += doc.Links
+	}
+
+	r.Repositories = len(linkCounts)
+	now := fetcher.now()
+	var counts []int
+	for key, links := range linkCounts {
+		info, ok := infos[key]
+		if !ok {
+			r.Unreachable = append(r.Unreachable, key)
+			continue
+		}
+		stale := isStale(info, now, opts.staleAfter)
+		r.Top = append(r.Top, repoStats{Repo: key, Stars: info.Stars, Links: links, Archived: info.Archived, Stale: stale})
+		counts = append(counts, info.Stars)
+		r.TotalStars += info.Stars
+		if info.Archived {
+			r.Archived++
+		}
+		if stale {
+			r.Stale++
+		}
+	}
+	slices.Sort(r.Unreachable)
+	slices.SortFunc(r.Top, func(a, b repoStats) int {
+		return cmp.Or(cmp.Compare(b.Stars, a.Stars), cmp.Compare(a.Repo, b.Repo))
+	})
+	if opts.reportTop > 0 && len(r.Top) > opts.reportTop {
+		r.Top = r.Top[:opts.reportTop]
+	}
+	r.MedianStars = median(counts)
+	return r, nil
+}
+
+// median returns the median of values, or 0 for none.
+func median(values []int) int {
+	if len(values) == 0 {
+		return 0
+	}
+	slices.Sort(values)
+	mid := len(values) / 2 //nolint:mnd
+	if len(values)%2 == 0 {
+		return (values[mid-1] + values[mid]) / 2 //nolint:mnd
+	}
+	return values[mid]
+}
+
+// writeText prints the report for a terminal.
+func (r *report) writeText(w io.Writer) {
+	_, _ = fmt.Fprintf(w, "%d document(s), %d link(s) to %d repositories.\n", len(r.Documents), r.Links, r.Repositories)
+	_, _ = fmt.Fprintf(w, "Total stars: %s (median %s)\n", stars.FormatStarCount(r.TotalStars), stars.FormatStarCount(r.MedianStars))
+	if r.withActivity {
+		_, _ = fmt.Fprintf(w, "Archived: %d\n", r.Archived)
+		if r.staleAfter > 0 {
+			_, _ = fmt.Fprintf(w, "Stale (no push in %d months): %d\n", r.staleAfter, r.Stale)
+		}
+	}
+
+	_, _ = fmt.Fprintln(w, "\nDocuments:")
+	for _, d := range r.Documents {
+		_, _ = fmt.Fprintf(w, "  %s: %d link(s), %d repositories, ⭐%s\n", d.File, d.Links, d.Repositories, stars.FormatStarCount(d.Stars))
+	}
+	if len(r.Top) > 0 {
+		_, _ = fmt.Fprintln(w, "\nTop repositories:")
+		for i, repo := range r.Top {
+			_, _ = fmt.Fprintf(w, "  %d. %s ⭐%s%s\n", i+1, repo.Repo, stars.FormatStarCount(repo.Stars), repo.flags())
+		}
+	}
+	if len(r.Unreachable) > 0 {
+		_, _ = fmt.Fprintln(w, "\nCould not be fetched:")
+		for _, key := range r.Unreachable {
+			_, _ = fmt.Fprintf(w, "  %s\n", key)
+		}
+	}
+}
+
+// writeMarkdown prints the report as Markdown tables, e.g. for a job summary.
+func (r *report) writeMarkdown(w io.Writer) {
+	_, _ = fmt.Fprintf(w, "**%d** link(s) to **%d** repositories with **%s** stars in total (median %s).\n\n",
+		r.Links, r.Repositories, stars.FormatStarCount(r.TotalStars), stars.FormatStarCount(r.MedianStars))
+
+	_, _ = fmt.Fprintln(w, "| Document | Links | Repositories | Stars |")
+	_, _ = fmt.Fprintln(w, "|---|---:|---:|---:|")
+	for _, d := range r.Documents {
+		_, _ = fmt.Fprintf(w, "| %s | %d | %d | %s |\n", d.File, d.Links, d.Repositories, stars.FormatStarCount(d.Stars))
+	}
+	if len(r.Top) > 0 {
+		_, _ = fmt.Fprintln(w, "\n| # | Repository | Stars |")
+		_, _ = fmt.Fprintln(w, "|---:|---|---:|")
+		for i, repo := range r.Top {
+			_, _ = fmt.Fprintf(w, "| %d | [%s](https://github.com/%s)%s | %s |\n",
+				i+1, repo.Repo, repo.Repo, repo.flags(), stars.FormatStarCount(repo.Stars))
+		}
+	}
+	if len(r.Unreachable) > 0 {
+		_, _ = fmt.Fprintln(w, "\nCould not be fetched:")
+		for _, key := range r.Unreachable {
+			_, _ = fmt.Fprintf(w, "- %s\n", key)
+		}
+	}
+}
+
+// flags returns the status notes shown after the repository name.
+func (s repoStats) flags() string {
+	switch {
+	case s.Archived:
+		return " (archived)"
+	case s.Stale:
+		return " (stale)"
+	}
+	return ""
+}
+
+// runReport prints the statistics about the repositories linked from files in the requested
+// output format.
+func runReport(ctx context.Context, files []string, opts *options, fetcher *starFetcher, stdout, stderr io.Writer) int {
+	r, err := buildReport(ctx, files, opts, fetcher)
+	if err != nil {
+		_, _ = fmt.Fprintln(stderr, "Error:", err)
+		return 1
+	}
+	switch opts.reportOutput {
+	case reportMarkdown:
+		r.writeMarkdown(stdout)
+	case reportJSON:
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(r); err != nil {
+			_, _ = fmt.Fprintln(stderr, "Error:", err)
+			return 1
+		}
+	default:
+		r.writeText(stdout)
+	}
+	return 0
+}
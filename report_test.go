@@ -0,0 +1,102 @@
+// Package main provides the core functionality for updating GitHub star counts in Markdown and AsciiDoc files.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunReport(t *testing.T) {
+	isolateAuthEnv(t)
+	t.Setenv("GITHUB_TOKEN", "test_token")
+	server := newRepoInfoAPI(t)
+
+	dir := t.TempDir()
+	readme := filepath.Join(dir, "README.md")
+	writeFile(t, readme, "- [Active](https://github.com/owner/active)\n- [Dormant](https://github.com/owner/dormant)\n"+
+		"- [Again](https://github.com/owner/active)\n- [Missing](https://github.com/owner/missing)\n")
+	other := filepath.Join(dir, "other.md")
+	writeFile(t, other, "- [Dormant](https://github.com/owner/dormant)\n- [Skip](https://github.com/skip/c)\n")
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"report", "-api-url", server.URL, "-stale-after", "12", "-exclude", "skip/*", "-output", "json", readme, other}
+	if code := run(args, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	var r report
+	if err := json.Unmarshal(stdout.Bytes(), &r); err != nil {
+		t.Fatalf("invalid JSON report: %v\n%s", err, stdout.String())
+	}
+	if r.Links != 5 || r.Repositories != 3 || r.TotalStars != 1274 || r.MedianStars != 637 || r.Archived != 1 || r.Stale != 1 {
+		t.Errorf("unexpected totals %+v", r)
+	}
+	if len(r.Documents) != 2 || r.Documents[0] != (documentStats{File: readme, Links: 4, Repositories: 3, Stars: 1274}) ||
+		r.Documents[1] != (documentStats{File: other, Links: 1, Repositories: 1, Stars: 40}) {
+		t.Errorf("unexpected documents %+v", r.Documents)
+	}
+	if len(r.Top) != 2 || r.Top[0] != (repoStats{Repo: "owner/active", Stars: 1234, Links: 2}) ||
+		r.Top[1] != (repoStats{Repo: "owner/dormant", Stars: 40, Links: 2, Archived: true, Stale: true}) {
+		t.Errorf("unexpected top repositories %+v", r.Top)
+	}
+	if len(r.Unreachable) != 1 || r.Unreachable[0] != "owner/missing" {
+		t.Errorf("unexpected unreachable repositories %v", r.Unreachable)
+	}
+
+	stdout.Reset()
+	args = []string{"report", "-api-url", server.URL, "-stale-after", "12", "-exclude", "skip/*", "-top", "1", readme, other}
+	if code := run(args, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	for _, line := range []string{
+		"2 document(s), 5 link(s) to 3 repositories.",
+		"Total stars: 1.2k (median 637)",
+		"Stale (no push in 12 months): 1",
+		"  1. owner/active ⭐1.2k\n\n",
+		"Could not be fetched:\n  owner/missing",
+	} {
+		if !strings.Contains(stdout.String(), line) {
+			t.Errorf("expected %q in the report, got %q", line, stdout.String())
+		}
+	}
+}
+
+func TestWriteMarkdownReport(t *testing.T) {
+	r := &report{
+		Documents:    []documentStats{{File: "README.md", Links: 3, Repositories: 2, Stars: 1540}},
+		Links:        3,
+		Repositories: 2,
+		TotalStars:   1540,
+		MedianStars:  770,
+		Top:          []repoStats{{Repo: "owner/a", Stars: 1500}, {Repo: "owner/b", Stars: 40, Archived: true}},
+	}
+	var out bytes.Buffer
+	r.writeMarkdown(&out)
+	expected := "**3** link(s) to **2** repositories with **1.5k** stars in total (median 770).\n\n" +
+		"| Document | Links | Repositories | Stars |\n|---|---:|---:|---:|\n| README.md | 3 | 2 | 1.5k |\n\n" +
+		"| # | Repository | Stars |\n|---:|---|---:|\n" +
+		"| 1 | [owner/a](https://github.com/owner/a) | 1.5k |\n" +
+		"| 2 | [owner/b](https://github.com/owner/b) (archived) | 40 |\n"
+	if out.String() != expected {
+		t.Errorf("expected %q, got %q", expected, out.String())
+	}
+}
+
+func TestMedian(t *testing.T) {
+	tests := []struct {
+		values   []int
+		expected int
+	}{
+		{nil, 0},
+		{[]int{5}, 5},
+		{[]int{9, 1, 5}, 5},
+		{[]int{10, 1, 3, 4}, 3},
+	}
+	for _, tt := range tests {
+		if got := median(tt.values); got != tt.expected {
+			t.Errorf("median(%v): expected %d, got %d", tt.values, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+// Package main provides the core functionality for updating GitHub star counts in Markdown and AsciiDoc files.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	toolName     = "markdown-github-stars-updater"
+	toolInfoURI  = "https://github.com/stn1slv/markdown-github-stars-updater"
+)
+
+// The SARIF types cover the subset of SARIF 2.1.0 that GitHub code scanning reads.
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+}
+
+// srcRoot is the SARIF base URI of the files of the checked repository, which code scanning
+// resolves to the repository root.
+const srcRoot = "%SRCROOT%"
+
+// writeSARIF writes violations as a SARIF log. Files are written relative to root, the root of
+// the repository, which code scanning resolves srcRoot to.
+func writeSARIF(w io.Writer, violations []violation, root string) error {
+	ids := make([]string, 0, len(policyRuleDescriptions))
+	for id := range policyRuleDescriptions {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	rules := make([]sarifRule, 0, len(ids))
+	for _, id := range ids {
+		rules = append(rules, sarifRule{ID: id, ShortDescription: sarifMessage{Text: policyRuleDescriptions[id]}})
+	}
+
+	results := make([]sarifResult, 0, len(violations))
+	for _, v := range violations {
+		results = append(results, sarifResult{
+			RuleID:  v.Rule,
+			Level:   "error",
+			Message: sarifMessage{Text: v.Message},
+			Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifact(root, v.File),
+				Region:           sarifRegion{StartLine: v.Line, StartColumn: v.Column},
+			}}},
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Version: sarifVersion,
+		Schema:  sarifSchema,
+		Runs: []sarifRun{{
+			Tool:    sarifTool{Driver: sarifDriver{Name: toolName, Version: version, InformationURI: toolInfoURI, Rules: rules}},
+			Results: results,
+		}},
+	})
+}
+
+// repoRoot returns the root of the repository the files are checked in: GITHUB_WORKSPACE in
+// GitHub Actions, otherwise the top level of the git work tree around the working directory, or
+// the working directory itself outside of git.
+func repoRoot(ctx context.Context) string {
+	if workspace := os.Getenv("GITHUB_WORKSPACE"); workspace != "" {
+		return workspace
+	}
+	if out, err := git(ctx, ".", "rev-parse", "--show-toplevel"); err == nil {
+		return strings.TrimSpace(string(out))
+	}
+	return "."
+}
+
+// sarifArtifact returns the location of file relative to root and srcRoot. Files outside root
+// are written as absolute file URIs, which code scanning cannot map to the repository.
+func sarifArtifact(root, file string) sarifArtifactLocation {
+	// git reports the root with symbolic links resolved, so file is resolved too.
+	if resolved, err := filepath.EvalSymlinks(root); err == nil {
+		root = resolved
+	}
+	if resolved, err := filepath.EvalSymlinks(file); err == nil {
+		file = resolved
+	}
+	if rel, err := repoPath(root, file); err == nil {
+		return sarifArtifactLocation{URI: rel, URIBaseID: srcRoot}
+	}
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		abs = file
+	}
+	path := filepath.ToSlash(abs)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path // a Windows drive letter
+	}
+	return sarifArtifactLocation{URI: (&url.URL{Scheme: "file", Path: path}).String()}
+}
@@ -0,0 +1,246 @@
+// Package main provides the core functionality for updating GitHub star counts in Markdown and AsciiDoc files.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/stn1slv/github-markdown-stars-updater/pkg/stars"
+)
+
+// blockSummary names the generated block that holds the section summary table.
+const blockSummary = "summary"
+
+// untitledSection is the title of the links before the first heading.
+const untitledSection = "(no heading)"
+
+var (
+	markdownHeadingRe = regexp.MustCompile(`^ {0,3}(#{1,6})[ \t]+(.*?)(?:[ \t]+#+)?[ \t]*$`)
+	asciidocHeadingRe = regexp.MustCompile(`^(={1,6})[ \t]+(.*?)[ \t]*$`)
+)
+
+// heading is a section heading of a document.
+type heading struct {
+	title string
+	level int // 1 for "#" in Markdown and "=" in AsciiDoc
+	line  int
+}
+
+// parseHeadings returns the ATX headings of a Markdown document, or the section titles of an
+// AsciiDoc document, in document order. Headings in code blocks are skipped.
+func parseHeadings(content string, format stars.Format) []heading {
+	headingRe := markdownHeadingRe
+	if format == stars.FormatASCIIDoc {
+		headingRe = asciidocHeadingRe
+	}
+	var headings []heading
+	for line := range textLines(content, format) {
+		if match := headingRe.FindStringSubmatch(line.text); match != nil && match[2] != "" {
+			headings = append(headings, heading{title: match[2], level: len(match[1]), line: line.number})
+		}
+	}
+	return headings
+}
+
+// sectionStats are the statistics of the repositories linked in one section, not counting its
+// subsections. Each repository is counted once per section.
+type sectionStats struct {
+	Title    string `json:"title"`
+	Level    int    `json:"level"`
+	Line     int    `json:"line"`
+	Entries  int    `json:"entries"`
+	Stars    int    `json:"stars"`
+	Median   int    `json:"median_stars"`
+	Top      string `json:"top,omitempty"`
+	TopStars int    `json:"top_stars,omitempty"`
+
+	keys []string
+}
+
+// documentSections are the section statistics of a document, with the totals of the whole
+// document.
+type documentSections struct {
+	File     string         `json:"file"`
+	Sections []sectionStats `json:"sections"`
+	Total    sectionStats   `json:"total"`
+}
+
+// buildSections assigns the links at locations to the sections of content and aggregates the star
+// counts in infos per section. Excluded links are left out; links to repositories missing from
+// infos count as entries without stars. Sections without links are omitted.
+func buildSections(content string, format stars.Format, locations []stars.Location, infos map[string]repoInfo, exclude []string) ([]sectionStats, sectionStats) {
+	headings := parseHeadings(content, format)
+	sections := make([]sectionStats, len(headings)+1)
+	sections[0] = sectionStats{Title: untitledSection, Line: 1}
+	for i, h := range headings {
+		sections[i+1] = sectionStats{Title: h.title, Level: h.level, Line: h.line}
+	}
+
+	seen := make(map[string]bool)
+	var all []string
+	for _, loc := range locations {
+		if isExcluded(exclude, loc.Key) {
+			continue
+		}
+		// The section of a link is the last heading on or before its line.
+		i := sort.Search(len(headings), func(i int) bool { return headings[i].line > loc.Line })
+		s := &sections[i]
+		if !slices.Contains(s.keys, loc.Key) {
+			s.keys = append(s.keys, loc.Key)
+		}
+		if !seen[loc.Key] {
+			seen[loc.Key] = true
+			all = append(all, loc.Key)
+		}
+	}
+
+	result := []sectionStats{}
+	for _, s := range sections {
+		if len(s.keys) > 0 {
+			s.aggregate(s.keys, infos)
+			result = append(result, s)
+		}
+	}
+	total := sectionStats{Title: "Total"}
+	total.aggregate(all, infos)
+	return result, total
+}
+
+// aggregate fills in the entries, stars, median and top repository of s from the repositories keys.
+func (s *sectionStats) aggregate(keys []string, infos map[string]repoInfo) {
+	s.Entries = len(keys)
+	var counts []int
+	for _, key := range keys {
+		info, ok := infos[key]
+		if !ok {
+			continue
+		}
+		counts = append(counts, info.Stars)
+		s.Stars += info.Stars
+		if s.Top == "" || info.Stars > s.TopStars || (info.Stars == s.TopStars && key < s.Top) {
+			s.Top, s.TopStars = key, info.Stars
+		}
+	}
+	s.Median = median(counts)
+}
+
+// topCell returns the top repository of s with its count, or "" when no count is known.
+func (s sectionStats) topCell() string {
+	if s.Top == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s ⭐%s", s.Top, stars.FormatStarCount(s.TopStars))
+}
+
+// summaryTable renders the section statistics as a table in the syntax of format, with "\n" line
+// breaks. Repositories are named without links, so that the table gets no labels.
+func summaryTable(sections []sectionStats, total sectionStats, format stars.Format) string {
+	var b strings.Builder
+	row := func(title string, s sectionStats) {
+		cells := []string{title, fmt.Sprint(s.Entries), "⭐" + stars.FormatStarCount(s.Stars), "⭐" + stars.FormatStarCount(s.Median), s.topCell()}
+		if format == stars.FormatASCIIDoc {
+			for i, cell := range cells {
+				cells[i] = strings.ReplaceAll(cell, "|", `\|`)
+			}
+			fmt.Fprintf(&b, "|%s\n", strings.Join(cells, " |"))
+			return
+		}
+		cells[0] = strings.ReplaceAll(cells[0], "|", `\|`)
+		fmt.Fprintf(&b, "| %s |\n", strings.Join(cells, " | "))
+	}
+
+	if format == stars.FormatASCIIDoc {
+		b.WriteString("[cols=\"3,>1,>1,>1,3\",options=\"header\"]\n|===\n|Section |Entries |Stars |Median |Top project\n")
+	} else {
+		b.WriteString("| Section | Entries | Stars | Median | Top project |\n|---|---:|---:|---:|---|\n")
+	}
+	for _, s := range sections {
+		row(s.Title, s)
+	}
+	if format == stars.FormatASCIIDoc {
+		row("*Total*", total)
+		b.WriteString("|===\n")
+	} else {
+		row("**Total**", total)
+	}
+	return b.String()
+}
+
+// runSections prints the section statistics of every document in files in the requested output
+// format.
+func runSections(ctx context.Context, files []string, opts *options, fetcher *starFetcher, stdout, stderr io.Writer) int {
+	docs := make([]documentSections, 0, len(files))
+	for _, file := range files {
+		content, _, err := readDocument(file)
+		if err != nil {
+			_, _ = fmt.Fprintln(stderr, "Error:", err)
+			return 1
+		}
+		format, err := formatOf(file, opts)
+		if err != nil {
+			_, _ = fmt.Fprintln(stderr, "Error:", err)
+			return 1
+		}
+		updater, err := stars.NewUpdater(format, nil)
+		if err != nil {
+			_, _ = fmt.Fprintln(stderr, "Error:", err)
+			return 1
+		}
+		repos, err := updater.FindRepos(content)
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "Error: finding repositories in %s: %v\n", file, err)
+			return 1
+		}
+		locations, err := documentLocations(content, format, repos)
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "Error: %s: %v\n", file, err)
+			return 1
+		}
+		infos := fetcher.fetchInfo(ctx, repos, opts.exclude, detailStars)
+		sections, total := buildSections(content, format, locations, infos, opts.exclude)
+		docs = append(docs, documentSections{File: file, Sections: sections, Total: total})
+	}
+
+	switch opts.reportOutput {
+	case reportJSON:
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(docs); err != nil {
+			_, _ = fmt.Fprintln(stderr, "Error:", err)
+			return 1
+		}
+	case reportMarkdown:
+		for i, doc := range docs {
+			if i > 0 {
+				_, _ = fmt.Fprintln(stdout)
+			}
+			_, _ = fmt.Fprintf(stdout, "### %s\n\n%s", doc.File, summaryTable(doc.Sections, doc.Total, stars.FormatMarkdown))
+		}
+	default:
+		for _, doc := range docs {
+			_, _ = fmt.Fprintf(stdout, "%s\n", doc.File)
+			for _, s := range doc.Sections {
+				indent := strings.Repeat("  ", max(s.Level, 1))
+				_, _ = fmt.Fprintf(stdout, "%s%s: %d entries, ⭐%s (median ⭐%s)%s\n", indent, s.Title, s.Entries,
+					stars.FormatStarCount(s.Stars), stars.FormatStarCount(s.Median), prefixed(", top ", s.topCell()))
+			}
+			_, _ = fmt.Fprintf(stdout, "  Total: %d entries, ⭐%s (median ⭐%s)%s\n", doc.Total.Entries,
+				stars.FormatStarCount(doc.Total.Stars), stars.FormatStarCount(doc.Total.Median), prefixed(", top ", doc.Total.topCell()))
+		}
+	}
+	return 0
+}
+
+// prefixed returns prefix+s, or "" when s is empty.
+func prefixed(prefix, s string) string {
+	if s == "" {
+		return ""
+	}
+	return prefix + s
+}
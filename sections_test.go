@@ -0,0 +1,183 @@
+// Package main provides the core functionality for updating GitHub star counts in Markdown and AsciiDoc files.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stn1slv/github-markdown-stars-updater/pkg/stars"
+)
+
+func TestParseHeadings(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   stars.Format
+		content  string
+		expected []heading
+	}{
+		{
+			name:    "Markdown",
+			format:  stars.FormatMarkdown,
+			content: "# Title\r\n\r\n## Tools ##\r\n#hashtag\r\n```sh\r\n# comment\r\n```\r\n~~~~\r\n## Not a heading\r\n~~~~\r\n   ### Deep\r\n",
+			expected: []heading{
+				{title: "Title", level: 1, line: 1},
+				{title: "Tools", level: 2, line: 3},
+				{title: "Deep", level: 3, line: 11},
+			},
+		},
+		{
+			name:    "AsciiDoc",
+			format:  stars.FormatASCIIDoc,
+			content: "= Title\n\n== Tools\n\n----\n== Not a heading\n----\n////\n== Commented out\n////\n=== CLI\n",
+			expected: []heading{
+				{title: "Title", level: 1, line: 1},
+				{title: "Tools", level: 2, line: 3},
+				{title: "CLI", level: 3, line: 11},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseHeadings(tt.content, tt.format); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestBuildSections(t *testing.T) {
+	content := "[Intro](https://github.com/o/main)\n# Tools\n- [A](https://github.com/o/a)\n- [B](https://github.com/o/b)\n" +
+		"- [A again](https://github.com/o/a)\n## Empty\n## More\n- [C](https://github.com/o/c)\n- [Gone](https://github.com/o/gone)\n" +
+		"- [Skip](https://github.com/skip/x)\n"
+	repos, err := (&stars.MarkdownUpdater{}).FindRepos(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	infos := map[string]repoInfo{"o/main": {Stars: 9000}, "o/a": {Stars: 300}, "o/b": {Stars: 100}, "o/c": {Stars: 100}, "skip/x": {Stars: 1}}
+
+	sections, total := buildSections(content, stars.FormatMarkdown, stars.Locate(content, repos), infos, []string{"skip/*"})
+	expected := []sectionStats{
+		{Title: untitledSection, Line: 1, Entries: 1, Stars: 9000, Median: 9000, Top: "o/main", TopStars: 9000},
+		{Title: "Tools", Level: 1, Line: 2, Entries: 2, Stars: 400, Median: 200, Top: "o/a", TopStars: 300},
+		{Title: "More", Level: 2, Line: 7, Entries: 2, Stars: 100, Median: 100, Top: "o/c", TopStars: 100},
+	}
+	for i := range sections {
+		sections[i].keys = nil
+	}
+	if !reflect.DeepEqual(sections, expected) {
+		t.Errorf("expected %+v, got %+v", expected, sections)
+	}
+	if total.Entries != 5 || total.Stars != 9500 || total.Median != 200 || total.Top != "o/main" {
+		t.Errorf("unexpected total %+v", total)
+	}
+}
+
+func TestFindBlocksErrors(t *testing.T) {
+	tests := []struct {
+		format  stars.Format
+		content string
+		message string
+	}{
+		{stars.FormatMarkdown, "# A\n<!-- stars:summary -->\n", "line 2: stars:summary block without a closing <!-- stars:end -->"},
+		{stars.FormatMarkdown, "<!-- stars:summary -->\n<!-- stars:summary -->\n<!-- stars:end -->\n", "line 2: stars:summary block inside"},
+		{stars.FormatASCIIDoc, "= A\n\n// stars:summary\n", "line 3: stars:summary block without a closing // stars:end"},
+	}
+	for _, tt := range tests {
+		if _, err := findBlocks(tt.content, tt.format, blockSummary); err == nil || !strings.Contains(err.Error(), tt.message) {
+			t.Errorf("expected an error containing %q, got %v", tt.message, err)
+		}
+	}
+
+	// Markers in code blocks, stray end markers and other directives are not blocks.
+	content := "<!-- stars:stale-after 6 -->\n<!-- stars:end -->\n```\n<!-- stars:summary -->\n```\n"
+	if blocks, err := findBlocks(content, stars.FormatMarkdown, blockSummary); err != nil || len(blocks) != 0 {
+		t.Errorf("expected no blocks, got %v, %v", blocks, err)
+	}
+}
+
+func TestRunRefreshesSummary(t *testing.T) {
+	isolateAuthEnv(t)
+	dir := t.TempDir()
+	fixture := filepath.Join(dir, "stars.json")
+	writeFile(t, fixture, `{"o/a": 1500, "o/b": 300}`)
+	doc := filepath.Join(dir, "list.md")
+	writeFile(t, doc, "# List\r\n\r\n<!-- stars:summary -->\r\nstale table\r\n<!-- stars:end -->\r\n\r\n## Tools\r\n\r\n"+
+		"- [A](https://github.com/o/a)\r\n- [B](https://github.com/o/b)\r\n")
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-offline", "-fixture", fixture, doc}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	expected := "# List\r\n\r\n<!-- stars:summary -->\r\n" +
+		"| Section | Entries | Stars | Median | Top project |\r\n" +
+		"|---|---:|---:|---:|---|\r\n" +
+		"| Tools | 2 | ⭐1.8k | ⭐900 | o/a ⭐1.5k |\r\n" +
+		"| **Total** | 2 | ⭐1.8k | ⭐900 | o/a ⭐1.5k |\r\n" +
+		"<!-- stars:end -->\r\n\r\n## Tools\r\n\r\n- [A (⭐1.5k)](https://github.com/o/a)\r\n- [B (⭐300)](https://github.com/o/b)\r\n"
+	if got := mustRead(t, doc); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+
+	// The refreshed table is stable.
+	stdout.Reset()
+	if code := run([]string{"-offline", "-fixture", fixture, doc}, &stdout, &stderr); code != 0 || !strings.Contains(stdout.String(), "already up to date") {
+		t.Errorf("expected the document to be up to date, got %d: %s", code, stdout.String())
+	}
+
+	// An unclosed block fails the document.
+	writeFile(t, doc, "<!-- stars:summary -->\n- [A](https://github.com/o/a)\n")
+	stderr.Reset()
+	if code := run([]string{"-offline", "-fixture", fixture, doc}, &stdout, &stderr); code != 1 || !strings.Contains(stderr.String(), "without a closing") {
+		t.Errorf("expected exit code 1 for an unclosed block, got %d: %s", code, stderr.String())
+	}
+}
+
+func TestRunSections(t *testing.T) {
+	isolateAuthEnv(t)
+	dir := t.TempDir()
+	fixture := filepath.Join(dir, "stars.json")
+	writeFile(t, fixture, `{"o/a": 1500, "o/b": 300}`)
+	doc := filepath.Join(dir, "list.adoc")
+	writeFile(t, doc, "= List\n\n== Tools\n\n* https://github.com/o/a[A]\n\n=== CLI\n\n* https://github.com/o/b[B]\n* https://github.com/o/gone[Gone]\n")
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"sections", "-offline", "-fixture", fixture, "-output", "json", doc}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	var docs []documentSections
+	if err := json.Unmarshal(stdout.Bytes(), &docs); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, stdout.String())
+	}
+	expected := []documentSections{{
+		File: doc,
+		Sections: []sectionStats{
+			{Title: "Tools", Level: 2, Line: 3, Entries: 1, Stars: 1500, Median: 1500, Top: "o/a", TopStars: 1500},
+			{Title: "CLI", Level: 3, Line: 7, Entries: 2, Stars: 300, Median: 300, Top: "o/b", TopStars: 300},
+		},
+		Total: sectionStats{Title: "Total", Entries: 3, Stars: 1800, Median: 900, Top: "o/a", TopStars: 1500},
+	}}
+	if !reflect.DeepEqual(docs, expected) {
+		t.Errorf("expected %+v, got %+v", expected, docs)
+	}
+	if got := mustRead(t, doc); strings.Contains(got, "⭐") {
+		t.Errorf("sections must not modify the document, got %q", got)
+	}
+
+	stdout.Reset()
+	if code := run([]string{"sections", "-offline", "-fixture", fixture, doc}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	for _, line := range []string{
+		"    Tools: 1 entries, ⭐1.5k (median ⭐1.5k), top o/a ⭐1.5k\n",
+		"      CLI: 2 entries, ⭐300 (median ⭐300), top o/b ⭐300\n",
+		"  Total: 3 entries, ⭐1.8k (median ⭐900), top o/a ⭐1.5k\n",
+	} {
+		if !strings.Contains(stdout.String(), line) {
+			t.Errorf("expected %q in the output, got:\n%s", line, stdout.String())
+		}
+	}
+}
@@ -0,0 +1,119 @@
+// Package main provides the core functionality for updating GitHub star counts in Markdown and AsciiDoc files.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/stn1slv/github-markdown-stars-updater/pkg/stars"
+)
+
+// snapshot is the repository data of a set of documents, written by the fetch command and read
+// by the apply command, keyed by canonical "owner/repo". Snapshots fetched offline from a fixture
+// hold the star counts only.
+type snapshot struct {
+	FetchedAt time.Time           `json:"fetched_at"`
+	StarsOnly bool                `json:"stars_only,omitempty"`
+	Repos     map[string]repoInfo `json:"repos"`
+}
+
+// loadSnapshot reads the snapshot file at path.
+func loadSnapshot(path string) (*snapshot, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	var s snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot %s: %w", path, err)
+	}
+	if s.Repos == nil {
+		s.Repos = make(map[string]repoInfo)
+	}
+	return &s, nil
+}
+
+// save writes the snapshot to path.
+func (s *snapshot) save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o750); err != nil {
+			return err
+		}
+	}
+	// Snapshots are handed to other build steps, like the documents they describe.
+	return os.WriteFile(path, append(data, '\n'), 0o644) //nolint:gosec
+}
+
+// useSnapshot makes f serve the repository data of s without calling any API.
+func (f *starFetcher) useSnapshot(s *snapshot) {
+	counts := make(map[string]int, len(s.Repos))
+	for key, info := range s.Repos {
+		detail := detailActivity
+		switch {
+		case s.StarsOnly:
+			detail = detailStars
+		case info.ReleaseChecked:
+			detail = detailRelease
+		}
+		f.remember(key, info, detail)
+		counts[key] = info.Stars
+	}
+	f.source = stars.NewMapFetcher(counts)
+	f.client = nil
+}
+
+// runFetch fetches the repository data linked from files into the cache and, with -snapshot, the
+// snapshot file. The documents are not modified. The release fields are only fetched when the
+// label template needs them. Offline, the star counts of the fixture are all there is to fetch.
+func runFetch(ctx context.Context, files []string, opts *options, fetcher *starFetcher, stdout, stderr io.Writer) int {
+	detail := max(detailActivity, detailFor(opts.label, opts.staleAfter))
+	if opts.offline {
+		detail = detailStars
+	}
+
+	s := &snapshot{FetchedAt: fetcher.now().UTC(), StarsOnly: detail == detailStars, Repos: make(map[string]repoInfo)}
+	exitCode := 0
+	for _, file := range files {
+		updater, err := newUpdater(file, opts)
+		if err != nil {
+			_, _ = fmt.Fprintln(stderr, "Error:", err)
+			exitCode = 1
+			continue
+		}
+		content, _, err := readDocument(file)
+		if err != nil {
+			_, _ = fmt.Fprintln(stderr, "Error: reading the file:", err)
+			exitCode = 1
+			continue
+		}
+		repos, err := updater.FindRepos(content)
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "Error: finding repositories in %s: %v\n", file, err)
+			exitCode = 1
+			continue
+		}
+		for key, info := range fetcher.fetchInfo(ctx, repos, opts.exclude, detail) {
+			s.Repos[key] = info
+		}
+	}
+
+	if opts.snapshotPath == "" {
+		_, _ = fmt.Fprintf(stdout, "Fetched %d repositories into the cache %s.\n", len(s.Repos), opts.cachePath)
+		return exitCode
+	}
+	if err := s.save(opts.snapshotPath); err != nil {
+		_, _ = fmt.Fprintln(stderr, "Error writing snapshot:", err)
+		return 1
+	}
+	_, _ = fmt.Fprintf(stdout, "Snapshot %s written with %d repositories.\n", opts.snapshotPath, len(s.Repos))
+	return exitCode
+}
@@ -0,0 +1,133 @@
+// Package main provides the core functionality for updating GitHub star counts in Markdown and AsciiDoc files.
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunFetchAndApplySnapshot(t *testing.T) {
+	isolateAuthEnv(t)
+	t.Setenv("GITHUB_TOKEN", "test_token")
+	server := newRepoInfoAPI(t)
+
+	dir := t.TempDir()
+	snapshotPath := filepath.Join(dir, "out", "stars.json")
+	original := "- [Active](https://github.com/owner/active)\n- [Dormant (⭐1)](https://github.com/owner/dormant)\n"
+	doc := filepath.Join(dir, "list.md")
+	writeFile(t, doc, original)
+	label := "⭐{stars} · {release}"
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"fetch", "-api-url", server.URL, "-snapshot", snapshotPath, "-label", label, doc}
+	if code := run(args, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	if got := mustRead(t, doc); got != original {
+		t.Errorf("fetch must not modify the document, got %q", got)
+	}
+	if !strings.Contains(stdout.String(), "with 2 repositories") {
+		t.Errorf("expected a snapshot summary, got %q", stdout.String())
+	}
+	s, err := loadSnapshot(snapshotPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s.Repos["owner/active"].Stars != 1234 || s.Repos["owner/active"].ReleaseTag != "v1.2.3" || !s.Repos["owner/dormant"].Archived {
+		t.Errorf("unexpected snapshot %+v", s.Repos)
+	}
+
+	// apply works without credentials or network access.
+	server.Close()
+	isolateAuthEnv(t)
+	stderr.Reset()
+	if code := run([]string{"apply", "-snapshot", snapshotPath, "-label", label, doc}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	expected := "- [Active (⭐1.2k · v1.2.3)](https://github.com/owner/active)\n- [Dormant (⭐40)](https://github.com/owner/dormant)\n"
+	if got := mustRead(t, doc); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+	if stderr.Len() != 0 {
+		t.Errorf("expected no warnings, got %q", stderr.String())
+	}
+}
+
+func TestSnapshotFlagValidation(t *testing.T) {
+	isolateAuthEnv(t)
+	doc := filepath.Join(t.TempDir(), "list.md")
+	writeFile(t, doc, "- [A](https://github.com/owner/a)\n")
+
+	tests := []struct {
+		args    []string
+		message string
+	}{
+		{[]string{"fetch", doc}, "fetch requires -snapshot or a cache"},
+		{[]string{"apply", doc}, "apply requires -snapshot"},
+		{[]string{"apply", "-snapshot", "stars.json", "-metrics", doc}, "package metrics"},
+	}
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		if code := run(tt.args, &stdout, &stderr); code != 1 || !strings.Contains(stderr.String(), tt.message) {
+			t.Errorf("%v: expected exit code 1 with %q, got %d: %s", tt.args, tt.message, code, stderr.String())
+		}
+	}
+}
+
+func TestRunFetchIntoCache(t *testing.T) {
+	isolateAuthEnv(t)
+	t.Setenv("GITHUB_TOKEN", "test_token")
+	server := newFakeAPI(t, map[string]int{"owner/a": 1500})
+
+	dir := t.TempDir()
+	cachePath := filepath.Join(dir, "cache.json")
+	doc := filepath.Join(dir, "list.md")
+	writeFile(t, doc, "- [A](https://github.com/owner/a)\n")
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"fetch", "-api-url", server.URL, "-cache", cachePath, doc}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	// The update runs from the cache the fetch populated.
+	server.Close()
+	if code := run([]string{"update", "-api-url", server.URL, "-cache", cachePath, doc}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	if got := mustRead(t, doc); got != "- [A (⭐1.5k)](https://github.com/owner/a)\n" || server.requests.Load() != 1 {
+		t.Errorf("expected the cached count, got %q with %d requests", got, server.requests.Load())
+	}
+}
+
+func TestRunFetchOfflineFromFixture(t *testing.T) {
+	isolateAuthEnv(t)
+	dir := t.TempDir()
+	fixture := filepath.Join(dir, "stars.json")
+	writeFile(t, fixture, `{"owner/a": 1500}`)
+	snapshotPath := filepath.Join(dir, "snapshot.json")
+	doc := filepath.Join(dir, "list.md")
+	writeFile(t, doc, "- [A](https://github.com/owner/a)\n")
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"fetch", "-offline", "-fixture", fixture, "-snapshot", snapshotPath, doc}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	if stderr.Len() != 0 {
+		t.Errorf("expected no warnings, got %q", stderr.String())
+	}
+	s, err := loadSnapshot(snapshotPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !s.StarsOnly || s.Repos["owner/a"].Stars != 1500 {
+		t.Errorf("expected a snapshot of the fixture counts, got %+v", s)
+	}
+
+	if code := run([]string{"apply", "-snapshot", snapshotPath, doc}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	if got := mustRead(t, doc); got != "- [A (⭐1.5k)](https://github.com/owner/a)\n" {
+		t.Errorf("unexpected document %q", got)
+	}
+}
@@ -0,0 +1,101 @@
+// Package main provides the core functionality for updating GitHub star counts in Markdown and AsciiDoc files.
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"html"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/stn1slv/github-markdown-stars-updater/pkg/stars"
+)
+
+// defaultSparklineDays is the period the sparklines show when none is configured.
+const defaultSparklineDays = 90
+
+// Size of the sparklines in pixels.
+const (
+	sparklineWidth   = 100
+	sparklineHeight  = 20
+	sparklinePadding = 2
+)
+
+// sparklineCharts renders the star history of the repositories in infos, or of the -sparkline-top
+// most starred ones, as sparklines into images. Repositories with fewer than two recorded counts
+// in the period have no sparkline. It returns the ChartFunc showing the sparklines after the
+// labels with -sparkline-links, and nil otherwise.
+func sparklineCharts(opts *options, history *starHistory, infos map[string]repoInfo, now time.Time, images *documentImages) stars.ChartFunc {
+	if opts.sparklineDir == "" || history == nil {
+		return nil
+	}
+	from := now.AddDate(0, 0, -opts.sparklineDays)
+
+	keys := make([]string, 0, len(infos))
+	for key := range infos {
+		if len(history.since(key, from)) >= 2 { //nolint:mnd
+			keys = append(keys, key)
+		}
+	}
+	slices.SortFunc(keys, func(a, b string) int {
+		return cmp.Or(cmp.Compare(infos[b].Stars, infos[a].Stars), cmp.Compare(a, b))
+	})
+	if opts.sparklineTop > 0 && len(keys) > opts.sparklineTop {
+		keys = keys[:opts.sparklineTop]
+	}
+
+	refs := make(map[string]string, len(keys))
+	for _, key := range keys {
+		svg := renderSparkline(key, history.since(key, from), from, now, opts.sparklineDays)
+		refs[key] = images.add(repoImagePath(opts.sparklineDir, key), svg)
+	}
+	if !opts.sparklineLinks {
+		return nil
+	}
+	return func(repoURL string) (string, bool) {
+		key, _ := stars.NormalizeRepoURL(repoURL)
+		ref, ok := refs[key]
+		return ref, ok
+	}
+}
+
+// renderSparkline renders the star counts in points, recorded between from and to, as a line on a
+// time axis spanning that period. The line is scaled to the range of the counts; a constant count
+// is drawn halfway up.
+func renderSparkline(key string, points []historyPoint, from, to time.Time, days int) []byte {
+	start, end := from.UTC().Truncate(24*time.Hour), to.UTC().Truncate(24*time.Hour) //nolint:mnd
+	span := max(end.Sub(start).Hours(), 1)
+	low, high := points[0].Stars, points[0].Stars
+	for _, p := range points {
+		low, high = min(low, p.Stars), max(high, p.Stars)
+	}
+
+	const innerWidth, innerHeight = sparklineWidth - 2*sparklinePadding, sparklineHeight - 2*sparklinePadding
+	coords := make([]string, 0, len(points))
+	var x, y float64
+	for _, p := range points {
+		date, err := time.Parse(historyDateLayout, p.Date)
+		if err != nil {
+			continue
+		}
+		x = sparklinePadding + innerWidth*date.Sub(start).Hours()/span
+		y = sparklineHeight / 2
+		if high > low {
+			y = sparklinePadding + innerHeight*float64(high-p.Stars)/float64(high-low)
+		}
+		coords = append(coords, fmt.Sprintf("%.1f,%.1f", x, y))
+	}
+
+	title := html.EscapeString(fmt.Sprintf("%s: ⭐%s → ⭐%s in the last %d days", key,
+		stars.FormatStarCount(points[0].Stars), stars.FormatStarCount(points[len(points)-1].Stars), days))
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" role="img" aria-label="%s">`+"\n",
+		sparklineWidth, sparklineHeight, sparklineWidth, sparklineHeight, title)
+	fmt.Fprintf(&b, "<title>%s</title>\n", title)
+	fmt.Fprintf(&b, `<polyline fill="none" stroke="#007ec6" stroke-width="1.5" stroke-linejoin="round" stroke-linecap="round" points="%s"/>`+"\n",
+		strings.Join(coords, " "))
+	fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="2" fill="#007ec6"/>`+"\n", x, y)
+	b.WriteString("</svg>\n")
+	return []byte(b.String())
+}
@@ -0,0 +1,154 @@
+// Package main provides the core functionality for updating GitHub star counts in Markdown and AsciiDoc files.
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderSparkline(t *testing.T) {
+	from := time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 1, 11, 20, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		points   []historyPoint
+		expected []string
+	}{
+		{
+			name:   "Rising",
+			points: []historyPoint{{"2026-01-01", 100}, {"2026-01-06", 150}, {"2026-01-11", 300}},
+			expected: []string{
+				`points="2.0,18.0 50.0,14.0 98.0,2.0"`,
+				`<circle cx="98.0" cy="2.0"`,
+				"<title>o/a: ⭐100 → ⭐300 in the last 10 days</title>",
+			},
+		},
+		{
+			name:     "Constant",
+			points:   []historyPoint{{"2026-01-06", 7}, {"2026-01-11", 7}},
+			expected: []string{`points="50.0,10.0 98.0,10.0"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svg := renderSparkline("o/a", tt.points, from, to, 10)
+			if err := xml.Unmarshal(svg, new(struct{})); err != nil {
+				t.Fatalf("invalid SVG: %v\n%s", err, svg)
+			}
+			for _, want := range tt.expected {
+				if !bytes.Contains(svg, []byte(want)) {
+					t.Errorf("expected %q in the sparkline:\n%s", want, svg)
+				}
+			}
+		})
+	}
+}
+
+func TestSparklineCharts(t *testing.T) {
+	now := time.Date(2026, 3, 31, 12, 0, 0, 0, time.UTC)
+	h := &starHistory{repos: map[string][]historyPoint{
+		"o/a":   {{"2026-03-01", 10}, {"2026-03-31", 20}},
+		"o/b":   {{"2026-03-30", 50}, {"2026-03-31", 60}},
+		"o/c":   {{"2026-03-31", 90}},                      // a single point
+		"o/old": {{"2025-01-01", 1}, {"2026-03-31", 1000}}, // a single point in the period
+	}}
+	infos := map[string]repoInfo{"o/a": {Stars: 20}, "o/b": {Stars: 60}, "o/c": {Stars: 90}, "o/old": {Stars: 1000}}
+
+	tests := []struct {
+		name  string
+		top   int
+		links bool
+		files []string
+		refs  map[string]string
+	}{
+		{"All", 0, true, []string{"charts/o/a.svg", "charts/o/b.svg"}, map[string]string{"o/a": "../charts/o/a.svg", "o/b": "../charts/o/b.svg"}},
+		{"Top", 1, true, []string{"charts/o/b.svg"}, map[string]string{"o/b": "../charts/o/b.svg"}},
+		{"Without links", 0, false, []string{"charts/o/a.svg", "charts/o/b.svg"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := defaultOptions()
+			opts.sparklineDir, opts.sparklineDays, opts.sparklineTop, opts.sparklineLinks = "charts", 30, tt.top, tt.links
+			images := newDocumentImages(filepath.Join("docs", "list.md"))
+			chart := sparklineCharts(&opts, h, infos, now, images)
+
+			var files []string
+			for path := range maps.Keys(images.files) {
+				files = append(files, filepath.ToSlash(path))
+			}
+			slices.Sort(files)
+			if !slices.Equal(files, tt.files) {
+				t.Errorf("expected files %v, got %v", tt.files, files)
+			}
+			if !tt.links {
+				if chart != nil {
+					t.Error("expected no chart references without -sparkline-links")
+				}
+				return
+			}
+			for _, key := range []string{"o/a", "o/b", "o/c", "o/old"} {
+				ref, ok := chart("https://github.com/" + key)
+				if expected, want := tt.refs[key]; ok != want || ref != expected {
+					t.Errorf("%s: expected %q, %v, got %q, %v", key, expected, want, ref, ok)
+				}
+			}
+		})
+	}
+
+	if sparklineCharts(&options{}, h, infos, now, newDocumentImages("list.md")) != nil {
+		t.Error("expected no charts without -sparklines")
+	}
+}
+
+func TestRunWithSparklines(t *testing.T) {
+	isolateAuthEnv(t)
+	dir := t.TempDir()
+	t.Chdir(dir)
+	writeFile(t, "stars.json", `{"o/a": 1500, "o/b": 300}`)
+	past := time.Now().UTC().AddDate(0, 0, -10).Format(historyDateLayout)
+	writeFile(t, "history.json", `{"repos": {"o/a": [{"date": "`+past+`", "stars": 1200}]}}`)
+	doc := "list.adoc"
+	writeFile(t, doc, "* https://github.com/o/a[A]\n* https://github.com/o/b[B]\n")
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"-offline", "-fixture", "stars.json", "-history", "history.json", "-sparklines", "charts", "-sparkline-links", doc}
+	if code := run(args, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	expected := "* https://github.com/o/a[A (⭐1.5k)] image:charts/o/a.svg[📈]\n* https://github.com/o/b[B (⭐300)]\n"
+	if got := mustRead(t, doc); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+	if svg := mustRead(t, filepath.Join("charts", "o", "a.svg")); !strings.Contains(svg, "⭐1.2k → ⭐1.5k in the last 90 days") {
+		t.Errorf("unexpected sparkline:\n%s", svg)
+	}
+
+	// Without -sparkline-links the references are removed, while the sparklines are still rendered.
+	if err := os.RemoveAll("charts"); err != nil {
+		t.Fatal(err)
+	}
+	stdout.Reset()
+	args = []string{"-offline", "-fixture", "stars.json", "-history", "history.json", "-sparklines", "charts", doc}
+	if code := run(args, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	if _, err := os.Stat(filepath.Join("charts", "o", "a.svg")); err != nil {
+		t.Errorf("expected the sparkline to be rendered: %v", err)
+	}
+	expected = "* https://github.com/o/a[A (⭐1.5k)]\n* https://github.com/o/b[B (⭐300)]\n"
+	if got := mustRead(t, doc); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+
+	stderr.Reset()
+	if code := run([]string{"-offline", "-fixture", "stars.json", "-sparklines", "charts", doc}, &stdout, &stderr); code != 1 || !strings.Contains(stderr.String(), "-sparklines requires a history file") {
+		t.Errorf("expected exit code 1 without a history, got %d: %s", code, stderr.String())
+	}
+}
@@ -0,0 +1,211 @@
+// Package main provides the core functionality for updating GitHub star counts in Markdown and AsciiDoc files.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	headerRateRemaining = "X-RateLimit-Remaining"
+	headerRateReset     = "X-RateLimit-Reset"
+)
+
+// errNoUsableToken is returned when every token in the pool is exhausted or was rejected.
+var errNoUsableToken = errors.New("all GitHub tokens are exhausted or invalid")
+
+// poolMember tracks the state of a single credential in a tokenPool.
+type poolMember struct {
+	cred      *credential
+	remaining int // -1 until the first response reports the quota
+	reset     time.Time
+	requests  int
+	removed   string // reason the credential was taken out of rotation
+}
+
+// tokenPool is an http.RoundTripper that spreads GitHub API requests over several credentials.
+// Each request uses the credential with the most remaining quota according to the rate-limit
+// headers of previous responses. Credentials that are rejected with 401 are removed for the rest
+// of the run; exhausted ones are skipped until their quota resets.
+type tokenPool struct {
+	mu      sync.Mutex
+	members []*poolMember
+	base    http.RoundTripper
+	now     func() time.Time
+}
+
+// newTokenPool returns a pool over creds that sends requests through base (http.DefaultTransport if nil).
+func newTokenPool(creds []*credential, base http.RoundTripper) *tokenPool {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	members := make([]*poolMember, 0, len(creds))
+	for _, cred := range creds {
+		members = append(members, &poolMember{cred: cred, remaining: -1})
+	}
+	return &tokenPool{members: members, base: base, now: time.Now}
+}
+
+// usable reports whether m can serve requests at the given time.
+func (m *poolMember) usable(now time.Time) bool {
+	return m.removed == "" && (m.remaining != 0 || !now.Before(m.reset))
+}
+
+// quota returns the remaining requests of m, or -1 when unknown or when the quota has been reset
+// since it was last reported.
+func (m *poolMember) quota(now time.Time) int {
+	if m.remaining < 0 || m.remaining == 0 && !now.Before(m.reset) {
+		return -1
+	}
+	return m.remaining
+}
+
+// pick returns the usable member with the most remaining quota and counts the request against it.
+// Members whose quota is unknown are preferred so that every token reports its quota early.
+// It must be called with p.mu held.
+func (p *tokenPool) pick() *poolMember {
+	var best *poolMember
+	bestScore := -1
+	now := p.now()
+	for _, m := range p.members {
+		if !m.usable(now) {
+			continue
+		}
+		score := m.quota(now)
+		if score < 0 {
+			score = math.MaxInt
+		}
+		if score > bestScore {
+			best, bestScore = m, score
+		}
+	}
+	if best != nil {
+		best.requests++
+	}
+	return best
+}
+
+// observe records the rate-limit state reported by resp for m and reports whether the request
+// should be retried with another credential.
+func (p *tokenPool) observe(m *poolMember, resp *http.Response) bool {
+	if v, err := strconv.Atoi(resp.Header.Get(headerRateRemaining)); err == nil {
+		m.remaining = v
+	}
+	if v, err := strconv.ParseInt(resp.Header.Get(headerRateReset), 10, 64); err == nil {
+		m.reset = time.Unix(v, 0)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		m.removed = "unauthorized"
+		return true
+	case (resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests) && m.remaining == 0:
+		return true
+	default:
+		return false
+	}
+}
+
+// aggregateRemaining returns the combined remaining quota of all usable members. Members with an
+// unknown quota count as one request so that the total never drops to zero while they are usable.
+// It must be called with p.mu held.
+func (p *tokenPool) aggregateRemaining() int {
+	total := 0
+	now := p.now()
+	for _, m := range p.members {
+		if !m.usable(now) {
+			continue
+		}
+		if q := m.quota(now); q >= 0 {
+			total += q
+		} else {
+			total++
+		}
+	}
+	return total
+}
+
+// RoundTrip implements http.RoundTripper.
+func (p *tokenPool) RoundTrip(req *http.Request) (*http.Response, error) {
+	for {
+		p.mu.Lock()
+		m := p.pick()
+		p.mu.Unlock()
+		if m == nil {
+			return nil, errNoUsableToken
+		}
+
+		token, err := m.cred.source.Token()
+		if err != nil {
+			p.mu.Lock()
+			m.removed = "token error"
+			p.mu.Unlock()
+			continue
+		}
+
+		attempt := req.Clone(req.Context())
+		if req.Body != nil && req.GetBody != nil {
+			if attempt.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+		attempt.Header.Set("Authorization", "Bearer "+token.AccessToken)
+
+		resp, err := p.base.RoundTrip(attempt)
+		if err != nil {
+			return nil, err
+		}
+
+		p.mu.Lock()
+		retry := p.observe(m, resp)
+		canRetry := req.Body == nil || req.GetBody != nil
+		if retry && canRetry && p.hasAlternative() {
+			p.mu.Unlock()
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+			continue
+		}
+		// Report the pool's combined quota so the GitHub client does not stop issuing requests
+		// when only the credential used for this response ran out.
+		if resp.Header.Get(headerRateRemaining) != "" {
+			resp.Header.Set(headerRateRemaining, strconv.Itoa(p.aggregateRemaining()))
+		}
+		p.mu.Unlock()
+		return resp, nil
+	}
+}
+
+// hasAlternative reports whether any member is still usable. It must be called with p.mu held.
+func (p *tokenPool) hasAlternative() bool {
+	now := p.now()
+	for _, m := range p.members {
+		if m.usable(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeSummary prints how many requests each credential served. Token values are never printed.
+func (p *tokenPool) writeSummary(w io.Writer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	_, _ = fmt.Fprintln(w, "GitHub token usage:")
+	for _, m := range p.members {
+		line := fmt.Sprintf("  %s: %d requests", m.cred.name, m.requests)
+		if m.remaining >= 0 {
+			line += fmt.Sprintf(", %d remaining", m.remaining)
+		}
+		if m.removed != "" {
+			line += " (removed: " + m.removed + ")"
+		}
+		_, _ = fmt.Fprintln(w, line)
+	}
+}
@@ -0,0 +1,122 @@
+// Package main provides the core functionality for updating GitHub star counts in Markdown and AsciiDoc files.
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+	"github.com/stn1slv/github-markdown-stars-updater/pkg/stars"
+)
+
+// fakeQuotaServer serves repository lookups and tracks a separate rate limit per token.
+type fakeQuotaServer struct {
+	mu        sync.Mutex
+	remaining map[string]int
+	invalid   map[string]bool
+	used      map[string]int
+}
+
+func (f *fakeQuotaServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if f.invalid[token] {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = fmt.Fprint(w, `{"message": "Bad credentials"}`)
+		return
+	}
+	f.used[token]++
+	reset := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	w.Header().Set("X-RateLimit-Reset", reset)
+	if f.remaining[token] == 0 {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = fmt.Fprint(w, `{"message": "API rate limit exceeded"}`)
+		return
+	}
+	f.remaining[token]--
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(f.remaining[token]))
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = fmt.Fprint(w, `{"stargazers_count": 5}`)
+}
+
+func TestTokenPoolRotation(t *testing.T) {
+	fake := &fakeQuotaServer{
+		remaining: map[string]int{"tok-a": 2, "tok-b": 10, "tok-c": 50},
+		invalid:   map[string]bool{"tok-c": true},
+		used:      map[string]int{},
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	creds := append(staticCredentials("TEST_TOKENS", []string{"tok-a", "tok-b"}), staticCredential("revoked", "tok-c"))
+	pool := newTokenPool(creds, server.Client().Transport)
+	client := github.NewClient(&http.Client{Transport: pool})
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	fetcher := &stars.GitHubFetcher{Client: client}
+
+	for i := range 12 {
+		count, err := fetcher.FetchStars(context.Background(), "owner/repo")
+		if err != nil {
+			t.Fatalf("request %d: unexpected error: %v", i, err)
+		}
+		if count != 5 {
+			t.Fatalf("request %d: expected 5 stars, got %d", i, count)
+		}
+	}
+
+	// Both quotas are used up in full, and the revoked token never succeeds.
+	if fake.used["tok-a"] != 2 || fake.used["tok-b"] != 10 || fake.used["tok-c"] != 0 {
+		t.Errorf("unexpected per-token usage: %v", fake.used)
+	}
+
+	_, err := fetcher.FetchStars(context.Background(), "owner/repo")
+	if err == nil {
+		t.Fatal("expected an error once every token is exhausted")
+	}
+
+	var summary bytes.Buffer
+	pool.writeSummary(&summary)
+	out := summary.String()
+	for _, want := range []string{"TEST_TOKENS #1: ", "TEST_TOKENS #2: ", "revoked: 1 requests (removed: unauthorized)"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("summary %q does not contain %q", out, want)
+		}
+	}
+	for _, token := range []string{"tok-a", "tok-b", "tok-c"} {
+		if strings.Contains(out, token) {
+			t.Errorf("summary leaks token %q: %s", token, out)
+		}
+	}
+}
+
+func TestTokenPoolPrefersMostRemaining(t *testing.T) {
+	pool := newTokenPool(staticCredentials("T", []string{"a", "b", "c"}), nil)
+	now := time.Now()
+	pool.now = func() time.Time { return now }
+	pool.members[0].remaining = 100
+	pool.members[1].remaining = 4000
+	pool.members[2].remaining = 0
+	pool.members[2].reset = now.Add(time.Minute)
+
+	if m := pool.pick(); m != pool.members[1] {
+		t.Errorf("expected the member with the most quota, got %s", m.cred.name)
+	}
+
+	// Once the exhausted token's reset time has passed it is tried again.
+	pool.now = func() time.Time { return now.Add(2 * time.Minute) }
+	if m := pool.pick(); m != pool.members[2] {
+		t.Errorf("expected the reset member, got %s", m.cred.name)
+	}
+}
@@ -0,0 +1,128 @@
+// Package main provides the core functionality for updating GitHub star counts in Markdown and AsciiDoc files.
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Defaults of the watch mode.
+const (
+	defaultWatchInterval = 500 * time.Millisecond
+	defaultWatchDebounce = 300 * time.Millisecond
+)
+
+// fileState identifies a version of a file without reading it.
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// pendingChange is a changed file waiting for the debounce period to pass without further saves.
+type pendingChange struct {
+	state fileState
+	since time.Time
+}
+
+// watcher polls the watched inputs and updates every document that changed. Rapid saves are
+// debounced, and the state of a document after the watcher's own write counts as seen, so that
+// the write does not trigger another update.
+type watcher struct {
+	inputs   []string
+	debounce time.Duration
+	update   func(ctx context.Context, file string) error
+	stderr   io.Writer
+
+	seen    map[string]fileState
+	pending map[string]pendingChange
+}
+
+// newWatcher returns a watcher for the files, directories and glob patterns in inputs whose
+// current state counts as seen.
+func newWatcher(inputs []string, debounce time.Duration, update func(context.Context, string) error, stderr io.Writer) *watcher {
+	w := &watcher{
+		inputs:   inputs,
+		debounce: debounce,
+		update:   update,
+		stderr:   stderr,
+		seen:     make(map[string]fileState),
+		pending:  make(map[string]pendingChange),
+	}
+	w.markSeen(w.files()...)
+	return w
+}
+
+// run polls every interval until ctx is done.
+func (w *watcher) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			w.poll(ctx, now)
+		}
+	}
+}
+
+// poll updates the documents whose last change is at least the debounce period before now. Files
+// appearing in a watched directory or matching a watched pattern are picked up as changes.
+func (w *watcher) poll(ctx context.Context, now time.Time) {
+	for _, file := range w.files() {
+		state, err := statFile(file)
+		if err != nil {
+			// Deleted, or being replaced by an editor; a later poll sees the new file.
+			continue
+		}
+		if seen, ok := w.seen[file]; ok && seen == state {
+			delete(w.pending, file)
+			continue
+		}
+		change, ok := w.pending[file]
+		if !ok || change.state != state {
+			w.pending[file] = pendingChange{state: state, since: now}
+			continue
+		}
+		if now.Sub(change.since) < w.debounce {
+			continue
+		}
+
+		delete(w.pending, file)
+		if err := w.update(ctx, file); err != nil {
+			_, _ = fmt.Fprintln(w.stderr, "Error:", err)
+		}
+		w.markSeen(file)
+	}
+}
+
+// files returns the current files of the watched inputs.
+func (w *watcher) files() []string {
+	files, err := expandInputs(w.inputs)
+	if err != nil {
+		_, _ = fmt.Fprintln(w.stderr, "Error:", err)
+		return nil
+	}
+	return files
+}
+
+// markSeen records the current state of files, e.g. after writing them.
+func (w *watcher) markSeen(files ...string) {
+	for _, file := range files {
+		if state, err := statFile(file); err == nil {
+			w.seen[file] = state
+		}
+	}
+}
+
+// statFile returns the current state of file.
+func statFile(file string) (fileState, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return fileState{}, err
+	}
+	return fileState{modTime: info.ModTime(), size: info.Size()}, nil
+}
@@ -0,0 +1,130 @@
+// Package main provides the core functionality for updating GitHub star counts in Markdown and AsciiDoc files.
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stn1slv/github-markdown-stars-updater/pkg/stars"
+)
+
+func TestWatcherDebounceAndOwnWrites(t *testing.T) {
+	dir := t.TempDir()
+	doc := filepath.Join(dir, "list.md")
+	writeFile(t, doc, "start\n")
+	writeFile(t, filepath.Join(dir, "notes.txt"), "not a document\n")
+
+	updates := make(map[string]int)
+	update := func(_ context.Context, file string) error {
+		updates[file]++
+		// The watcher's own write must not trigger another update.
+		f, err := os.OpenFile(file, os.O_APPEND|os.O_WRONLY, 0)
+		if err != nil {
+			return err
+		}
+		_, err = f.WriteString("updated\n")
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		return err
+	}
+	var stderr bytes.Buffer
+	w := newWatcher([]string{dir}, 100*time.Millisecond, update, &stderr)
+	ctx := context.Background()
+	start := time.Now()
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+
+	w.poll(ctx, at(0))
+	if len(updates) != 0 {
+		t.Fatalf("expected no update before any change, got %v", updates)
+	}
+
+	// Two rapid saves: the second one restarts the debounce period.
+	writeFile(t, doc, "first save\n")
+	w.poll(ctx, at(10))
+	w.poll(ctx, at(60))
+	writeFile(t, doc, "second, longer save\n")
+	w.poll(ctx, at(120))
+	w.poll(ctx, at(200))
+	if updates[doc] != 0 {
+		t.Fatalf("expected the update to wait for the debounce period, got %d updates", updates[doc])
+	}
+	w.poll(ctx, at(230))
+	w.poll(ctx, at(400))
+	w.poll(ctx, at(1000))
+	if updates[doc] != 1 {
+		t.Errorf("expected exactly one update, got %d", updates[doc])
+	}
+	if got := mustRead(t, doc); got != "second, longer save\nupdated\n" {
+		t.Errorf("unexpected content %q", got)
+	}
+
+	// New documents in a watched directory are picked up; other files are ignored.
+	added := filepath.Join(dir, "sub", "added.md")
+	writeFile(t, added, "new\n")
+	w.poll(ctx, at(1100))
+	w.poll(ctx, at(1200))
+	if updates[added] != 1 || len(updates) != 2 {
+		t.Errorf("expected one update of the new document, got %v", updates)
+	}
+	if stderr.Len() != 0 {
+		t.Errorf("unexpected errors: %q", stderr.String())
+	}
+}
+
+func TestWatchFetchesOnlyNewRepositories(t *testing.T) {
+	dir := t.TempDir()
+	doc := filepath.Join(dir, "list.md")
+	writeFile(t, doc, "- [A](https://github.com/owner/a)\n")
+
+	requests := make(map[string]int)
+	var stdout, stderr bytes.Buffer
+	fetcher := newStarFetcher(nil, newMetricsClient(nil), nil, &stderr)
+	fetcher.source = stars.StarFetcherFunc(func(_ context.Context, repo string) (int, error) {
+		requests[repo]++
+		return map[string]int{"owner/a": 1500, "owner/b": 20}[repo], nil
+	})
+	opts := defaultOptions()
+	update := func(ctx context.Context, file string) error {
+		return processFile(ctx, file, &opts, nil, fetcher, &stdout, &stderr)
+	}
+	ctx := context.Background()
+	if err := update(ctx, doc); err != nil {
+		t.Fatal(err)
+	}
+
+	w := newWatcher([]string{doc}, 0, update, &stderr)
+	writeFile(t, doc, mustRead(t, doc)+"- [B](https://github.com/owner/b)\n")
+	now := time.Now()
+	w.poll(ctx, now)
+	w.poll(ctx, now)
+
+	expected := "- [A (⭐1.5k)](https://github.com/owner/a)\n- [B (⭐20)](https://github.com/owner/b)\n"
+	if got := mustRead(t, doc); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+	if requests["owner/a"] != 1 || requests["owner/b"] != 1 {
+		t.Errorf("expected one request per repository, got %v", requests)
+	}
+}
+
+func TestWatchFlagValidation(t *testing.T) {
+	isolateAuthEnv(t)
+	doc := filepath.Join(t.TempDir(), "list.md")
+	writeFile(t, doc, "- [A](https://github.com/owner/a)\n")
+
+	for _, args := range [][]string{
+		{"-watch", "-dry-run", doc},
+		{"update", "-watch", "-out", "out.md", doc},
+		{"-watch", "-watch-interval", "0s", doc},
+	} {
+		var stdout, stderr bytes.Buffer
+		if code := run(args, &stdout, &stderr); code != 1 {
+			t.Errorf("%v: expected exit code 1, got %d: %s", args, code, stderr.String())
+		}
+	}
+}
@@ -0,0 +1,156 @@
+// Package main provides the core functionality for updating GitHub star counts in Markdown and AsciiDoc files.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// defaultFileMode is the mode of newly created documents. Documents are usually public, so they
+// are readable by everyone like files created by an editor.
+const defaultFileMode fs.FileMode = 0o644
+
+// backupSuffix is appended to the document name for the copy kept by -backup.
+const backupSuffix = ".bak"
+
+// writeFileAtomic replaces the file at path with data so that readers see either the old or the
+// new content, never a partial write: data goes to a temporary file in the same directory, which
+// is synced and renamed over the target. A symlink at path is followed, so the link stays in place
+// and its target is replaced. The mode and, where the platform allows, the owner of an existing
+// file are kept. With backup, the previous content is kept next to the target with a ".bak"
+// suffix. A file that already holds data is left untouched, and written reports false.
+func writeFileAtomic(path string, data []byte, backup bool) (written bool, err error) {
+	target, info, err := resolveTarget(path)
+	if err != nil {
+		return false, err
+	}
+	if info != nil {
+		previous, err := os.ReadFile(filepath.Clean(target))
+		if err != nil {
+			return false, err
+		}
+		if bytes.Equal(previous, data) {
+			return false, nil
+		}
+	}
+	return replaceFile(target, info, backup, func(w io.Writer) (bool, error) {
+		_, err := w.Write(data)
+		return true, err
+	})
+}
+
+// writeStreamAtomic is writeFileAtomic for documents too large to hold in memory: write streams
+// the new content and reports whether it differs from the current one. The file is left untouched
+// when it does not, and written reports false.
+func writeStreamAtomic(path string, backup bool, write func(w io.Writer) (changed bool, err error)) (written bool, err error) {
+	target, info, err := resolveTarget(path)
+	if err != nil {
+		return false, err
+	}
+	return replaceFile(target, info, backup, write)
+}
+
+// resolveTarget follows a symlink at path and returns the file to replace with its current
+// info, which is nil when the file does not exist yet.
+func resolveTarget(path string) (string, fs.FileInfo, error) {
+	target, err := filepath.EvalSymlinks(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		target, err = path, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	info, err := os.Stat(target)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return target, nil, nil
+	case err != nil:
+		return "", nil, err
+	case !info.Mode().IsRegular():
+		return "", nil, fmt.Errorf("%s is not a regular file", path)
+	}
+	return target, info, nil
+}
+
+// replaceFile writes the content produced by write to a temporary file next to target and renames
+// it over target, keeping the mode and owner of the existing file described by info. Nothing is
+// replaced when write reports no change. With backup, the existing file is first copied next to
+// target with a ".bak" suffix.
+func replaceFile(target string, info fs.FileInfo, backup bool, write func(w io.Writer) (bool, error)) (written bool, err error) {
+	mode := defaultFileMode
+	if info != nil {
+		mode = info.Mode().Perm()
+	}
+
+	dir := filepath.Dir(target)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(target)+".tmp-*")
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err != nil || !written {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	changed, err := write(tmp)
+	if err != nil || !changed {
+		return false, err
+	}
+	if backup && info != nil {
+		if err = backupFile(target); err != nil {
+			return false, fmt.Errorf("writing backup: %w", err)
+		}
+	}
+	if err = tmp.Chmod(mode); err != nil {
+		return false, err
+	}
+	if info != nil {
+		preserveOwner(tmp, info)
+	}
+	if err = tmp.Sync(); err != nil {
+		return false, err
+	}
+	if err = tmp.Close(); err != nil {
+		return false, err
+	}
+	if err = os.Rename(tmp.Name(), target); err != nil {
+		return false, err
+	}
+	syncDir(dir)
+	return true, nil
+}
+
+// backupFile copies the file at path next to it with a ".bak" suffix, replacing an older backup.
+func backupFile(path string) error {
+	target, info, err := resolveTarget(path + backupSuffix)
+	if err != nil {
+		return err
+	}
+	_, err = replaceFile(target, info, false, func(w io.Writer) (bool, error) {
+		src, err := os.Open(filepath.Clean(path))
+		if err != nil {
+			return false, err
+		}
+		defer func() { _ = src.Close() }()
+		_, err = io.Copy(w, src)
+		return true, err
+	})
+	return err
+}
+
+// syncDir makes a rename in dir durable where the platform supports syncing directories.
+func syncDir(dir string) {
+	d, err := os.Open(filepath.Clean(dir))
+	if err != nil {
+		return
+	}
+	_ = d.Sync()
+	_ = d.Close()
+}
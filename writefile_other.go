@@ -0,0 +1,9 @@
+//go:build !unix
+
+// Package main provides the core functionality for updating GitHub star counts in Markdown and AsciiDoc files.
+package main
+
+import "os"
+
+// preserveOwner is a no-op on platforms without Unix file ownership.
+func preserveOwner(*os.File, os.FileInfo) {}
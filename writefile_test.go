@@ -0,0 +1,123 @@
+// Package main provides the core functionality for updating GitHub star counts in Markdown and AsciiDoc files.
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "list.md")
+	writeFile(t, path, "old\n")
+	if err := os.Chmod(path, 0o640); err != nil {
+		t.Fatal(err)
+	}
+
+	written, err := writeFileAtomic(path, []byte("new\n"), true)
+	if err != nil || !written {
+		t.Fatalf("expected the file to be written, got %v, %v", written, err)
+	}
+	if got := mustRead(t, path); got != "new\n" {
+		t.Errorf("expected the new content, got %q", got)
+	}
+	if got := mustRead(t, path+backupSuffix); got != "old\n" {
+		t.Errorf("expected the old content in the backup, got %q", got)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o640 {
+		t.Errorf("expected mode 0640 to be kept, got %o", info.Mode().Perm())
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if strings.Contains(entry.Name(), ".tmp-") {
+			t.Errorf("temporary file %s left behind", entry.Name())
+		}
+	}
+}
+
+func TestWriteFileAtomicUnchanged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "list.md")
+	writeFile(t, path, "same\n")
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(path, past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	written, err := writeFileAtomic(path, []byte("same\n"), true)
+	if err != nil || written {
+		t.Fatalf("expected the file to be left alone, got %v, %v", written, err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(past) {
+		t.Errorf("expected the modification time to be kept, got %v", info.ModTime())
+	}
+	if _, err := os.Stat(path + backupSuffix); !os.IsNotExist(err) {
+		t.Errorf("expected no backup of an unchanged file, got %v", err)
+	}
+}
+
+func TestWriteFileAtomicSymlinkAndNewFile(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "docs", "list.md")
+	writeFile(t, target, "old\n")
+	link := filepath.Join(dir, "README.md")
+	if err := os.Symlink(filepath.Join("docs", "list.md"), link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	if _, err := writeFileAtomic(link, []byte("new\n"), false); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Lstat(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		t.Error("expected the symlink to stay in place")
+	}
+	if got := mustRead(t, target); got != "new\n" {
+		t.Errorf("expected the link target to be updated, got %q", got)
+	}
+
+	created := filepath.Join(dir, "out.md")
+	if written, err := writeFileAtomic(created, []byte("content\n"), true); err != nil || !written {
+		t.Fatalf("expected a new file, got %v, %v", written, err)
+	}
+	if info, err := os.Stat(created); err != nil || info.Mode().Perm() != defaultFileMode {
+		t.Errorf("expected mode %o for a new file, got %v, %v", defaultFileMode, info, err)
+	}
+}
+
+func TestRunLeavesUpToDateFileUntouched(t *testing.T) {
+	isolateAuthEnv(t)
+	fixture := filepath.Join(t.TempDir(), "stars.json")
+	writeFile(t, fixture, `{"owner/a": 1500}`)
+	doc := filepath.Join(t.TempDir(), "list.md")
+	writeFile(t, doc, "- [A (⭐1.5k)](https://github.com/owner/a)\n")
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-offline", "-fixture", fixture, "-backup", doc}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "already up to date") {
+		t.Errorf("expected the file to be reported as up to date, got %q", stdout.String())
+	}
+	if _, err := os.Stat(doc + backupSuffix); !os.IsNotExist(err) {
+		t.Errorf("expected no backup, got %v", err)
+	}
+}
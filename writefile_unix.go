@@ -0,0 +1,17 @@
+//go:build unix
+
+// Package main provides the core functionality for updating GitHub star counts in Markdown and AsciiDoc files.
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// preserveOwner gives f the owner and group of the file described by info. Only privileged
+// processes may change the owner, so failures are ignored and the file keeps the current user.
+func preserveOwner(f *os.File, info os.FileInfo) {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		_ = f.Chown(int(st.Uid), int(st.Gid))
+	}
+}